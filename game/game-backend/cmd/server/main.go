@@ -1,54 +1,138 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
 	"game-backend/config"
 	"game-backend/internal/handler"
 	"game-backend/internal/middleware"
+	"game-backend/internal/model"
+	"game-backend/internal/payment"
 	"game-backend/internal/service"
 	"game-backend/internal/websocket"
 	"game-backend/pkg/database"
+	"game-backend/pkg/logger"
+	"game-backend/pkg/metrics"
+	"game-backend/pkg/money"
+	"game-backend/pkg/tracing"
 )
 
 func main() {
 	// 加载配置
 	if err := config.LoadConfig("config/config.yaml"); err != nil {
-		log.Fatalf("加载配置失败: %v", err)
+		logrus.WithError(err).Fatal("加载配置失败")
 	}
 
+	// 初始化日志
+	if err := logger.Init(config.AppConfig.Log); err != nil {
+		logrus.WithError(err).Fatal("初始化日志失败")
+	}
+
+	// 子命令：./main migrate <up|down|status|to|force>
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
+	}
+
+	// 加载币种表
+	if err := money.Init(config.AppConfig.Wallet); err != nil {
+		logrus.WithError(err).Fatal("加载币种配置失败")
+	}
+
+	// 初始化链路追踪
+	shutdownTracing, err := tracing.Init(config.AppConfig.Tracing)
+	if err != nil {
+		logrus.WithError(err).Fatal("初始化链路追踪失败")
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logrus.WithError(err).Warn("关闭链路追踪失败")
+		}
+	}()
+
 	// 初始化数据库
 	if err := database.InitMySQL(); err != nil {
-		log.Fatalf("初始化MySQL失败: %v", err)
+		logrus.WithError(err).Fatal("初始化MySQL失败")
 	}
 	defer database.Close()
 
-	// 自动迁移数据库表结构
-	if err := database.AutoMigrate(); err != nil {
-		log.Fatalf("数据库迁移失败: %v", err)
+	// 数据库迁移
+	if config.AppConfig.Database.MigrateOnStart {
+		if err := database.RunMigrations(); err != nil {
+			logrus.WithError(err).Fatal("数据库迁移失败")
+		}
+	}
+	if config.AppConfig.Database.AutoMigrate {
+		logrus.Warn("已启用GORM AutoMigrate，仅应在开发环境使用")
+		if err := database.AutoMigrate(); err != nil {
+			logrus.WithError(err).Fatal("数据库迁移失败")
+		}
+	}
+
+	// 启动时解析一次内嵌迁移的最新版本，就绪检查只查询数据库中的当前版本
+	if _, err := database.LatestMigrationVersion(); err != nil {
+		logrus.WithError(err).Fatal("加载内嵌迁移失败")
 	}
 
 	// 初始化Redis
 	if err := database.InitRedis(); err != nil {
-		log.Fatalf("初始化Redis失败: %v", err)
+		logrus.WithError(err).Fatal("初始化Redis失败")
 	}
 	defer database.CloseRedis()
 
+	// 创建服务
+	auditService := service.NewAuditService(database.GetDB())
+	walletService := service.NewWalletService(database.GetDB(), auditService)
+	responsibleService := service.NewResponsibleService(database.GetDB(), auditService)
+	authService := service.NewAuthService(database.GetDB(), walletService)
+	leaderboardService := service.NewLeaderboardService(database.GetDB(), database.GetRedisClient())
+	gameService := service.NewGameService(database.GetDB(), walletService, responsibleService, leaderboardService)
+	autoBetService := service.NewAutoBetService(database.GetDB(), gameService, auditService)
+	chatService := service.NewChatService(database.GetDB(), auditService, service.NewWordListFilter(config.AppConfig.Chat.BannedWords))
+
+	// 创建支付渠道
+	paymentProvider, err := payment.NewProvider(config.AppConfig.Payment)
+	if err != nil {
+		logrus.WithError(err).Fatal("初始化支付渠道失败")
+	}
+	paymentService := service.NewPaymentService(database.GetDB(), walletService, auditService, responsibleService, paymentProvider)
+
+	// 令牌还需对应仍有效的登录会话，登出、重新登录或开启自我限制后立即失效
+	sessions := func(ctx context.Context, token string) (bool, error) {
+		return authService.WithContext(ctx).SessionActive(token)
+	}
+
 	// 创建WebSocket中心
-	wsHub := websocket.NewHub()
+	wsHub := websocket.NewHub(gameService, autoBetService, chatService, leaderboardService, database.GetRedisClient(), sessions)
 	go wsHub.Run()
 
-	// 创建服务
-	authService := service.NewAuthService(database.GetDB())
-	gameService := service.NewGameService(database.GetDB())
+	// 注册WebSocket指标
+	metrics.RegisterWebSocketGauges(
+		func() float64 { return float64(wsHub.GetClientsCount()) },
+		func() float64 { return float64(wsHub.SendQueueDepth()) },
+		func() float64 { return float64(wsHub.GetGameState().OnlineCount) },
+	)
 
 	// 创建处理器
-	authHandler := handler.NewAuthHandler(authService)
-	gameHandler := handler.NewGameHandler(gameService, wsHub)
+	authHandler := handler.NewAuthHandler(authService, auditService)
+	gameHandler := handler.NewGameHandler(gameService, leaderboardService, wsHub)
+	autoBetHandler := handler.NewAutoBetHandler(autoBetService, wsHub)
+	auditHandler := handler.NewAuditHandler(auditService)
+	chatHandler := handler.NewChatHandler(chatService, auditService, wsHub)
+	walletHandler := handler.NewWalletHandler(walletService)
+	paymentHandler := handler.NewPaymentHandler(paymentService)
+	responsibleHandler := handler.NewResponsibleHandler(responsibleService, wsHub)
+	healthHandler := handler.NewHealthHandler(database.GetDB(), database.GetRedisClient(), wsHub)
 
 	// 设置Gin模式
 	if config.AppConfig.Server.IsDebug() {
@@ -57,17 +141,27 @@ func main() {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
+	// 管理接口以数据库中的当前角色为准
+	roles := func(ctx context.Context, userID uint) (string, error) {
+		return authService.WithContext(ctx).CurrentRole(userID)
+	}
+
 	// 创建路由
-	router := setupRouter(authHandler, gameHandler, wsHub)
+	router := setupRouter(sessions, roles, authHandler, gameHandler, autoBetHandler, walletHandler, paymentHandler, responsibleHandler, auditHandler, chatHandler, healthHandler, wsHub)
 
 	// 启动服务器
-	serverAddr := config.AppConfig.Server.GetServerAddr()
-	log.Printf("服务器启动在: %s", serverAddr)
+	serverCfg := config.AppConfig.Server
+	srv := &http.Server{
+		Addr:         serverCfg.GetServerAddr(),
+		Handler:      router,
+		ReadTimeout:  time.Duration(serverCfg.ReadTimeout) * time.Second,
+		WriteTimeout: time.Duration(serverCfg.WriteTimeout) * time.Second,
+	}
+	logrus.WithField("addr", srv.Addr).Info("服务器启动")
 
-	// 优雅关闭
 	go func() {
-		if err := router.Run(serverAddr); err != nil {
-			log.Fatalf("服务器启动失败: %v", err)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logrus.WithError(err).Fatal("服务器启动失败")
 		}
 	}()
 
@@ -76,25 +170,45 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	log.Println("服务器正在关闭...")
+	// 优雅关闭：先让就绪检查失败，等待上游摘除流量后再停止接收请求
+	logrus.Info("服务器正在关闭...")
+	healthHandler.MarkShuttingDown()
+	time.Sleep(time.Duration(serverCfg.DrainDelay) * time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(serverCfg.ShutdownTimeout)*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		logrus.WithError(err).Error("服务器关闭超时")
+	}
+	if err := wsHub.LeavePresence(ctx); err != nil {
+		logrus.WithError(err).Warn("清除在线状态失败")
+	}
+
+	logrus.Info("服务器已关闭")
 }
 
 // setupRouter 设置路由
-func setupRouter(authHandler *handler.AuthHandler, gameHandler *handler.GameHandler, wsHub *websocket.Hub) *gin.Engine {
+func setupRouter(sessions middleware.SessionChecker, roles middleware.RoleLoader, authHandler *handler.AuthHandler, gameHandler *handler.GameHandler, autoBetHandler *handler.AutoBetHandler, walletHandler *handler.WalletHandler, paymentHandler *handler.PaymentHandler, responsibleHandler *handler.ResponsibleHandler, auditHandler *handler.AuditHandler, chatHandler *handler.ChatHandler, healthHandler *handler.HealthHandler, wsHub *websocket.Hub) *gin.Engine {
 	router := gin.New()
+	requireAuth := middleware.AuthMiddleware(sessions)
 
 	// 中间件
+	router.Use(middleware.TracingMiddleware(config.AppConfig.Tracing.ServiceName))
+	router.Use(middleware.RequestIDMiddleware())
 	router.Use(middleware.LoggerMiddleware())
+	router.Use(middleware.MetricsMiddleware())
 	router.Use(middleware.RecoveryMiddleware())
 	router.Use(middleware.CORSMiddleware())
 
 	// 健康检查
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{
-			"status":  "ok",
-			"message": "服务运行正常",
-		})
-	})
+	router.GET("/health", healthHandler.Livez)
+	router.GET("/livez", healthHandler.Livez)
+	router.GET("/readyz", healthHandler.Readyz)
+
+	// 监控指标
+	if config.AppConfig.Metrics.Enabled {
+		router.GET(config.AppConfig.Metrics.Path, gin.WrapH(promhttp.Handler()))
+	}
 
 	// API版本组
 	v1 := router.Group("/api/v1")
@@ -104,10 +218,10 @@ func setupRouter(authHandler *handler.AuthHandler, gameHandler *handler.GameHand
 	{
 		auth.POST("/login", middleware.LoginRateLimitMiddleware(), authHandler.Login)
 		auth.POST("/register", middleware.LoginRateLimitMiddleware(), authHandler.Register)
-		auth.POST("/logout", middleware.AuthMiddleware(), authHandler.Logout)
-		auth.GET("/profile", middleware.AuthMiddleware(), authHandler.GetProfile)
-		auth.PUT("/profile", middleware.AuthMiddleware(), authHandler.UpdateProfile)
-		auth.POST("/refresh", middleware.AuthMiddleware(), authHandler.RefreshToken)
+		auth.POST("/logout", requireAuth, authHandler.Logout)
+		auth.GET("/profile", requireAuth, authHandler.GetProfile)
+		auth.PUT("/profile", requireAuth, authHandler.UpdateProfile)
+		auth.POST("/refresh", requireAuth, authHandler.RefreshToken)
 	}
 
 	// 游戏相关路由
@@ -115,16 +229,69 @@ func setupRouter(authHandler *handler.AuthHandler, gameHandler *handler.GameHand
 	{
 		// 公开接口
 		game.GET("/status", gameHandler.GetGameStatus)
+		game.GET("/round/current", gameHandler.GetCurrentRound)
+		game.GET("/online", gameHandler.GetOnlineUsers)
 		game.GET("/history", gameHandler.GetGameHistory)
+		game.GET("/history/analytics", gameHandler.GetHistoryAnalytics)
+		game.GET("/history/:round_id", gameHandler.GetRoundDetail)
 		game.GET("/leaderboard", gameHandler.GetLeaderboard)
 
 		// 需要认证的接口
-		gameAuth := game.Group("", middleware.AuthMiddleware())
+		gameAuth := game.Group("", requireAuth)
 		{
 			gameAuth.POST("/bet", middleware.APIRateLimitMiddleware(), gameHandler.PlaceBet)
+			gameAuth.POST("/bet/cancel", middleware.APIRateLimitMiddleware(), gameHandler.CancelBet)
 			gameAuth.POST("/cashout", middleware.APIRateLimitMiddleware(), gameHandler.Cashout)
 			gameAuth.GET("/bet/history", gameHandler.GetBetHistory)
 			gameAuth.GET("/stats", gameHandler.GetUserStats)
+			gameAuth.GET("/leaderboard/me", gameHandler.GetMyLeaderboardRank)
+			gameAuth.GET("/autobet", autoBetHandler.GetAutoBet)
+			gameAuth.POST("/autobet", middleware.APIRateLimitMiddleware(), autoBetHandler.StartAutoBet)
+			gameAuth.DELETE("/autobet", autoBetHandler.StopAutoBet)
+		}
+	}
+
+	// 钱包相关路由
+	wallet := v1.Group("/wallet")
+	{
+		wallet.GET("/currencies", walletHandler.ListCurrencies)
+		wallet.GET("", requireAuth, walletHandler.GetWallets)
+		wallet.GET("/transactions", requireAuth, walletHandler.GetTransactions)
+		wallet.POST("/deposits", requireAuth, middleware.APIRateLimitMiddleware(), paymentHandler.Deposit)
+		wallet.POST("/withdrawals", requireAuth, middleware.APIRateLimitMiddleware(), paymentHandler.Withdraw)
+		wallet.GET("/payments", requireAuth, paymentHandler.GetPayments)
+	}
+
+	// 负责任博彩设置
+	responsible := v1.Group("/responsible", requireAuth)
+	{
+		responsible.GET("", responsibleHandler.GetSettings)
+		responsible.PUT("/limits", responsibleHandler.SetLimit)
+		responsible.POST("/cool-off", responsibleHandler.CoolOff)
+		responsible.POST("/self-exclusion", responsibleHandler.SelfExclude)
+	}
+
+	// 支付渠道回调（通过签名校验，不走登录认证）
+	v1.POST("/payment/webhook/:provider", paymentHandler.Webhook)
+
+	// 管理接口（客服/管理员）
+	admin := v1.Group("/admin", requireAuth, middleware.RoleMiddleware(roles, model.RoleAdmin, model.RoleSupport))
+	{
+		admin.GET("/audit", auditHandler.ListAuditLogs)
+		admin.GET("/audit/verify", auditHandler.VerifyAuditChain)
+
+		// 聊天审核与禁言/封禁
+		admin.GET("/chat/messages", chatHandler.ListMessages)
+		admin.GET("/chat/sanctions", chatHandler.ListSanctions)
+		admin.POST("/chat/sanctions", chatHandler.CreateSanction)
+		admin.DELETE("/chat/sanctions/:id", chatHandler.LiftSanction)
+
+		// 提现审批仅限管理员
+		withdrawals := admin.Group("/withdrawals", middleware.RoleMiddleware(roles, model.RoleAdmin))
+		{
+			withdrawals.GET("", paymentHandler.ListWithdrawals)
+			withdrawals.POST("/:id/approve", paymentHandler.ApproveWithdrawal)
+			withdrawals.POST("/:id/reject", paymentHandler.RejectWithdrawal)
 		}
 	}
 
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/sirupsen/logrus"
+	"game-backend/pkg/database"
+)
+
+const migrateUsage = `用法: main migrate <command>
+
+命令:
+  up              执行所有未应用的迁移
+  down [n]        回滚最近n个迁移（默认1）
+  status          查看迁移状态
+  to <version>    迁移到指定版本（0表示全部回滚）
+  force <version> 将指定版本标记为已应用并清除dirty状态（不执行SQL）`
+
+// runMigrate 执行迁移子命令，返回进程退出码
+func runMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	if err := database.InitMySQL(); err != nil {
+		logrus.WithError(err).Error("初始化MySQL失败")
+		return 1
+	}
+	defer database.Close()
+
+	migrator, err := database.NewMigrator(database.GetDB())
+	if err != nil {
+		logrus.WithError(err).Error("加载迁移失败")
+		return 1
+	}
+
+	switch args[0] {
+	case "up":
+		err = migrator.Up()
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				fmt.Fprintln(os.Stderr, "回滚数量必须为正整数")
+				return 2
+			}
+		}
+		err = migrator.Down(steps)
+	case "to", "force":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			return 2
+		}
+		version, parseErr := strconv.ParseUint(args[1], 10, 64)
+		if parseErr != nil {
+			fmt.Fprintln(os.Stderr, "版本号必须为非负整数")
+			return 2
+		}
+		if args[0] == "to" {
+			err = migrator.To(uint(version))
+		} else {
+			err = migrator.Force(uint(version))
+		}
+	case "status":
+		err = printMigrationStatus(migrator)
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	if err != nil {
+		logrus.WithError(err).WithField("command", args[0]).Error("数据库迁移失败")
+		return 1
+	}
+	return 0
+}
+
+// printMigrationStatus 以表格形式输出迁移状态
+func printMigrationStatus(migrator *database.Migrator) error {
+	statuses, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED_AT")
+	for _, s := range statuses {
+		state := "pending"
+		appliedAt := "-"
+		if s.Dirty {
+			state = "dirty"
+		} else if s.Applied {
+			state = "applied"
+		}
+		if s.AppliedAt != nil {
+			appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,179 @@
+// wsbench 估算游戏进行中状态广播的带宽：每滴答完整GameStatusUpdate与GameTick加关键帧，以及permessage-deflate的效果
+//
+// 用法: go run ./cmd/wsbench [-clients 1000] [-seconds 30] [-interval 100] [-keyframe 10] [-level 1] [-threshold 256]
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"game-backend/internal/websocket"
+	"game-backend/pkg/money"
+	"game-backend/proto"
+)
+
+// scenario 一种广播方式
+type scenario struct {
+	name      string
+	ticks     bool // 关键帧之间发送GameTick，否则每次滴答发送GameStatusUpdate
+	every     int  // 每every次滴答发送一次滴答消息，模拟发送队列积压的客户端
+	compress  bool
+	threshold int
+}
+
+func main() {
+	clients := flag.Int("clients", 1000, "客户端数")
+	seconds := flag.Int("seconds", 30, "模拟的游戏进行时长(秒)")
+	interval := flag.Int("interval", 100, "滴答间隔(毫秒)")
+	keyframe := flag.Int("keyframe", 10, "关键帧间隔(滴答数)")
+	level := flag.Int("level", flate.BestSpeed, "压缩级别")
+	threshold := flag.Int("threshold", 256, "小于该字节数的消息不压缩")
+	flag.Parse()
+
+	scenarios := []scenario{
+		{name: "完整状态/每滴答", every: 1},
+		{name: "完整状态/每滴答+deflate", every: 1, compress: true},
+		{name: "滴答+关键帧", ticks: true, every: 1},
+		{name: "滴答+关键帧+deflate", ticks: true, every: 1, compress: true, threshold: *threshold},
+		{name: "滴答+关键帧(积压客户端,1/4频率)", ticks: true, every: 4},
+	}
+
+	tickCount := *seconds * 1000 / *interval
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "场景\t消息数/客户端\t平均字节/消息\t字节/秒/客户端\tKB/秒/%d客户端\tMbit/秒/%d客户端\n", *clients, *clients)
+	for _, s := range scenarios {
+		messages, total, err := run(s, tickCount, *interval, *keyframe, *level)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		perSecond := float64(total) / float64(*seconds)
+		fmt.Fprintf(w, "%s\t%d\t%.1f\t%.0f\t%.1f\t%.2f\n", s.name, messages,
+			float64(total)/float64(messages), perSecond,
+			perSecond*float64(*clients)/1024, perSecond*float64(*clients)*8/1e6)
+	}
+	w.Flush()
+
+	// 大消息的压缩效果，用于选择compression_threshold
+	fmt.Println()
+	fmt.Fprintln(w, "下注列表快照\t原始字节\tdeflate字节")
+	for _, n := range []int{1, 5, 20, 100, 500} {
+		raw, packed, err := snapshotSizes(n, *level)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(w, "%d注\t%d\t%d\n", n, raw, packed)
+	}
+	w.Flush()
+}
+
+// snapshotSizes 含n笔下注的RoundSnapshot编码后与压缩后的长度
+func snapshotSizes(n, level int) (int, int, error) {
+	snapshot := &proto.RoundSnapshot{RoundId: "round_1640995200", Seq: 1024, Timestamp: time.Now().Unix()}
+	for i := 0; i < n; i++ {
+		snapshot.Bets = append(snapshot.Bets, &proto.RoundBet{
+			BetId:       fmt.Sprintf("bet_0f8fad5b-d9cb-469f-a165-%012d", i),
+			UserId:      int64(10000 + i),
+			Username:    fmt.Sprintf("player%d", i),
+			Currency:    "CNY",
+			Amount:      "10.50",
+			AutoCashout: "2.00",
+			Remaining:   "10.50",
+		})
+	}
+
+	message, err := websocket.EncodeMessage(websocket.RoundSnapshot, snapshot)
+	if err != nil {
+		return 0, 0, err
+	}
+	packed, err := deflated(message, level)
+	return len(message), packed, err
+}
+
+// run 模拟一局进行中的滴答，返回单个客户端收到的消息数与线上字节数（含WebSocket帧头）
+func run(s scenario, tickCount, interval, keyframe, level int) (int, int, error) {
+	start := time.Now()
+	multiplier := decimal.NewFromInt(1)
+	step := decimal.New(1, -2)
+	seq := int64(0)
+
+	messages, total := 0, 0
+	for i := 1; i <= tickCount; i++ {
+		now := start.Add(time.Duration(i*interval) * time.Millisecond)
+		multiplier = multiplier.Add(step)
+
+		var (
+			message []byte
+			err     error
+		)
+		if !s.ticks || i%keyframe == 0 {
+			seq++
+			message, err = websocket.EncodeMessage(websocket.GameStatusUpdate, &proto.GameStatusUpdate{
+				GameId:            "crash_001",
+				State:             proto.GameState(1),
+				CurrentMultiplier: multiplier.StringFixed(money.MultiplierScale),
+				PlayersCount:      1000,
+				NextRoundIn:       int32(tickCount - i),
+				ServerTime:        now.Unix(),
+				Seq:               seq,
+				ServerTimeMs:      now.UnixMilli(),
+			})
+		} else if i%s.every == 0 {
+			message, err = websocket.EncodeMessage(websocket.GameTick, &proto.GameTick{
+				Multiplier: multiplier.StringFixed(money.MultiplierScale),
+				ElapsedMs:  int64(i * interval),
+			})
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		if message == nil {
+			continue
+		}
+
+		size := len(message)
+		if s.compress && size >= s.threshold {
+			if size, err = deflated(message, level); err != nil {
+				return 0, 0, err
+			}
+		}
+		messages++
+		total += frameHeader(size) + size
+	}
+	return messages, total, nil
+}
+
+// deflated permessage-deflate（不保留上下文）压缩后的负载长度，与gorilla/websocket一致去掉结尾的4字节
+func deflated(message []byte, level int) (int, error) {
+	var buf bytes.Buffer
+	fw, err := flate.NewWriter(&buf, level)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := fw.Write(message); err != nil {
+		return 0, err
+	}
+	if err := fw.Flush(); err != nil {
+		return 0, err
+	}
+	return buf.Len() - 4, nil
+}
+
+// frameHeader 服务端发送的WebSocket帧头长度（不加掩码）
+func frameHeader(payload int) int {
+	switch {
+	case payload < 126:
+		return 2
+	case payload <= 0xFFFF:
+		return 4
+	default:
+		return 10
+	}
+}
@@ -2,31 +2,41 @@ package config
 
 import (
 	"fmt"
-	"log"
 	"os"
 	"strconv"
 	"strings"
 
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 )
 
 // Config 应用配置结构
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	Database DatabaseConfig `mapstructure:"database"`
-	Redis    RedisConfig    `mapstructure:"redis"`
-	JWT      JWTConfig      `mapstructure:"jwt"`
-	Game     GameConfig     `mapstructure:"game"`
-	Log      LogConfig      `mapstructure:"log"`
+	Server      ServerConfig      `mapstructure:"server"`
+	Database    DatabaseConfig    `mapstructure:"database"`
+	Redis       RedisConfig       `mapstructure:"redis"`
+	JWT         JWTConfig         `mapstructure:"jwt"`
+	Game        GameConfig        `mapstructure:"game"`
+	Log         LogConfig         `mapstructure:"log"`
+	Metrics     MetricsConfig     `mapstructure:"metrics"`
+	Tracing     TracingConfig     `mapstructure:"tracing"`
+	Wallet      WalletConfig      `mapstructure:"wallet"`
+	Payment     PaymentConfig     `mapstructure:"payment"`
+	Responsible ResponsibleConfig `mapstructure:"responsible"`
+	WebSocket   WebSocketConfig   `mapstructure:"websocket"`
+	Chat        ChatConfig        `mapstructure:"chat"`
+	Leaderboard LeaderboardConfig `mapstructure:"leaderboard"`
 }
 
 // ServerConfig 服务器配置
 type ServerConfig struct {
-	Host         string `mapstructure:"host"`
-	Port         int    `mapstructure:"port"`
-	Mode         string `mapstructure:"mode"` // debug, release, test
-	ReadTimeout  int    `mapstructure:"read_timeout"`
-	WriteTimeout int    `mapstructure:"write_timeout"`
+	Host            string `mapstructure:"host"`
+	Port            int    `mapstructure:"port"`
+	Mode            string `mapstructure:"mode"` // debug, release, test
+	ReadTimeout     int    `mapstructure:"read_timeout"`
+	WriteTimeout    int    `mapstructure:"write_timeout"`
+	DrainDelay      int    `mapstructure:"drain_delay"`      // 秒，关闭前就绪检查失败后等待流量摘除的时间
+	ShutdownTimeout int    `mapstructure:"shutdown_timeout"` // 秒，等待进行中请求完成的最长时间
 }
 
 // DatabaseConfig 数据库配置
@@ -39,6 +49,9 @@ type DatabaseConfig struct {
 	Charset  string `mapstructure:"charset"`
 	MaxIdle  int    `mapstructure:"max_idle"`
 	MaxOpen  int    `mapstructure:"max_open"`
+
+	MigrateOnStart bool `mapstructure:"migrate_on_start"` // 启动时执行未应用的版本化迁移
+	AutoMigrate    bool `mapstructure:"auto_migrate"`     // 启动时执行GORM AutoMigrate，仅用于开发环境
 }
 
 // RedisConfig Redis配置
@@ -59,8 +72,6 @@ type JWTConfig struct {
 
 // GameConfig 游戏配置
 type GameConfig struct {
-	MinBetAmount      float64 `mapstructure:"min_bet_amount"`
-	MaxBetAmount      float64 `mapstructure:"max_bet_amount"`
 	MinMultiplier     float64 `mapstructure:"min_multiplier"`
 	MaxMultiplier     float64 `mapstructure:"max_multiplier"`
 	RoundDuration     int     `mapstructure:"round_duration"`     // 秒
@@ -68,16 +79,114 @@ type GameConfig struct {
 	WaitingDuration   int     `mapstructure:"waiting_duration"`   // 秒
 	UpdateInterval    int     `mapstructure:"update_interval"`    // 毫秒
 	MaxPlayersPerGame int     `mapstructure:"max_players_per_game"`
+	MaxBetsPerRound   int     `mapstructure:"max_bets_per_round"`  // 每位玩家每局最多同时下注数（下注位数量）
+	AutoBetMaxRounds  int     `mapstructure:"auto_bet_max_rounds"` // 单个自动下注会话最多轮数
+	CashoutGrace      int     `mapstructure:"cashout_grace"`       // 毫秒，崩盘前发出的止盈在此时间内到达仍按发出时倍数结算
+	CashoutLatencyCap int     `mapstructure:"cashout_latency_cap"` // 毫秒，止盈延迟补偿的单程延迟上限，不超过cashout_grace
+
+	// 单局风控，金额以基准币种计，0表示不限
+	MaxWinPerBet     float64 `mapstructure:"max_win_per_bet"`    // 单注最高盈利，达到时强制止盈
+	MaxRoundPayout   float64 `mapstructure:"max_round_payout"`   // 单局赔付总额上限，达到时强制所有进行中下注止盈
+	MaxRoundExposure float64 `mapstructure:"max_round_exposure"` // 单局潜在赔付总额上限，超出时拒绝新下注
 }
 
 // LogConfig 日志配置
 type LogConfig struct {
 	Level      string `mapstructure:"level"`
 	Format     string `mapstructure:"format"`
-	Output     string `mapstructure:"output"`
-	MaxSize    int    `mapstructure:"max_size"`
+	Output     string `mapstructure:"output"`    // stdout, stderr, file
+	FilePath   string `mapstructure:"file_path"` // output为file时的日志文件路径
+	MaxSize    int    `mapstructure:"max_size"`  // MB
 	MaxBackups int    `mapstructure:"max_backups"`
-	MaxAge     int    `mapstructure:"max_age"`
+	MaxAge     int    `mapstructure:"max_age"` // 天
+	Compress   bool   `mapstructure:"compress"`
+}
+
+// MetricsConfig 监控指标配置
+type MetricsConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Path    string `mapstructure:"path"`
+}
+
+// TracingConfig 链路追踪配置
+type TracingConfig struct {
+	Exporter    string  `mapstructure:"exporter"` // none, stdout, otlp
+	Endpoint    string  `mapstructure:"endpoint"` // OTLP HTTP地址，如 localhost:4318
+	Insecure    bool    `mapstructure:"insecure"`
+	ServiceName string  `mapstructure:"service_name"`
+	SampleRatio float64 `mapstructure:"sample_ratio"` // 0-1
+}
+
+// WalletConfig 钱包与币种配置
+type WalletConfig struct {
+	BaseCurrency string           `mapstructure:"base_currency"` // 排行榜与统计折算的基准币种
+	SignupBonus  float64          `mapstructure:"signup_bonus"`  // 新用户初始余额(基准币种)，0表示不发放
+	Currencies   []CurrencyConfig `mapstructure:"currencies"`
+}
+
+// CurrencyConfig 币种配置，下注限额以该币种计
+type CurrencyConfig struct {
+	Code      string  `mapstructure:"code"`
+	Precision int32   `mapstructure:"precision"` // 小数位数 0-8
+	MinBet    float64 `mapstructure:"min_bet"`
+	MaxBet    float64 `mapstructure:"max_bet"`
+	Rate      float64 `mapstructure:"rate"` // 1单位折合基准币种数量，基准币种为1
+}
+
+// PaymentConfig 充值提现配置，金额限额以基准币种计
+type PaymentConfig struct {
+	Provider          string  `mapstructure:"provider"`            // fake
+	WebhookSecret     string  `mapstructure:"webhook_secret"`      // 回调签名密钥
+	WebhookTolerance  int     `mapstructure:"webhook_tolerance"`   // 秒，回调时间戳允许偏差
+	FakeCallbackURL   string  `mapstructure:"fake_callback_url"`   // 本地模拟支付回调地址，为空时不自动回调
+	FakeCallbackDelay int     `mapstructure:"fake_callback_delay"` // 秒
+	MinDeposit        float64 `mapstructure:"min_deposit"`
+	MaxDeposit        float64 `mapstructure:"max_deposit"`
+	MinWithdrawal     float64 `mapstructure:"min_withdrawal"`
+	MaxWithdrawal     float64 `mapstructure:"max_withdrawal"`
+	DailyWithdrawal   float64 `mapstructure:"daily_withdrawal"`   // 24小时内提现总额上限
+	AutoApproveBelow  float64 `mapstructure:"auto_approve_below"` // 低于该金额的提现自动审批，0表示全部人工审批
+}
+
+// ResponsibleConfig 负责任博彩配置
+type ResponsibleConfig struct {
+	CoolingPeriod        int `mapstructure:"cooling_period"`          // 小时，放宽限额的生效等待期，收紧立即生效
+	CoolOffMaxDays       int `mapstructure:"cool_off_max_days"`       // 冷静期最长天数
+	SelfExclusionMinDays int `mapstructure:"self_exclusion_min_days"` // 自我排除最短天数
+	SessionBreak         int `mapstructure:"session_break"`           // 分钟，两次下注间隔达到该时长时开始新的游戏会话
+}
+
+// WebSocketConfig WebSocket配置
+type WebSocketConfig struct {
+	EventBuffer      int `mapstructure:"event_buffer"`       // 保留的最近广播事件数，用于断线重连补发
+	ResumeWindow     int `mapstructure:"resume_window"`      // 秒，断线后会话保留时长，超时后重连发送完整快照
+	TimeSyncInterval int `mapstructure:"time_sync_interval"` // 秒，服务端发送时间同步ping测量RTT的间隔
+	KeyframeInterval int `mapstructure:"keyframe_interval"`  // 滴答数，两次完整GameStatusUpdate之间只发送GameTick
+	PresenceInterval int `mapstructure:"presence_interval"`  // 秒，向Redis同步本实例在线用户的间隔，3个间隔未同步的实例视为下线
+	OnlineListLimit  int `mapstructure:"online_list_limit"`  // 在线用户列表最多返回的用户数
+
+	// permessage-deflate压缩，客户端协商后启用
+	Compression          bool `mapstructure:"compression"`
+	CompressionLevel     int  `mapstructure:"compression_level"`     // flate压缩级别，-2到9
+	CompressionThreshold int  `mapstructure:"compression_threshold"` // 字节，小于该长度的消息不压缩
+}
+
+// ChatConfig 聊天配置
+type ChatConfig struct {
+	Rooms       []string `mapstructure:"rooms"`        // 聊天室列表
+	HistorySize int      `mapstructure:"history_size"` // 加入聊天室时下发的最近消息数
+	MaxLength   int      `mapstructure:"max_length"`   // 单条消息最大字符数
+	RateLimit   float64  `mapstructure:"rate_limit"`   // 每位玩家每秒可发送的消息数
+	RateBurst   int      `mapstructure:"rate_burst"`   // 突发消息数
+	BannedWords []string `mapstructure:"banned_words"` // 屏蔽词，命中时替换为*并标记待审核
+}
+
+// LeaderboardConfig 排行榜配置
+type LeaderboardConfig struct {
+	Size              int `mapstructure:"size"`               // 接口返回与快照保存的名次数
+	BroadcastSize     int `mapstructure:"broadcast_size"`     // 广播的名次数
+	BroadcastInterval int `mapstructure:"broadcast_interval"` // 秒，检查排行榜变化并广播的间隔
+	SnapshotInterval  int `mapstructure:"snapshot_interval"`  // 秒，排行榜快照写入MySQL的间隔
 }
 
 var AppConfig *Config
@@ -96,23 +205,23 @@ func LoadConfig(configPath string) error {
 
 	// 读取配置文件
 	if err := viper.ReadInConfig(); err != nil {
-		log.Printf("读取配置文件失败: %v", err)
+		logrus.WithError(err).Error("读取配置文件失败")
 		return err
 	}
 
 	// 解析配置
 	if err := viper.Unmarshal(&AppConfig); err != nil {
-		log.Printf("解析配置失败: %v", err)
+		logrus.WithError(err).Error("解析配置失败")
 		return err
 	}
 
 	// 验证配置
 	if err := validateConfig(); err != nil {
-		log.Printf("配置验证失败: %v", err)
+		logrus.WithError(err).Error("配置验证失败")
 		return err
 	}
 
-	log.Printf("配置加载成功: %s", configPath)
+	logrus.WithField("path", configPath).Info("配置加载成功")
 	return nil
 }
 
@@ -124,6 +233,8 @@ func setDefaults() {
 	viper.SetDefault("server.mode", "debug")
 	viper.SetDefault("server.read_timeout", 30)
 	viper.SetDefault("server.write_timeout", 30)
+	viper.SetDefault("server.drain_delay", 5)
+	viper.SetDefault("server.shutdown_timeout", 15)
 
 	// 数据库默认配置
 	viper.SetDefault("database.host", "localhost")
@@ -134,6 +245,8 @@ func setDefaults() {
 	viper.SetDefault("database.charset", "utf8mb4")
 	viper.SetDefault("database.max_idle", 10)
 	viper.SetDefault("database.max_open", 100)
+	viper.SetDefault("database.migrate_on_start", true)
+	viper.SetDefault("database.auto_migrate", false)
 
 	// Redis默认配置
 	viper.SetDefault("redis.host", "localhost")
@@ -148,8 +261,6 @@ func setDefaults() {
 	viper.SetDefault("jwt.issuer", "crash-game")
 
 	// 游戏默认配置
-	viper.SetDefault("game.min_bet_amount", 1.0)
-	viper.SetDefault("game.max_bet_amount", 1000.0)
 	viper.SetDefault("game.min_multiplier", 1.01)
 	viper.SetDefault("game.max_multiplier", 1000.0)
 	viper.SetDefault("game.round_duration", 30)
@@ -157,14 +268,87 @@ func setDefaults() {
 	viper.SetDefault("game.waiting_duration", 10)
 	viper.SetDefault("game.update_interval", 100)
 	viper.SetDefault("game.max_players_per_game", 1000)
+	viper.SetDefault("game.max_bets_per_round", 2)
+	viper.SetDefault("game.auto_bet_max_rounds", 1000)
+	viper.SetDefault("game.cashout_grace", 250)
+	viper.SetDefault("game.cashout_latency_cap", 100)
+	viper.SetDefault("game.max_win_per_bet", 100000.0)
+	viper.SetDefault("game.max_round_payout", 500000.0)
+	viper.SetDefault("game.max_round_exposure", 2000000.0)
 
 	// 日志默认配置
 	viper.SetDefault("log.level", "info")
 	viper.SetDefault("log.format", "json")
 	viper.SetDefault("log.output", "stdout")
+	viper.SetDefault("log.file_path", "logs/server.log")
 	viper.SetDefault("log.max_size", 100)
 	viper.SetDefault("log.max_backups", 3)
 	viper.SetDefault("log.max_age", 7)
+	viper.SetDefault("log.compress", false)
+
+	// 监控指标默认配置
+	viper.SetDefault("metrics.enabled", true)
+	viper.SetDefault("metrics.path", "/metrics")
+
+	// 链路追踪默认配置
+	viper.SetDefault("tracing.exporter", "none")
+	viper.SetDefault("tracing.endpoint", "localhost:4318")
+	viper.SetDefault("tracing.insecure", true)
+	viper.SetDefault("tracing.service_name", "crash-game-backend")
+	viper.SetDefault("tracing.sample_ratio", 1.0)
+
+	// 钱包默认配置
+	viper.SetDefault("wallet.base_currency", "CNY")
+	viper.SetDefault("wallet.signup_bonus", 0)
+	viper.SetDefault("wallet.currencies", []map[string]interface{}{
+		{"code": "CNY", "precision": 2, "min_bet": 1.0, "max_bet": 1000.0, "rate": 1.0},
+		{"code": "USD", "precision": 2, "min_bet": 0.2, "max_bet": 150.0, "rate": 7.2},
+		{"code": "BTC", "precision": 8, "min_bet": 0.00001, "max_bet": 0.02, "rate": 480000.0},
+	})
+
+	// 充值提现默认配置
+	viper.SetDefault("payment.provider", "fake")
+	viper.SetDefault("payment.webhook_secret", "")
+	viper.SetDefault("payment.webhook_tolerance", 300)
+	viper.SetDefault("payment.fake_callback_url", "")
+	viper.SetDefault("payment.fake_callback_delay", 3)
+	viper.SetDefault("payment.min_deposit", 10.0)
+	viper.SetDefault("payment.max_deposit", 50000.0)
+	viper.SetDefault("payment.min_withdrawal", 50.0)
+	viper.SetDefault("payment.max_withdrawal", 20000.0)
+	viper.SetDefault("payment.daily_withdrawal", 50000.0)
+	viper.SetDefault("payment.auto_approve_below", 0)
+
+	// 负责任博彩默认配置
+	viper.SetDefault("responsible.cooling_period", 24)
+	viper.SetDefault("responsible.cool_off_max_days", 42)
+	viper.SetDefault("responsible.self_exclusion_min_days", 180)
+	viper.SetDefault("responsible.session_break", 30)
+
+	// WebSocket默认配置
+	viper.SetDefault("websocket.event_buffer", 1024)
+	viper.SetDefault("websocket.resume_window", 120)
+	viper.SetDefault("websocket.time_sync_interval", 5)
+	viper.SetDefault("websocket.keyframe_interval", 10)
+	viper.SetDefault("websocket.presence_interval", 5)
+	viper.SetDefault("websocket.online_list_limit", 100)
+	viper.SetDefault("websocket.compression", true)
+	viper.SetDefault("websocket.compression_level", 1)
+	viper.SetDefault("websocket.compression_threshold", 256)
+
+	// 聊天默认配置
+	viper.SetDefault("chat.rooms", []string{"global"})
+	viper.SetDefault("chat.history_size", 50)
+	viper.SetDefault("chat.max_length", 200)
+	viper.SetDefault("chat.rate_limit", 0.5)
+	viper.SetDefault("chat.rate_burst", 3)
+	viper.SetDefault("chat.banned_words", []string{})
+
+	// 排行榜默认配置
+	viper.SetDefault("leaderboard.size", 100)
+	viper.SetDefault("leaderboard.broadcast_size", 10)
+	viper.SetDefault("leaderboard.broadcast_interval", 10)
+	viper.SetDefault("leaderboard.snapshot_interval", 300)
 }
 
 // validateConfig 验证配置
@@ -181,12 +365,79 @@ func validateConfig() error {
 		return fmt.Errorf("Redis端口无效: %d", AppConfig.Redis.Port)
 	}
 
-	if AppConfig.Game.MinBetAmount <= 0 {
-		return fmt.Errorf("最小下注金额必须大于0")
+	if err := validateWallet(AppConfig.Wallet); err != nil {
+		return err
 	}
 
-	if AppConfig.Game.MaxBetAmount <= AppConfig.Game.MinBetAmount {
-		return fmt.Errorf("最大下注金额必须大于最小下注金额")
+	if AppConfig.Wallet.SignupBonus < 0 {
+		return fmt.Errorf("新用户初始余额不能为负")
+	}
+
+	if AppConfig.Game.MaxBetsPerRound < 1 {
+		return fmt.Errorf("每局下注数上限无效: %d", AppConfig.Game.MaxBetsPerRound)
+	}
+
+	if AppConfig.Game.AutoBetMaxRounds < 1 {
+		return fmt.Errorf("自动下注最多轮数无效: %d", AppConfig.Game.AutoBetMaxRounds)
+	}
+
+	if AppConfig.Game.CashoutGrace < 0 || AppConfig.Game.CashoutGrace > 1000 {
+		return fmt.Errorf("止盈宽限期无效: %d", AppConfig.Game.CashoutGrace)
+	}
+
+	if AppConfig.Game.CashoutLatencyCap < 0 || AppConfig.Game.CashoutLatencyCap > AppConfig.Game.CashoutGrace {
+		return fmt.Errorf("止盈延迟补偿上限无效: %d，须在0到cashout_grace之间", AppConfig.Game.CashoutLatencyCap)
+	}
+
+	if AppConfig.Game.MaxWinPerBet < 0 || AppConfig.Game.MaxRoundPayout < 0 || AppConfig.Game.MaxRoundExposure < 0 {
+		return fmt.Errorf("单局风控限额不能为负数")
+	}
+
+	if AppConfig.Payment.WebhookSecret == "" {
+		return fmt.Errorf("支付回调签名密钥不能为空")
+	}
+
+	if AppConfig.Payment.MaxDeposit <= AppConfig.Payment.MinDeposit || AppConfig.Payment.MinDeposit <= 0 {
+		return fmt.Errorf("充值限额无效")
+	}
+
+	if AppConfig.Payment.MaxWithdrawal <= AppConfig.Payment.MinWithdrawal || AppConfig.Payment.MinWithdrawal <= 0 {
+		return fmt.Errorf("提现限额无效")
+	}
+
+	if AppConfig.Responsible.CoolingPeriod < 0 || AppConfig.Responsible.CoolOffMaxDays < 1 || AppConfig.Responsible.SelfExclusionMinDays < 1 ||
+		AppConfig.Responsible.SessionBreak < 1 {
+		return fmt.Errorf("负责任博彩配置无效")
+	}
+
+	if AppConfig.WebSocket.EventBuffer < 1 || AppConfig.WebSocket.ResumeWindow < 0 {
+		return fmt.Errorf("WebSocket事件缓冲配置无效")
+	}
+
+	if AppConfig.WebSocket.TimeSyncInterval < 1 {
+		return fmt.Errorf("时间同步间隔无效: %d", AppConfig.WebSocket.TimeSyncInterval)
+	}
+
+	if AppConfig.WebSocket.KeyframeInterval < 1 {
+		return fmt.Errorf("关键帧间隔无效: %d", AppConfig.WebSocket.KeyframeInterval)
+	}
+
+	if AppConfig.WebSocket.PresenceInterval < 1 || AppConfig.WebSocket.OnlineListLimit < 0 {
+		return fmt.Errorf("在线状态配置无效")
+	}
+
+	if AppConfig.WebSocket.CompressionLevel < -2 || AppConfig.WebSocket.CompressionLevel > 9 || AppConfig.WebSocket.CompressionThreshold < 0 {
+		return fmt.Errorf("WebSocket压缩配置无效")
+	}
+
+	if len(AppConfig.Chat.Rooms) == 0 || AppConfig.Chat.HistorySize < 0 || AppConfig.Chat.MaxLength < 1 ||
+		AppConfig.Chat.RateLimit <= 0 || AppConfig.Chat.RateBurst < 1 {
+		return fmt.Errorf("聊天配置无效")
+	}
+
+	if AppConfig.Leaderboard.Size < 1 || AppConfig.Leaderboard.BroadcastSize < 1 || AppConfig.Leaderboard.BroadcastSize > AppConfig.Leaderboard.Size ||
+		AppConfig.Leaderboard.BroadcastInterval < 1 || AppConfig.Leaderboard.SnapshotInterval < 1 {
+		return fmt.Errorf("排行榜配置无效")
 	}
 
 	if AppConfig.JWT.Secret == "" {
@@ -196,6 +447,46 @@ func validateConfig() error {
 	return nil
 }
 
+// validateWallet 验证币种配置
+func validateWallet(cfg WalletConfig) error {
+	if len(cfg.Currencies) == 0 {
+		return fmt.Errorf("至少需要配置一个币种")
+	}
+
+	seen := make(map[string]bool, len(cfg.Currencies))
+	for _, c := range cfg.Currencies {
+		if c.Code == "" {
+			return fmt.Errorf("币种代码不能为空")
+		}
+		if seen[c.Code] {
+			return fmt.Errorf("币种重复: %s", c.Code)
+		}
+		seen[c.Code] = true
+
+		if c.Precision < 0 || c.Precision > 8 {
+			return fmt.Errorf("币种 %s 精度必须在0-8之间", c.Code)
+		}
+		if c.MinBet <= 0 {
+			return fmt.Errorf("币种 %s 最小下注金额必须大于0", c.Code)
+		}
+		if c.MaxBet <= c.MinBet {
+			return fmt.Errorf("币种 %s 最大下注金额必须大于最小下注金额", c.Code)
+		}
+		if c.Rate <= 0 {
+			return fmt.Errorf("币种 %s 汇率必须大于0", c.Code)
+		}
+		if c.Code == cfg.BaseCurrency && c.Rate != 1 {
+			return fmt.Errorf("基准币种 %s 汇率必须为1", c.Code)
+		}
+	}
+
+	if !seen[cfg.BaseCurrency] {
+		return fmt.Errorf("基准币种 %s 不在币种列表中", cfg.BaseCurrency)
+	}
+
+	return nil
+}
+
 // GetDSN 获取数据库连接字符串
 func (c *DatabaseConfig) GetDSN() string {
 	return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=%s&parseTime=True&loc=Local",
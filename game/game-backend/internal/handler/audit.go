@@ -0,0 +1,184 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"game-backend/internal/middleware"
+	"game-backend/internal/model"
+	"game-backend/internal/service"
+)
+
+// AuditHandler 审计日志处理器
+type AuditHandler struct {
+	auditService *service.AuditService
+}
+
+// NewAuditHandler 创建审计日志处理器
+func NewAuditHandler(auditService *service.AuditService) *AuditHandler {
+	return &AuditHandler{
+		auditService: auditService,
+	}
+}
+
+// ListAuditLogs 查询审计日志（客服/管理员）
+func (h *AuditHandler) ListAuditLogs(c *gin.Context) {
+	// 获取分页参数
+	page, err := parsePageQuery(c, 50, 200)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	query := service.AuditQuery{
+		Action:     c.Query("action"),
+		TargetType: c.Query("target_type"),
+		TargetID:   c.Query("target_id"),
+		RequestID:  c.Query("request_id"),
+		PageQuery:  page,
+	}
+
+	if actorID, err := strconv.ParseUint(c.Query("actor_id"), 10, 64); err == nil {
+		query.ActorID = uint(actorID)
+	}
+
+	// 解析时间范围
+	if query.StartTime, err = parseTimeQuery(c, "start_time"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "start_time格式错误，应为RFC3339",
+		})
+		return
+	}
+	if query.EndTime, err = parseTimeQuery(c, "end_time"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "end_time格式错误，应为RFC3339",
+		})
+		return
+	}
+
+	logs, pagination, err := h.auditService.WithContext(c.Request.Context()).Query(query)
+	if errors.Is(err, service.ErrInvalidCursor) || errors.Is(err, service.ErrInvalidSort) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "查询审计日志失败: " + err.Error(),
+		})
+		return
+	}
+
+	// 查询审计日志本身也是需要留痕的管理操作
+	if err := h.auditService.WithContext(c.Request.Context()).Record(auditMeta(c), service.AuditEvent{
+		Action:     model.AuditActionAdminQuery,
+		TargetType: "audit_log",
+		Reason:     c.Request.URL.RawQuery,
+	}); err != nil {
+		middleware.Logger(c).WithError(err).WithField("action", model.AuditActionAdminQuery).Error("记录审计日志失败")
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取成功",
+		"data": gin.H{
+			"logs":       logs,
+			"pagination": pagination,
+		},
+	})
+}
+
+// VerifyAuditChain 校验审计日志哈希链
+func (h *AuditHandler) VerifyAuditChain(c *gin.Context) {
+	fromID, _ := strconv.ParseUint(c.DefaultQuery("from_id", "1"), 10, 64)
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10000"))
+
+	if fromID < 1 {
+		fromID = 1
+	}
+	if limit < 1 || limit > 100000 {
+		limit = 10000
+	}
+
+	result, err := h.auditService.WithContext(c.Request.Context()).VerifyChain(fromID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "校验审计日志失败: " + err.Error(),
+		})
+		return
+	}
+
+	if err := h.auditService.WithContext(c.Request.Context()).Record(auditMeta(c), service.AuditEvent{
+		Action:     model.AuditActionAdminVerify,
+		TargetType: "audit_log",
+		After:      result,
+	}); err != nil {
+		middleware.Logger(c).WithError(err).WithField("action", model.AuditActionAdminVerify).Error("记录审计日志失败")
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "校验完成",
+		"data":    result,
+	})
+}
+
+// auditMeta 从请求上下文构建审计信息
+func auditMeta(c *gin.Context) service.AuditMeta {
+	userID, _ := middleware.GetUserID(c)
+	role, _ := middleware.GetRole(c)
+
+	actorType := model.AuditActorUser
+	if role == model.RoleAdmin || role == model.RoleSupport {
+		actorType = model.AuditActorAdmin
+	}
+
+	return service.AuditMeta{
+		ActorID:   userID,
+		ActorType: actorType,
+		RequestID: middleware.GetRequestID(c),
+		ClientIP:  c.ClientIP(),
+	}
+}
+
+// parseTimeQuery 解析RFC3339格式的时间查询参数
+func parseTimeQuery(c *gin.Context, key string) (*time.Time, error) {
+	value := c.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// parseDateQuery 解析YYYY-MM-DD格式的日期查询参数（服务器时区），未提供时返回nil
+func parseDateQuery(c *gin.Context, key string) (*time.Time, error) {
+	value := c.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
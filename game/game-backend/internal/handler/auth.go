@@ -1,8 +1,9 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
-	"time"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"game-backend/internal/middleware"
@@ -12,13 +13,15 @@ import (
 
 // AuthHandler 认证处理器
 type AuthHandler struct {
-	authService *service.AuthService
+	authService  *service.AuthService
+	auditService *service.AuditService
 }
 
 // NewAuthHandler 创建认证处理器
-func NewAuthHandler(authService *service.AuthService) *AuthHandler {
+func NewAuthHandler(authService *service.AuthService, auditService *service.AuditService) *AuthHandler {
 	return &AuthHandler{
-		authService: authService,
+		authService:  authService,
+		auditService: auditService,
 	}
 }
 
@@ -37,11 +40,11 @@ type RegisterRequest struct {
 
 // LoginResponse 登录响应结构
 type LoginResponse struct {
-	Token    string      `json:"token"`
-	UserID   uint        `json:"user_id"`
-	Username string      `json:"username"`
-	Balance  float64     `json:"balance"`
-	User     *model.User `json:"user"`
+	Token    string         `json:"token"`
+	UserID   uint           `json:"user_id"`
+	Username string         `json:"username"`
+	Wallets  []model.Wallet `json:"wallets"`
+	User     *model.User    `json:"user"`
 }
 
 // Login 用户登录
@@ -56,8 +59,21 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	}
 
 	// 验证用户凭据
-	user, err := h.authService.ValidateUser(req.Username, req.Password)
+	user, err := h.authService.WithContext(c.Request.Context()).ValidateUser(req.Username, req.Password)
 	if err != nil {
+		h.recordAudit(c, service.AuditEvent{
+			Action:     model.AuditActionUserLoginFail,
+			TargetType: "user",
+			TargetID:   req.Username,
+			Reason:     err.Error(),
+		})
+		if errors.Is(err, service.ErrSelfExcluded) || errors.Is(err, service.ErrCoolOffActive) || errors.Is(err, service.ErrSessionLimitReached) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"code":    403,
+				"message": err.Error(),
+			})
+			return
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"code":    401,
 			"message": "用户名或密码错误",
@@ -66,7 +82,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	}
 
 	// 生成JWT Token
-	token, err := middleware.GenerateToken(user.ID, user.Username)
+	token, err := middleware.GenerateToken(user.ID, user.Username, user.Role)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
@@ -76,7 +92,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	}
 
 	// 保存用户会话
-	err = h.authService.SaveUserSession(user.ID, token)
+	err = h.authService.WithContext(c.Request.Context()).SaveUserSession(user.ID, token)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
@@ -85,11 +101,19 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	meta := auditMeta(c)
+	meta.ActorID = user.ID
+	h.recordAuditWithMeta(c, meta, service.AuditEvent{
+		Action:     model.AuditActionUserLogin,
+		TargetType: "user",
+		TargetID:   strconv.FormatUint(uint64(user.ID), 10),
+	})
+
 	response := LoginResponse{
 		Token:    token,
 		UserID:   user.ID,
 		Username: user.Username,
-		Balance:  user.Balance,
+		Wallets:  user.Wallets,
 		User:     user,
 	}
 
@@ -112,7 +136,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	}
 
 	// 检查用户名是否已存在
-	exists, err := h.authService.CheckUsernameExists(req.Username)
+	exists, err := h.authService.WithContext(c.Request.Context()).CheckUsernameExists(req.Username)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
@@ -130,7 +154,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	}
 
 	// 检查邮箱是否已存在
-	exists, err = h.authService.CheckEmailExists(req.Email)
+	exists, err = h.authService.WithContext(c.Request.Context()).CheckEmailExists(req.Email)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
@@ -148,7 +172,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	}
 
 	// 创建用户
-	user, err := h.authService.CreateUser(req.Username, req.Password, req.Email)
+	user, err := h.authService.WithContext(c.Request.Context()).CreateUser(req.Username, req.Password, req.Email)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
@@ -157,6 +181,18 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
+	meta := auditMeta(c)
+	meta.ActorID = user.ID
+	h.recordAuditWithMeta(c, meta, service.AuditEvent{
+		Action:     model.AuditActionUserRegister,
+		TargetType: "user",
+		TargetID:   strconv.FormatUint(uint64(user.ID), 10),
+		After: gin.H{
+			"username": user.Username,
+			"email":    user.Email,
+		},
+	})
+
 	c.JSON(http.StatusCreated, gin.H{
 		"code":    201,
 		"message": "注册成功",
@@ -180,7 +216,7 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 	}
 
 	// 删除用户会话
-	err := h.authService.DeleteUserSession(userID)
+	err := h.authService.WithContext(c.Request.Context()).DeleteUserSession(userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
@@ -189,6 +225,12 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 		return
 	}
 
+	h.recordAudit(c, service.AuditEvent{
+		Action:     model.AuditActionUserLogout,
+		TargetType: "user",
+		TargetID:   strconv.FormatUint(uint64(userID), 10),
+	})
+
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
 		"message": "登出成功",
@@ -206,7 +248,7 @@ func (h *AuthHandler) GetProfile(c *gin.Context) {
 		return
 	}
 
-	user, err := h.authService.GetUserByID(userID)
+	user, err := h.authService.WithContext(c.Request.Context()).GetUserByID(userID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"code":    404,
@@ -246,8 +288,18 @@ func (h *AuthHandler) UpdateProfile(c *gin.Context) {
 		return
 	}
 
+	// 获取更新前的信息用于审计
+	before, err := h.authService.WithContext(c.Request.Context()).GetUserByID(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code":    404,
+			"message": "用户不存在",
+		})
+		return
+	}
+
 	// 更新用户信息
-	err := h.authService.UpdateUser(userID, req.Email, req.Avatar)
+	err = h.authService.WithContext(c.Request.Context()).UpdateUser(userID, req.Email, req.Avatar)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
@@ -256,6 +308,22 @@ func (h *AuthHandler) UpdateProfile(c *gin.Context) {
 		return
 	}
 
+	after := gin.H{"email": before.Email, "avatar": before.Avatar}
+	if req.Email != "" {
+		after["email"] = req.Email
+	}
+	if req.Avatar != "" {
+		after["avatar"] = req.Avatar
+	}
+
+	h.recordAudit(c, service.AuditEvent{
+		Action:     model.AuditActionProfileUpdate,
+		TargetType: "user",
+		TargetID:   strconv.FormatUint(uint64(userID), 10),
+		Before:     gin.H{"email": before.Email, "avatar": before.Avatar},
+		After:      after,
+	})
+
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
 		"message": "更新成功",
@@ -282,8 +350,10 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
+	role, _ := middleware.GetRole(c)
+
 	// 生成新的Token
-	newToken, err := middleware.GenerateToken(userID, username)
+	newToken, err := middleware.GenerateToken(userID, username, role)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
@@ -293,7 +363,7 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	}
 
 	// 更新用户会话
-	err = h.authService.UpdateUserSession(userID, newToken)
+	err = h.authService.WithContext(c.Request.Context()).UpdateUserSession(userID, newToken)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
@@ -310,3 +380,15 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 		},
 	})
 }
+
+// recordAudit 记录审计事件，失败时仅记录日志不影响主流程
+func (h *AuthHandler) recordAudit(c *gin.Context, event service.AuditEvent) {
+	h.recordAuditWithMeta(c, auditMeta(c), event)
+}
+
+// recordAuditWithMeta 使用指定审计信息记录审计事件
+func (h *AuthHandler) recordAuditWithMeta(c *gin.Context, meta service.AuditMeta, event service.AuditEvent) {
+	if err := h.auditService.WithContext(c.Request.Context()).Record(meta, event); err != nil {
+		middleware.Logger(c).WithError(err).WithField("action", event.Action).Error("记录审计日志失败")
+	}
+}
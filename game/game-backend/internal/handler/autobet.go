@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"game-backend/internal/middleware"
+	"game-backend/internal/service"
+	"game-backend/internal/websocket"
+)
+
+// AutoBetHandler 自动下注处理器
+type AutoBetHandler struct {
+	autoBetService *service.AutoBetService
+	wsHub          *websocket.Hub
+}
+
+// NewAutoBetHandler 创建自动下注处理器
+func NewAutoBetHandler(autoBetService *service.AutoBetService, wsHub *websocket.Hub) *AutoBetHandler {
+	return &AutoBetHandler{
+		autoBetService: autoBetService,
+		wsHub:          wsHub,
+	}
+}
+
+// GetAutoBet 获取进行中或最近一次的自动下注
+func (h *AutoBetHandler) GetAutoBet(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"code":    401,
+			"message": "未登录",
+		})
+		return
+	}
+
+	session, err := h.autoBetService.WithContext(c.Request.Context()).Current(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"code":    404,
+				"message": "没有自动下注记录",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "获取自动下注失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取成功",
+		"data":    session,
+	})
+}
+
+// StartAutoBet 开启自动下注，从下一局下注阶段开始执行
+func (h *AutoBetHandler) StartAutoBet(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"code":    401,
+			"message": "未登录",
+		})
+		return
+	}
+
+	var req service.AutoBetParams
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	svc := h.autoBetService.WithContext(c.Request.Context())
+	if err := svc.Validate(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	session, err := svc.Start(userID, req, auditMeta(c))
+	if err != nil {
+		if errors.Is(err, service.ErrAutoBetRunning) {
+			c.JSON(http.StatusConflict, gin.H{
+				"code":    409,
+				"message": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "开启自动下注失败: " + err.Error(),
+		})
+		return
+	}
+
+	// 同步推送到该玩家的WebSocket连接
+	h.wsHub.SendAutoBetStatus(session)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "自动下注已开启",
+		"data":    session,
+	})
+}
+
+// StopAutoBet 停止进行中的自动下注，已下注的本局照常结算
+func (h *AutoBetHandler) StopAutoBet(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"code":    401,
+			"message": "未登录",
+		})
+		return
+	}
+
+	session, err := h.autoBetService.WithContext(c.Request.Context()).Stop(userID, auditMeta(c))
+	if err != nil {
+		if errors.Is(err, service.ErrAutoBetNotRunning) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"code":    404,
+				"message": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "停止自动下注失败: " + err.Error(),
+		})
+		return
+	}
+
+	h.wsHub.SendAutoBetStatus(session)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "自动下注已停止",
+		"data":    session,
+	})
+}
@@ -0,0 +1,231 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"game-backend/internal/middleware"
+	"game-backend/internal/model"
+	"game-backend/internal/service"
+	"game-backend/internal/websocket"
+)
+
+// ChatHandler 聊天审核处理器
+type ChatHandler struct {
+	chatService  *service.ChatService
+	auditService *service.AuditService
+	wsHub        *websocket.Hub
+}
+
+// NewChatHandler 创建聊天审核处理器
+func NewChatHandler(chatService *service.ChatService, auditService *service.AuditService, wsHub *websocket.Hub) *ChatHandler {
+	return &ChatHandler{
+		chatService:  chatService,
+		auditService: auditService,
+		wsHub:        wsHub,
+	}
+}
+
+// ListMessages 查询聊天消息（客服/管理员），flagged=true仅查询命中屏蔽词的消息
+func (h *ChatHandler) ListMessages(c *gin.Context) {
+	page, err := parsePageQuery(c, 50, 200)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	query := service.ChatMessageQuery{
+		Room:      c.Query("room"),
+		PageQuery: page,
+	}
+	if userID, err := strconv.ParseUint(c.Query("user_id"), 10, 64); err == nil {
+		query.UserID = uint(userID)
+	}
+	if flagged, err := strconv.ParseBool(c.Query("flagged")); err == nil {
+		query.Flagged = &flagged
+	}
+
+	if query.StartTime, err = parseTimeQuery(c, "start_time"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "start_time格式错误，应为RFC3339",
+		})
+		return
+	}
+	if query.EndTime, err = parseTimeQuery(c, "end_time"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "end_time格式错误，应为RFC3339",
+		})
+		return
+	}
+
+	messages, pagination, err := h.chatService.WithContext(c.Request.Context()).Messages(query)
+	if errors.Is(err, service.ErrInvalidCursor) || errors.Is(err, service.ErrInvalidSort) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "查询聊天消息失败: " + err.Error(),
+		})
+		return
+	}
+
+	// 审核消息可以看到屏蔽前的原始内容，查询需要留痕
+	if err := h.auditService.WithContext(c.Request.Context()).Record(auditMeta(c), service.AuditEvent{
+		Action:     model.AuditActionAdminQuery,
+		TargetType: "chat_message",
+		Reason:     c.Request.URL.RawQuery,
+	}); err != nil {
+		middleware.Logger(c).WithError(err).WithField("action", model.AuditActionAdminQuery).Error("记录审计日志失败")
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取成功",
+		"data": gin.H{
+			"messages":   messages,
+			"pagination": pagination,
+		},
+	})
+}
+
+// ListSanctions 查询禁言/封禁记录（客服/管理员），active=true仅查询仍然有效的处罚
+func (h *ChatHandler) ListSanctions(c *gin.Context) {
+	page, err := parsePageQuery(c, 50, 200)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	query := service.ChatSanctionQuery{
+		PageQuery: page,
+	}
+	if userID, err := strconv.ParseUint(c.Query("user_id"), 10, 64); err == nil {
+		query.UserID = uint(userID)
+	}
+	query.Active, _ = strconv.ParseBool(c.Query("active"))
+
+	sanctions, pagination, err := h.chatService.WithContext(c.Request.Context()).Sanctions(query)
+	if errors.Is(err, service.ErrInvalidCursor) || errors.Is(err, service.ErrInvalidSort) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "查询禁言/封禁记录失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取成功",
+		"data": gin.H{
+			"sanctions":  sanctions,
+			"pagination": pagination,
+		},
+	})
+}
+
+// CreateSanction 禁言或封禁玩家，并通知其在线连接
+func (h *ChatHandler) CreateSanction(c *gin.Context) {
+	var req service.ChatSanctionParams
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	sanction, err := h.chatService.WithContext(c.Request.Context()).Sanction(auditMeta(c), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidChatSanction):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": err.Error(),
+			})
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{
+				"code":    404,
+				"message": "用户不存在",
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    500,
+				"message": "处罚失败: " + err.Error(),
+			})
+		}
+		return
+	}
+
+	h.wsHub.ApplyChatSanction(sanction)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "处罚成功",
+		"data":    sanction,
+	})
+}
+
+// LiftSanction 提前解除禁言/封禁
+func (h *ChatHandler) LiftSanction(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "处罚ID无效",
+		})
+		return
+	}
+
+	sanction, err := h.chatService.WithContext(c.Request.Context()).Lift(auditMeta(c), uint(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{
+				"code":    404,
+				"message": "处罚记录不存在",
+			})
+		case errors.Is(err, service.ErrChatSanctionNotActive):
+			c.JSON(http.StatusConflict, gin.H{
+				"code":    409,
+				"message": err.Error(),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    500,
+				"message": "解除处罚失败: " + err.Error(),
+			})
+		}
+		return
+	}
+
+	h.wsHub.LiftChatSanction(sanction)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "已解除",
+		"data":    sanction,
+	})
+}
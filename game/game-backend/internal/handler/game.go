@@ -1,37 +1,55 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"game-backend/config"
 	"game-backend/internal/middleware"
+	"game-backend/internal/model"
 	"game-backend/internal/service"
 	"game-backend/internal/websocket"
+	"game-backend/pkg/money"
 )
 
 // GameHandler 游戏处理器
 type GameHandler struct {
-	gameService *service.GameService
-	wsHub       *websocket.Hub
+	gameService        *service.GameService
+	leaderboardService *service.LeaderboardService
+	wsHub              *websocket.Hub
 }
 
 // NewGameHandler 创建游戏处理器
-func NewGameHandler(gameService *service.GameService, wsHub *websocket.Hub) *GameHandler {
+func NewGameHandler(gameService *service.GameService, leaderboardService *service.LeaderboardService, wsHub *websocket.Hub) *GameHandler {
 	return &GameHandler{
-		gameService: gameService,
-		wsHub:       wsHub,
+		gameService:        gameService,
+		leaderboardService: leaderboardService,
+		wsHub:              wsHub,
 	}
 }
 
-// BetRequest 下注请求结构
+// BetRequest 下注请求结构，金额与倍数接受数字或字符串，币种为空时使用基准币种，下注位为空时自动分配
 type BetRequest struct {
-	Amount      float64 `json:"amount" binding:"required,min=1"`
-	AutoCashout float64 `json:"auto_cashout" binding:"omitempty,min=1.01"`
+	Slot        *int            `json:"slot"`
+	Currency    string          `json:"currency"`
+	Amount      decimal.Decimal `json:"amount"`
+	AutoCashout decimal.Decimal `json:"auto_cashout"`
 }
 
-// CashoutRequest 止盈请求结构
+// CashoutRequest 止盈请求结构，fraction为剩余本金的止盈比例，为空时全部止盈
 type CashoutRequest struct {
+	BetID    string           `json:"bet_id" binding:"required"`
+	Fraction *decimal.Decimal `json:"fraction"`
+}
+
+// CancelBetRequest 撤销下注请求结构
+type CancelBetRequest struct {
 	BetID string `json:"bet_id" binding:"required"`
 }
 
@@ -44,15 +62,37 @@ func (h *GameHandler) GetGameStatus(c *gin.Context) {
 		"message": "获取成功",
 		"data": gin.H{
 			"game_id":            gameState.GameID,
+			"round_id":           gameState.RoundID,
 			"status":             gameState.Status,
 			"current_multiplier": gameState.CurrentMultiplier,
 			"players_count":      gameState.PlayersCount,
+			"online_count":       gameState.OnlineCount,
+			"spectators_count":   gameState.SpectatorsCount,
 			"next_round_in":      gameState.NextRoundIn,
 			"server_time":        gameState.LastUpdate,
+			"server_time_ms":     gameState.LastUpdateMs,
 		},
 	})
 }
 
+// GetCurrentRound 获取本局下注列表快照，与WebSocket RoundSnapshot消息相同
+func (h *GameHandler) GetCurrentRound(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取成功",
+		"data":    h.wsHub.RoundSnapshot(),
+	})
+}
+
+// GetOnlineUsers 获取在线用户列表，与WebSocket OnlineUsers消息相同
+func (h *GameHandler) GetOnlineUsers(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取成功",
+		"data":    h.wsHub.OnlineUsers(),
+	})
+}
+
 // PlaceBet 下注
 func (h *GameHandler) PlaceBet(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
@@ -73,69 +113,137 @@ func (h *GameHandler) PlaceBet(c *gin.Context) {
 		return
 	}
 
-	// 验证下注金额
-	if req.Amount < h.gameService.GetMinBetAmount() {
+	// 验证下注币种、金额与自动止盈倍数
+	if err := h.gameService.ValidateBet(req.Currency, req.Amount, req.AutoCashout); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"code":    400,
-			"message": "下注金额不能小于最小限制",
+			"message": err.Error(),
 		})
 		return
 	}
 
-	if req.Amount > h.gameService.GetMaxBetAmount() {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    400,
-			"message": "下注金额不能大于最大限制",
-		})
-		return
+	slot := websocket.AutoSlot
+	if req.Slot != nil {
+		slot = *req.Slot
 	}
 
-	// 检查用户余额
-	user, err := h.gameService.GetUserByID(userID)
+	// 分配下注位并经单局风控预占敞口后创建下注并扣除余额
+	bet, err := h.wsHub.PlaceBet(c.Request.Context(), userID, slot, req.Currency, req.Amount, req.AutoCashout, auditMeta(c))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"code":    404,
-			"message": "用户不存在",
-		})
+		switch {
+		case errors.Is(err, service.ErrInsufficientBalance):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": "余额不足",
+			})
+		case errors.Is(err, websocket.ErrRoundExposureExceeded), errors.Is(err, websocket.ErrInvalidBetSlot),
+			errors.Is(err, service.ErrWalletInDebt), errors.Is(err, service.ErrWagerLimitExceeded), errors.Is(err, service.ErrLossLimitExceeded):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": err.Error(),
+			})
+		case errors.Is(err, service.ErrSelfExcluded), errors.Is(err, service.ErrCoolOffActive),
+			errors.Is(err, service.ErrSessionLimitReached):
+			c.JSON(http.StatusForbidden, gin.H{
+				"code":    403,
+				"message": err.Error(),
+			})
+		case errors.Is(err, websocket.ErrBettingClosed), errors.Is(err, websocket.ErrBetSlotsFull), errors.Is(err, websocket.ErrBetSlotTaken):
+			c.JSON(http.StatusConflict, gin.H{
+				"code":    409,
+				"message": err.Error(),
+			})
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{
+				"code":    404,
+				"message": "用户不存在",
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    500,
+				"message": "下注失败: " + err.Error(),
+			})
+		}
 		return
 	}
 
-	if user.Balance < req.Amount {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    400,
-			"message": "余额不足",
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "下注成功",
+		"data": gin.H{
+			"bet_id":       bet.BetID,
+			"round_id":     bet.RoundID,
+			"slot":         bet.Slot,
+			"currency":     bet.Currency,
+			"amount":       bet.Amount,
+			"auto_cashout": bet.AutoCashout,
+			"status":       bet.Status,
+		},
+	})
+}
+
+// CancelBet 撤销下注，仅在本局开始前可用，全额退回本金
+func (h *GameHandler) CancelBet(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"code":    401,
+			"message": "未登录",
 		})
 		return
 	}
 
-	// 创建下注记录
-	bet, err := h.gameService.CreateBet(userID, req.Amount, req.AutoCashout)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":    500,
-			"message": "下注失败: " + err.Error(),
+	var req CancelBetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误: " + err.Error(),
 		})
 		return
 	}
 
-	// 扣除用户余额
-	err = h.gameService.DeductUserBalance(userID, req.Amount)
+	bet, err := h.wsHub.CancelBet(c.Request.Context(), userID, req.BetID, auditMeta(c))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":    500,
-			"message": "扣除余额失败: " + err.Error(),
-		})
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{
+				"code":    404,
+				"message": "下注记录不存在",
+			})
+		case errors.Is(err, service.ErrBetNotOwned):
+			c.JSON(http.StatusForbidden, gin.H{
+				"code":    403,
+				"message": "无权操作此下注",
+			})
+		case errors.Is(err, websocket.ErrBettingClosed):
+			c.JSON(http.StatusConflict, gin.H{
+				"code":    409,
+				"message": err.Error(),
+			})
+		case errors.Is(err, service.ErrBetNotActive):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": "下注已处理",
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    500,
+				"message": "撤销下注失败: " + err.Error(),
+			})
+		}
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
-		"message": "下注成功",
+		"message": "撤销成功",
 		"data": gin.H{
-			"bet_id":       bet.BetID,
-			"amount":       bet.Amount,
-			"auto_cashout": bet.AutoCashout,
-			"status":       bet.Status,
+			"bet_id":   bet.BetID,
+			"round_id": bet.RoundID,
+			"slot":     bet.Slot,
+			"currency": bet.Currency,
+			"amount":   bet.Amount,
+			"status":   bet.Status,
 		},
 	})
 }
@@ -160,108 +268,278 @@ func (h *GameHandler) Cashout(c *gin.Context) {
 		return
 	}
 
-	// 获取下注记录
-	bet, err := h.gameService.GetBetByID(req.BetID)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"code":    404,
-			"message": "下注记录不存在",
+	fraction := decimal.NewFromInt(1)
+	if req.Fraction != nil {
+		fraction = *req.Fraction
+	}
+	if err := h.gameService.ValidateCashoutFraction(fraction); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
 		})
 		return
 	}
 
-	// 检查下注是否属于当前用户
-	if bet.UserID != userID {
-		c.JSON(http.StatusForbidden, gin.H{
-			"code":    403,
-			"message": "无权操作此下注",
+	// 按当前倍数结算下注并增加余额，REST请求没有测得的延迟，不做延迟补偿
+	bet, settlement, err := h.wsHub.Cashout(c.Request.Context(), userID, req.BetID, fraction, 0, time.Time{}, auditMeta(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, websocket.ErrGameNotRunning):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": "游戏未进行中",
+			})
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{
+				"code":    404,
+				"message": "下注记录不存在",
+			})
+		case errors.Is(err, service.ErrBetNotOwned):
+			c.JSON(http.StatusForbidden, gin.H{
+				"code":    403,
+				"message": "无权操作此下注",
+			})
+		case errors.Is(err, service.ErrBetNotActive):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": "下注已处理",
+			})
+		case errors.Is(err, service.ErrCashoutTooSmall):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": err.Error(),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    500,
+				"message": "止盈失败: " + err.Error(),
+			})
+		}
+		return
+	}
+
+	// 本次结算的盈利，部分止盈时只计算本次结算的本金
+	profit := settlement.Payout.Sub(settlement.Stake)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "止盈成功",
+		"data": gin.H{
+			"bet_id":           bet.BetID,
+			"slot":             bet.Slot,
+			"currency":         bet.Currency,
+			"stake":            settlement.Stake,
+			"multiplier":       settlement.Multiplier,
+			"payout":           settlement.Payout,
+			"profit":           profit,
+			"remaining_amount": bet.RemainingAmount,
+			"total_payout":     bet.Payout,
+			"status":           bet.Status,
+		},
+	})
+}
+
+// GetBetHistory 获取下注历史，按游标分页，支持按币种、轮次、状态、下注时间、金额与倍数范围过滤
+func (h *GameHandler) GetBetHistory(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"code":    401,
+			"message": "未登录",
 		})
 		return
 	}
 
-	// 检查下注状态
-	if bet.Status != 0 {
+	page, err := parsePageQuery(c, 20, 100)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"code":    400,
-			"message": "下注已处理",
+			"message": err.Error(),
 		})
 		return
 	}
 
-	// 获取当前游戏状态
-	gameState := h.wsHub.GetGameState()
-	if gameState.Status != 1 {
+	query := service.BetHistoryQuery{
+		PageQuery: page,
+		Currency:  c.Query("currency"),
+		RoundID:   c.Query("round_id"),
+	}
+	if query.HistoryFilter, err = parseHistoryFilter(c); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"code":    400,
-			"message": "游戏未进行中",
+			"message": err.Error(),
 		})
 		return
 	}
+	if query.Statuses, err = parseStatusQuery(c, "status"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	// 获取下注历史
+	bets, pagination, err := h.gameService.WithContext(c.Request.Context()).GetUserBetHistory(userID, query)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidCursor), errors.Is(err, service.ErrInvalidSort), errors.Is(err, money.ErrUnsupportedCurrency):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": err.Error(),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    500,
+				"message": "获取历史失败: " + err.Error(),
+			})
+		}
+		return
+	}
 
-	// 计算赔付
-	payout := bet.Amount * gameState.CurrentMultiplier
-	profit := payout - bet.Amount
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取成功",
+		"data": gin.H{
+			"bets":       bets,
+			"pagination": pagination,
+		},
+	})
+}
 
-	// 更新下注记录
-	err = h.gameService.UpdateBetCashout(req.BetID, gameState.CurrentMultiplier, payout)
+// GetGameHistory 获取游戏历史，按游标分页，支持按轮次、时间、总下注额与崩盘倍数范围过滤
+func (h *GameHandler) GetGameHistory(c *gin.Context) {
+	page, err := parsePageQuery(c, 50, 100)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":    500,
-			"message": "止盈失败: " + err.Error(),
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
 		})
 		return
 	}
 
-	// 增加用户余额
-	err = h.gameService.AddUserBalance(userID, payout)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":    500,
-			"message": "增加余额失败: " + err.Error(),
+	query := service.GameHistoryQuery{
+		PageQuery: page,
+		RoundID:   c.Query("round_id"),
+	}
+	if query.HistoryFilter, err = parseHistoryFilter(c); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
 		})
 		return
 	}
 
+	// 获取游戏历史
+	games, pagination, err := h.gameService.WithContext(c.Request.Context()).GetGameHistory(query)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidCursor), errors.Is(err, service.ErrInvalidSort):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": err.Error(),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    500,
+				"message": "获取历史失败: " + err.Error(),
+			})
+		}
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
-		"message": "止盈成功",
+		"message": "获取成功",
 		"data": gin.H{
-			"bet_id":     bet.BetID,
-			"multiplier": gameState.CurrentMultiplier,
-			"payout":     payout,
-			"profit":     profit,
+			"games":      games,
+			"pagination": pagination,
 		},
 	})
 }
 
-// GetBetHistory 获取下注历史
-func (h *GameHandler) GetBetHistory(c *gin.Context) {
-	userID, exists := middleware.GetUserID(c)
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"code":    401,
-			"message": "未登录",
+// parseHistoryFilter 解析历史查询共用的时间（start_time/end_time，RFC3339）、金额（min_amount/max_amount）与倍数（min_multiplier/max_multiplier）范围
+func parseHistoryFilter(c *gin.Context) (service.HistoryFilter, error) {
+	var filter service.HistoryFilter
+	var err error
+	if filter.StartTime, err = parseTimeQuery(c, "start_time"); err != nil {
+		return filter, errors.New("start_time格式错误，应为RFC3339")
+	}
+	if filter.EndTime, err = parseTimeQuery(c, "end_time"); err != nil {
+		return filter, errors.New("end_time格式错误，应为RFC3339")
+	}
+	if filter.MinAmount, err = parseDecimalQuery(c, "min_amount"); err != nil {
+		return filter, err
+	}
+	if filter.MaxAmount, err = parseDecimalQuery(c, "max_amount"); err != nil {
+		return filter, err
+	}
+	if filter.MinMultiplier, err = parseDecimalQuery(c, "min_multiplier"); err != nil {
+		return filter, err
+	}
+	if filter.MaxMultiplier, err = parseDecimalQuery(c, "max_multiplier"); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+// GetHistoryAnalytics 获取最近limit局（默认100，最多1000）的崩盘倍数统计，since为逗号分隔的倍数，默认2,10,100
+func (h *GameHandler) GetHistoryAnalytics(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if limit < 1 || limit > 1000 {
+		limit = 100
+	}
+
+	var thresholds []decimal.Decimal
+	for _, value := range strings.Split(c.DefaultQuery("since", "2,10,100"), ",") {
+		threshold, err := decimal.NewFromString(strings.TrimSpace(value))
+		if err != nil || threshold.LessThan(decimal.NewFromInt(1)) || money.ValidateMultiplier(threshold) != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": "since须为逗号分隔的倍数，不小于1且最多两位小数",
+			})
+			return
+		}
+		thresholds = append(thresholds, threshold)
+	}
+	if len(thresholds) > 10 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "since最多10个倍数",
 		})
 		return
 	}
 
-	// 获取分页参数
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
-	
-	if page < 1 {
-		page = 1
-	}
-	if pageSize < 1 || pageSize > 100 {
-		pageSize = 20
+	analytics, err := h.gameService.WithContext(c.Request.Context()).GetRoundAnalytics(limit, thresholds)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "获取历史统计失败: " + err.Error(),
+		})
+		return
 	}
 
-	// 获取下注历史
-	bets, total, err := h.gameService.GetUserBetHistory(userID, page, pageSize)
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取成功",
+		"data":    analytics,
+	})
+}
+
+// GetRoundDetail 获取一局的详情，包括所有下注与结算明细
+func (h *GameHandler) GetRoundDetail(c *gin.Context) {
+	detail, err := h.gameService.WithContext(c.Request.Context()).GetRoundDetail(c.Param("round_id"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code":    404,
+			"message": "该局不存在或尚未结束",
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
-			"message": "获取历史失败: " + err.Error(),
+			"message": "获取该局详情失败: " + err.Error(),
 		})
 		return
 	}
@@ -269,34 +547,88 @@ func (h *GameHandler) GetBetHistory(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
 		"message": "获取成功",
-		"data": gin.H{
-			"bets":      bets,
-			"total":     total,
-			"page":      page,
-			"page_size": pageSize,
-		},
+		"data":    detail,
 	})
 }
 
-// GetGameHistory 获取游戏历史
-func (h *GameHandler) GetGameHistory(c *gin.Context) {
-	// 获取分页参数
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "50"))
-	
-	if page < 1 {
-		page = 1
+// GetLeaderboard 获取排行榜：period为daily/weekly/all，metric为profit/multiplier，
+// bucket为已结束的周期时返回该周期的快照；指定currency时返回该币种累计赔付排行
+func (h *GameHandler) GetLeaderboard(c *gin.Context) {
+	size := config.AppConfig.Leaderboard.Size
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(size)))
+	if limit < 1 || limit > size {
+		limit = size
 	}
-	if pageSize < 1 || pageSize > 100 {
-		pageSize = 50
+
+	if currency := c.Query("currency"); currency != "" {
+		entries, err := h.gameService.WithContext(c.Request.Context()).GetCurrencyLeaderboard(currency, limit)
+		if errors.Is(err, money.ErrUnsupportedCurrency) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": err.Error(),
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    500,
+				"message": "获取排行榜失败: " + err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"code":    200,
+			"message": "获取成功",
+			"data": gin.H{
+				"currency": strings.ToUpper(currency),
+				"entries":  entries,
+			},
+		})
+		return
 	}
 
-	// 获取游戏历史
-	games, total, err := h.gameService.GetGameHistory(page, pageSize)
+	period := c.DefaultQuery("period", model.LeaderboardAllTime)
+	metric := c.DefaultQuery("metric", model.LeaderboardProfit)
+	if err := service.ValidBoard(period, metric); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	leaderboardService := h.leaderboardService.WithContext(c.Request.Context())
+
+	// 已结束的周期从MySQL快照读取
+	if bucket := c.Query("bucket"); bucket != "" && bucket != service.LeaderboardBucket(period, time.Now()) {
+		snapshots, err := leaderboardService.Snapshots(period, metric, bucket, limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    500,
+				"message": "获取排行榜失败: " + err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"code":    200,
+			"message": "获取成功",
+			"data": gin.H{
+				"period":  period,
+				"metric":  metric,
+				"bucket":  bucket,
+				"entries": snapshots,
+			},
+		})
+		return
+	}
+
+	board, err := leaderboardService.Top(period, metric, limit)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
-			"message": "获取历史失败: " + err.Error(),
+			"message": "获取排行榜失败: " + err.Error(),
 		})
 		return
 	}
@@ -304,23 +636,33 @@ func (h *GameHandler) GetGameHistory(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
 		"message": "获取成功",
-		"data": gin.H{
-			"games":     games,
-			"total":     total,
-			"page":      page,
-			"page_size": pageSize,
-		},
+		"data":    board,
 	})
 }
 
-// GetLeaderboard 获取排行榜
-func (h *GameHandler) GetLeaderboard(c *gin.Context) {
-	// 获取排行榜
-	leaderboard, err := h.gameService.GetLeaderboard()
+// GetMyLeaderboardRank 获取当前用户在当前周期各排行榜中的名次，可按period、metric筛选
+func (h *GameHandler) GetMyLeaderboardRank(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"code":    401,
+			"message": "未登录",
+		})
+		return
+	}
+
+	ranks, err := h.leaderboardService.WithContext(c.Request.Context()).Rank(userID, c.Query("period"), c.Query("metric"))
+	if errors.Is(err, service.ErrInvalidLeaderboard) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
-			"message": "获取排行榜失败: " + err.Error(),
+			"message": "获取排名失败: " + err.Error(),
 		})
 		return
 	}
@@ -328,11 +670,11 @@ func (h *GameHandler) GetLeaderboard(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
 		"message": "获取成功",
-		"data":    leaderboard,
+		"data":    ranks,
 	})
 }
 
-// GetUserStats 获取用户统计
+// GetUserStats 获取用户统计，可按start_date、end_date（YYYY-MM-DD）与currency筛选
 func (h *GameHandler) GetUserStats(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
 	if !exists {
@@ -343,8 +685,32 @@ func (h *GameHandler) GetUserStats(c *gin.Context) {
 		return
 	}
 
+	query := service.StatsQuery{Currency: c.Query("currency")}
+	var err error
+	if query.StartDate, err = parseDateQuery(c, "start_date"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "start_date格式错误，应为YYYY-MM-DD",
+		})
+		return
+	}
+	if query.EndDate, err = parseDateQuery(c, "end_date"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "end_date格式错误，应为YYYY-MM-DD",
+		})
+		return
+	}
+
 	// 获取用户统计
-	stats, err := h.gameService.GetUserStats(userID)
+	stats, err := h.gameService.WithContext(c.Request.Context()).GetUserStats(userID, query)
+	if errors.Is(err, service.ErrInvalidStatsRange) || errors.Is(err, money.ErrUnsupportedCurrency) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
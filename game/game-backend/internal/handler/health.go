@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+	"game-backend/internal/websocket"
+	"game-backend/pkg/database"
+)
+
+const (
+	// healthCheckTimeout 单个组件检查超时时间
+	healthCheckTimeout = 2 * time.Second
+	// maxTickAge 游戏循环允许的最长未滴答时间
+	maxTickAge = 5 * time.Second
+)
+
+// ComponentStatus 组件检查结果
+type ComponentStatus struct {
+	Status    string `json:"status"` // ok, fail
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// HealthHandler 健康检查处理器
+type HealthHandler struct {
+	db           *gorm.DB
+	redis        *redis.Client
+	wsHub        *websocket.Hub
+	shuttingDown atomic.Bool
+}
+
+// NewHealthHandler 创建健康检查处理器
+func NewHealthHandler(db *gorm.DB, redisClient *redis.Client, wsHub *websocket.Hub) *HealthHandler {
+	return &HealthHandler{
+		db:    db,
+		redis: redisClient,
+		wsHub: wsHub,
+	}
+}
+
+// MarkShuttingDown 标记实例正在关闭，之后就绪检查始终失败
+func (h *HealthHandler) MarkShuttingDown() {
+	h.shuttingDown.Store(true)
+}
+
+// Livez 存活检查：进程能处理请求即视为存活
+func (h *HealthHandler) Livez(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"message": "服务运行正常",
+	})
+}
+
+// Readyz 就绪检查：依赖组件全部正常才接收流量
+func (h *HealthHandler) Readyz(c *gin.Context) {
+	if h.shuttingDown.Load() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status":  "fail",
+			"message": "服务正在关闭",
+		})
+		return
+	}
+
+	checks := map[string]func(ctx context.Context) error{
+		"mysql":      h.checkMySQL,
+		"redis":      h.checkRedis,
+		"game_loop":  h.checkGameLoop,
+		"migrations": database.CheckMigrations,
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	results := make(map[string]ComponentStatus, len(checks))
+	ready := true
+
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check func(ctx context.Context) error) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(c.Request.Context(), healthCheckTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check(ctx)
+			result := ComponentStatus{
+				Status:    "ok",
+				LatencyMs: time.Since(start).Milliseconds(),
+			}
+			if err != nil {
+				result.Status = "fail"
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			results[name] = result
+			if err != nil {
+				ready = false
+			}
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	status := http.StatusOK
+	overall := "ok"
+	if !ready {
+		status = http.StatusServiceUnavailable
+		overall = "fail"
+	}
+
+	c.JSON(status, gin.H{
+		"status": overall,
+		"checks": results,
+	})
+}
+
+// checkMySQL 检查数据库连接池
+func (h *HealthHandler) checkMySQL(ctx context.Context) error {
+	if h.db == nil {
+		return fmt.Errorf("数据库未初始化")
+	}
+
+	sqlDB, err := h.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// checkRedis 检查Redis连接
+func (h *HealthHandler) checkRedis(ctx context.Context) error {
+	if h.redis == nil {
+		return fmt.Errorf("Redis未初始化")
+	}
+	return h.redis.Ping(ctx).Err()
+}
+
+// checkGameLoop 检查游戏循环是否在正常滴答
+func (h *HealthHandler) checkGameLoop(ctx context.Context) error {
+	last := h.wsHub.LastTick()
+	if last.IsZero() {
+		return fmt.Errorf("游戏循环未启动")
+	}
+	if age := time.Since(last); age > maxTickAge {
+		return fmt.Errorf("游戏循环已 %s 未更新", age.Truncate(time.Millisecond))
+	}
+	return nil
+}
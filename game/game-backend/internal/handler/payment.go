@@ -0,0 +1,357 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"game-backend/config"
+	"game-backend/internal/middleware"
+	"game-backend/internal/model"
+	"game-backend/internal/payment"
+	"game-backend/internal/service"
+	"game-backend/pkg/money"
+)
+
+// PaymentHandler 充值提现处理器
+type PaymentHandler struct {
+	paymentService *service.PaymentService
+}
+
+// NewPaymentHandler 创建充值提现处理器
+func NewPaymentHandler(paymentService *service.PaymentService) *PaymentHandler {
+	return &PaymentHandler{
+		paymentService: paymentService,
+	}
+}
+
+// PaymentAmountRequest 充值提现请求结构，币种为空时使用基准币种
+type PaymentAmountRequest struct {
+	Currency string          `json:"currency"`
+	Amount   decimal.Decimal `json:"amount"`
+}
+
+// RejectRequest 拒绝提现请求结构
+type RejectRequest struct {
+	Reason string `json:"reason" binding:"required,max=255"`
+}
+
+// Deposit 申请充值
+func (h *PaymentHandler) Deposit(c *gin.Context) {
+	h.create(c, model.PaymentTypeDeposit)
+}
+
+// Withdraw 申请提现
+func (h *PaymentHandler) Withdraw(c *gin.Context) {
+	h.create(c, model.PaymentTypeWithdrawal)
+}
+
+// create 创建充值或提现请求
+func (h *PaymentHandler) create(c *gin.Context, paymentType string) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"code":    401,
+			"message": "未登录",
+		})
+		return
+	}
+
+	var req PaymentAmountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	svc := h.paymentService.WithContext(c.Request.Context())
+
+	var record *model.PaymentRequest
+	var err error
+	if paymentType == model.PaymentTypeDeposit {
+		record, err = svc.RequestDeposit(userID, req.Currency, req.Amount, auditMeta(c))
+	} else {
+		record, err = svc.RequestWithdrawal(userID, req.Currency, req.Amount, auditMeta(c))
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, money.ErrUnsupportedCurrency),
+			errors.Is(err, money.ErrInvalidAmount),
+			errors.Is(err, service.ErrDepositOutOfRange),
+			errors.Is(err, service.ErrWithdrawalOutOfRange),
+			errors.Is(err, service.ErrDailyWithdrawalLimit),
+			errors.Is(err, service.ErrDepositLimitExceeded),
+			errors.Is(err, service.ErrInsufficientBalance),
+			errors.Is(err, service.ErrWalletInDebt):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": err.Error(),
+			})
+		case errors.Is(err, service.ErrSelfExcluded), errors.Is(err, service.ErrCoolOffActive):
+			c.JSON(http.StatusForbidden, gin.H{
+				"code":    403,
+				"message": err.Error(),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    500,
+				"message": "提交失败: " + err.Error(),
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "提交成功",
+		"data":    record,
+	})
+}
+
+// GetPayments 获取当前用户充值提现记录
+func (h *PaymentHandler) GetPayments(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"code":    401,
+			"message": "未登录",
+		})
+		return
+	}
+
+	page, err := parsePageQuery(c, 20, 100)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	records, pagination, err := h.paymentService.WithContext(c.Request.Context()).GetUserPayments(userID, c.Query("type"), page)
+	if errors.Is(err, service.ErrInvalidCursor) || errors.Is(err, service.ErrInvalidSort) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "获取记录失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取成功",
+		"data": gin.H{
+			"payments":   records,
+			"pagination": pagination,
+		},
+	})
+}
+
+// ListWithdrawals 获取提现审批队列，默认只返回待审批
+func (h *PaymentHandler) ListWithdrawals(c *gin.Context) {
+	page, err := parsePageQuery(c, 20, 100)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	status := c.DefaultQuery("status", model.PaymentStatusPending)
+	if status == "all" {
+		status = ""
+	}
+
+	records, pagination, err := h.paymentService.WithContext(c.Request.Context()).ListWithdrawals(status, page)
+	if errors.Is(err, service.ErrInvalidCursor) || errors.Is(err, service.ErrInvalidSort) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "获取提现队列失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取成功",
+		"data": gin.H{
+			"withdrawals": records,
+			"pagination":  pagination,
+		},
+	})
+}
+
+// ApproveWithdrawal 审批通过提现
+func (h *PaymentHandler) ApproveWithdrawal(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "提现ID无效",
+		})
+		return
+	}
+
+	reviewerID, _ := middleware.GetUserID(c)
+	record, err := h.paymentService.WithContext(c.Request.Context()).ApproveWithdrawal(id, reviewerID, auditMeta(c))
+	if err != nil {
+		respondReviewError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "审批成功",
+		"data":    record,
+	})
+}
+
+// RejectWithdrawal 拒绝提现并退回冻结金额
+func (h *PaymentHandler) RejectWithdrawal(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "提现ID无效",
+		})
+		return
+	}
+
+	var req RejectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	reviewerID, _ := middleware.GetUserID(c)
+	record, err := h.paymentService.WithContext(c.Request.Context()).RejectWithdrawal(id, reviewerID, req.Reason, auditMeta(c))
+	if err != nil {
+		respondReviewError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "已拒绝",
+		"data":    record,
+	})
+}
+
+// Webhook 处理支付渠道回调，校验签名后按状态机更新订单，重复回调返回成功
+func (h *PaymentHandler) Webhook(c *gin.Context) {
+	provider := c.Param("provider")
+	if provider != h.paymentService.ProviderName() {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code":    404,
+			"message": "未知支付渠道",
+		})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 64<<10))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "读取回调失败",
+		})
+		return
+	}
+
+	cfg := config.AppConfig.Payment
+	if err := payment.Verify(cfg.WebhookSecret, c.GetHeader(payment.HeaderTimestamp), c.GetHeader(payment.HeaderSignature),
+		body, time.Duration(cfg.WebhookTolerance)*time.Second); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"code":    401,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	var hook payment.Webhook
+	if err := json.Unmarshal(body, &hook); err != nil || hook.RequestID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "回调内容无效",
+		})
+		return
+	}
+
+	record, err := h.paymentService.WithContext(c.Request.Context()).HandleWebhook(provider, hook)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{
+				"code":    404,
+				"message": "订单不存在",
+			})
+		case errors.Is(err, service.ErrPaymentProviderMismatch),
+			errors.Is(err, service.ErrInvalidPaymentTransition):
+			c.JSON(http.StatusConflict, gin.H{
+				"code":    409,
+				"message": err.Error(),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    500,
+				"message": "处理回调失败: " + err.Error(),
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "处理成功",
+		"data": gin.H{
+			"request_id": record.RequestID,
+			"status":     record.Status,
+		},
+	})
+}
+
+// respondReviewError 返回审批错误
+func respondReviewError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"code":    404,
+			"message": "提现记录不存在",
+		})
+	case errors.Is(err, service.ErrInvalidPaymentTransition):
+		c.JSON(http.StatusConflict, gin.H{
+			"code":    409,
+			"message": err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "操作失败: " + err.Error(),
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"game-backend/internal/service"
+)
+
+// parsePageQuery 解析游标分页参数：cursor、page_size（超出1到maxSize时取defaultSize）、sort与order（asc/desc，默认desc）
+func parsePageQuery(c *gin.Context, defaultSize, maxSize int) (service.PageQuery, error) {
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(defaultSize)))
+	if pageSize < 1 || pageSize > maxSize {
+		pageSize = defaultSize
+	}
+
+	page := service.PageQuery{
+		Cursor:   c.Query("cursor"),
+		PageSize: pageSize,
+		Sort:     c.Query("sort"),
+	}
+	switch c.DefaultQuery("order", "desc") {
+	case "desc":
+	case "asc":
+		page.Asc = true
+	default:
+		return page, errors.New("order须为asc或desc")
+	}
+	return page, nil
+}
+
+// parseDecimalQuery 解析非负金额或倍数查询参数，未提供时返回nil
+func parseDecimalQuery(c *gin.Context, key string) (*decimal.Decimal, error) {
+	value := c.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+
+	d, err := decimal.NewFromString(value)
+	if err != nil || d.IsNegative() {
+		return nil, errors.New(key + "须为非负数")
+	}
+
+	return &d, nil
+}
+
+// parseStatusQuery 解析逗号分隔的下注状态（0-3），未提供时返回nil
+func parseStatusQuery(c *gin.Context, key string) ([]int, error) {
+	value := c.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+
+	var statuses []int
+	for _, part := range strings.Split(value, ",") {
+		status, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || status < 0 || status > 3 {
+			return nil, errors.New(key + "须为逗号分隔的0-3")
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
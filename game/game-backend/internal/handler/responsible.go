@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"game-backend/internal/middleware"
+	"game-backend/internal/model"
+	"game-backend/internal/service"
+	"game-backend/internal/websocket"
+)
+
+// ResponsibleHandler 负责任博彩处理器
+type ResponsibleHandler struct {
+	responsibleService *service.ResponsibleService
+	wsHub              *websocket.Hub
+}
+
+// NewResponsibleHandler 创建负责任博彩处理器
+func NewResponsibleHandler(responsibleService *service.ResponsibleService, wsHub *websocket.Hub) *ResponsibleHandler {
+	return &ResponsibleHandler{
+		responsibleService: responsibleService,
+		wsHub:              wsHub,
+	}
+}
+
+// SetLimitRequest 设置限额请求结构，金额以基准币种计，会话时长以分钟计，0表示取消
+type SetLimitRequest struct {
+	Type   string          `json:"type" binding:"required"`
+	Period string          `json:"period"`
+	Amount decimal.Decimal `json:"amount"`
+}
+
+// ExclusionRequest 冷静期/自我排除请求结构
+type ExclusionRequest struct {
+	Days int `json:"days" binding:"required,min=1"`
+}
+
+// GetSettings 获取当前用户的限额与自我限制
+func (h *ResponsibleHandler) GetSettings(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"code":    401,
+			"message": "未登录",
+		})
+		return
+	}
+
+	settings, err := h.responsibleService.WithContext(c.Request.Context()).GetSettings(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "获取设置失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取成功",
+		"data":    settings,
+	})
+}
+
+// SetLimit 设置限额
+func (h *ResponsibleHandler) SetLimit(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"code":    401,
+			"message": "未登录",
+		})
+		return
+	}
+
+	var req SetLimitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	limit, err := h.responsibleService.WithContext(c.Request.Context()).SetLimit(userID, req.Type, req.Period, req.Amount, auditMeta(c))
+	if errors.Is(err, service.ErrInvalidLimit) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "设置限额失败: " + err.Error(),
+		})
+		return
+	}
+
+	message := "设置成功"
+	if limit.PendingAmount != nil {
+		message = "放宽限额将在冷却期后生效"
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": message,
+		"data":    limit,
+	})
+}
+
+// CoolOff 开启冷静期
+func (h *ResponsibleHandler) CoolOff(c *gin.Context) {
+	h.exclude(c, model.ExclusionTypeCoolOff)
+}
+
+// SelfExclude 开启自我排除
+func (h *ResponsibleHandler) SelfExclude(c *gin.Context) {
+	h.exclude(c, model.ExclusionTypeSelf)
+}
+
+// exclude 开启冷静期或自我排除，生效后当前会话失效
+func (h *ResponsibleHandler) exclude(c *gin.Context, exclusionType string) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"code":    401,
+			"message": "未登录",
+		})
+		return
+	}
+
+	var req ExclusionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	exclusion, err := h.responsibleService.WithContext(c.Request.Context()).StartExclusion(userID, exclusionType, req.Days, auditMeta(c))
+	if errors.Is(err, service.ErrInvalidExclusion) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "设置失败: " + err.Error(),
+		})
+		return
+	}
+
+	// 登录会话已在开启时清除，同时断开该玩家的实时连接
+	notice := "已开启冷静期，当前会话已结束"
+	if exclusionType == model.ExclusionTypeSelf {
+		notice = "已开启自我排除，当前会话已结束"
+	}
+	h.wsHub.DisconnectUser(userID, notice)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "设置成功",
+		"data":    exclusion,
+	})
+}
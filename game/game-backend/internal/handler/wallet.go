@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"game-backend/internal/middleware"
+	"game-backend/internal/service"
+	"game-backend/pkg/money"
+)
+
+// WalletHandler 钱包处理器
+type WalletHandler struct {
+	walletService *service.WalletService
+}
+
+// NewWalletHandler 创建钱包处理器
+func NewWalletHandler(walletService *service.WalletService) *WalletHandler {
+	return &WalletHandler{
+		walletService: walletService,
+	}
+}
+
+// ListCurrencies 获取支持的币种及下注限额
+func (h *WalletHandler) ListCurrencies(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取成功",
+		"data": gin.H{
+			"base_currency": money.Base().Code,
+			"currencies":    money.Currencies(),
+		},
+	})
+}
+
+// GetWallets 获取当前用户各币种钱包
+func (h *WalletHandler) GetWallets(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"code":    401,
+			"message": "未登录",
+		})
+		return
+	}
+
+	wallets, err := h.walletService.WithContext(c.Request.Context()).GetWallets(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "获取钱包失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取成功",
+		"data":    wallets,
+	})
+}
+
+// GetTransactions 获取当前用户钱包流水
+func (h *WalletHandler) GetTransactions(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"code":    401,
+			"message": "未登录",
+		})
+		return
+	}
+
+	page, err := parsePageQuery(c, 20, 100)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	records, pagination, err := h.walletService.WithContext(c.Request.Context()).GetTransactions(userID, c.Query("currency"), page)
+	if errors.Is(err, money.ErrUnsupportedCurrency) || errors.Is(err, service.ErrInvalidCursor) || errors.Is(err, service.ErrInvalidSort) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "获取流水失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取成功",
+		"data": gin.H{
+			"transactions": records,
+			"pagination":   pagination,
+		},
+	})
+}
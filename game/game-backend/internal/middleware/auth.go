@@ -1,24 +1,32 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/sirupsen/logrus"
 	"game-backend/config"
+	"game-backend/pkg/logger"
 )
 
 // Claims JWT声明结构
 type Claims struct {
 	UserID   uint   `json:"user_id"`
 	Username string `json:"username"`
+	Role     string `json:"role"`
 	jwt.RegisteredClaims
 }
 
+// SessionChecker 检查令牌对应的登录会话是否仍有效
+type SessionChecker func(ctx context.Context, token string) (bool, error)
+
 // AuthMiddleware JWT认证中间件
-func AuthMiddleware() gin.HandlerFunc {
+// 除校验令牌外还要求登录会话仍有效：登出、在其他设备登录、开启冷静期/自我排除或账号禁用后，未过期的令牌也不再接受
+func AuthMiddleware(sessions SessionChecker) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 获取Authorization头
 		authHeader := c.GetHeader("Authorization")
@@ -43,7 +51,7 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		// 解析JWT令牌
-		claims, err := parseToken(tokenString)
+		claims, err := ParseToken(tokenString)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"code":    401,
@@ -63,17 +71,35 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// 检查登录会话
+		active, err := sessions(c.Request.Context(), tokenString)
+		if err != nil {
+			Logger(c).WithError(err).Error("校验登录会话失败")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    500,
+				"message": "校验登录会话失败",
+			})
+			c.Abort()
+			return
+		}
+		if !active {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"code":    401,
+				"message": "登录会话已失效，请重新登录",
+			})
+			c.Abort()
+			return
+		}
+
 		// 将用户信息存储到上下文中
-		c.Set("user_id", claims.UserID)
-		c.Set("username", claims.Username)
-		c.Set("claims", claims)
+		setUserContext(c, claims)
 
 		c.Next()
 	}
 }
 
-// OptionalAuthMiddleware 可选认证中间件（用于某些不需要强制登录的接口）
-func OptionalAuthMiddleware() gin.HandlerFunc {
+// OptionalAuthMiddleware 可选认证中间件（用于某些不需要强制登录的接口），会话已失效的令牌按未登录处理
+func OptionalAuthMiddleware(sessions SessionChecker) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -87,7 +113,7 @@ func OptionalAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		claims, err := parseToken(tokenString)
+		claims, err := ParseToken(tokenString)
 		if err != nil {
 			c.Next()
 			return
@@ -98,16 +124,83 @@ func OptionalAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		c.Set("user_id", claims.UserID)
-		c.Set("username", claims.Username)
-		c.Set("claims", claims)
+		if active, err := sessions(c.Request.Context(), tokenString); err != nil || !active {
+			c.Next()
+			return
+		}
+
+		setUserContext(c, claims)
 
 		c.Next()
 	}
 }
 
-// parseToken 解析JWT令牌
-func parseToken(tokenString string) (*Claims, error) {
+// RoleLoader 读取用户当前角色，用户不存在或已禁用时返回空角色
+type RoleLoader func(ctx context.Context, userID uint) (string, error)
+
+// RoleMiddleware 角色校验中间件（需在AuthMiddleware之后使用）
+// 角色以数据库中的当前值为准而不是令牌中的声明，降级或禁用管理员立即生效
+func RoleMiddleware(load RoleLoader, roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, err := currentRole(c, load)
+		if err != nil {
+			Logger(c).WithError(err).Error("读取用户角色失败")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    500,
+				"message": "读取用户角色失败",
+			})
+			c.Abort()
+			return
+		}
+
+		for _, allowed := range roles {
+			if role == allowed {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{
+			"code":    403,
+			"message": "权限不足",
+		})
+		c.Abort()
+	}
+}
+
+// currentRole 读取用户当前角色并覆盖上下文中令牌声明的角色，同一请求内只读取一次
+func currentRole(c *gin.Context, load RoleLoader) (string, error) {
+	if c.GetBool("role_loaded") {
+		role, _ := GetRole(c)
+		return role, nil
+	}
+
+	userID, _ := GetUserID(c)
+	role, err := load(c.Request.Context(), userID)
+	if err != nil {
+		return "", err
+	}
+
+	c.Set("role", role)
+	c.Set("role_loaded", true)
+	return role, nil
+}
+
+// setUserContext 将用户信息写入gin上下文，并追加到请求日志字段
+func setUserContext(c *gin.Context, claims *Claims) {
+	c.Set("user_id", claims.UserID)
+	c.Set("username", claims.Username)
+	c.Set("role", claims.Role)
+	c.Set("claims", claims)
+
+	ctx := logger.WithFields(c.Request.Context(), logrus.Fields{
+		logger.FieldUserID: claims.UserID,
+	})
+	c.Request = c.Request.WithContext(ctx)
+}
+
+// ParseToken 解析并校验JWT令牌
+func ParseToken(tokenString string) (*Claims, error) {
 	claims := &Claims{}
 	
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
@@ -130,13 +223,14 @@ func parseToken(tokenString string) (*Claims, error) {
 }
 
 // GenerateToken 生成JWT令牌
-func GenerateToken(userID uint, username string) (string, error) {
+func GenerateToken(userID uint, username, role string) (string, error) {
 	now := time.Now()
 	expireTime := now.Add(time.Duration(config.AppConfig.JWT.ExpireTime) * time.Hour)
 
 	claims := &Claims{
 		UserID:   userID,
 		Username: username,
+		Role:     role,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expireTime),
 			IssuedAt:  jwt.NewNumericDate(now),
@@ -172,6 +266,17 @@ func GetUsername(c *gin.Context) (string, bool) {
 	return name, ok
 }
 
+// GetRole 从上下文中获取用户角色
+func GetRole(c *gin.Context) (string, bool) {
+	role, exists := c.Get("role")
+	if !exists {
+		return "", false
+	}
+
+	r, ok := role.(string)
+	return r, ok
+}
+
 // GetClaims 从上下文中获取JWT声明
 func GetClaims(c *gin.Context) (*Claims, bool) {
 	claims, exists := c.Get("claims")
@@ -11,11 +11,14 @@ func CORSMiddleware() gin.HandlerFunc {
 		c.Header("Access-Control-Allow-Origin", "*")
 		
 		// 设置允许的请求头
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With, X-Request-ID")
 		
 		// 设置允许的请求方法
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 		
+		// 允许前端读取请求ID
+		c.Header("Access-Control-Expose-Headers", "X-Request-ID")
+
 		// 设置允许携带凭证
 		c.Header("Access-Control-Allow-Credentials", "true")
 		
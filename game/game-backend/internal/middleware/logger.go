@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -9,43 +10,47 @@ import (
 
 // LoggerMiddleware 日志中间件
 func LoggerMiddleware() gin.HandlerFunc {
-	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
-		// 创建日志条目
-		entry := logrus.WithFields(logrus.Fields{
-			"timestamp":   param.TimeStamp.Format(time.RFC3339),
-			"status":      param.StatusCode,
-			"latency":     param.Latency,
-			"client_ip":   param.ClientIP,
-			"method":      param.Method,
-			"path":        param.Path,
-			"user_agent":  param.Request.UserAgent(),
-			"error":       param.ErrorMessage,
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		// 创建日志条目（已携带request_id、user_id等上下文字段）
+		entry := Logger(c).WithFields(logrus.Fields{
+			"status":     c.Writer.Status(),
+			"latency_ms": time.Since(start).Milliseconds(),
+			"client_ip":  c.ClientIP(),
+			"method":     c.Request.Method,
+			"path":       c.Request.URL.Path,
+			"route":      c.FullPath(),
+			"user_agent": c.Request.UserAgent(),
+			"size":       c.Writer.Size(),
 		})
 
+		if len(c.Errors) > 0 {
+			entry = entry.WithField("error", c.Errors.String())
+		}
+
 		// 根据状态码选择日志级别
-		switch {
-		case param.StatusCode >= 500:
+		switch status := c.Writer.Status(); {
+		case status >= 500:
 			entry.Error("HTTP Request")
-		case param.StatusCode >= 400:
+		case status >= 400:
 			entry.Warn("HTTP Request")
 		default:
 			entry.Info("HTTP Request")
 		}
-
-		return ""
-	})
+	}
 }
 
 // RecoveryMiddleware 恢复中间件
 func RecoveryMiddleware() gin.HandlerFunc {
 	return gin.CustomRecovery(func(c *gin.Context, recovered interface{}) {
-		if err, ok := recovered.(string); ok {
-			logrus.WithFields(logrus.Fields{
-				"error": err,
-				"path":  c.Request.URL.Path,
-				"method": c.Request.Method,
-			}).Error("Panic recovered")
-		}
+		Logger(c).WithFields(logrus.Fields{
+			"error":  fmt.Sprint(recovered),
+			"path":   c.Request.URL.Path,
+			"method": c.Request.Method,
+		}).Error("Panic recovered")
 
 		c.JSON(500, gin.H{
 			"code":    500,
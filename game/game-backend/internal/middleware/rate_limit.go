@@ -7,6 +7,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/time/rate"
+	"game-backend/pkg/metrics"
 )
 
 // RateLimiter 速率限制器
@@ -49,8 +50,8 @@ func (rl *RateLimiter) Cleanup() {
 	// 目前保持简单，让GC处理
 }
 
-// RateLimitMiddleware 速率限制中间件
-func RateLimitMiddleware(requestsPerSecond float64, burst int) gin.HandlerFunc {
+// RateLimitMiddleware 速率限制中间件，name用于区分不同限流器的监控指标
+func RateLimitMiddleware(name string, requestsPerSecond float64, burst int) gin.HandlerFunc {
 	limiter := NewRateLimiter(requestsPerSecond, burst)
 
 	// 启动清理协程
@@ -67,6 +68,7 @@ func RateLimitMiddleware(requestsPerSecond float64, burst int) gin.HandlerFunc {
 		limiter := limiter.GetLimiter(clientIP)
 
 		if !limiter.Allow() {
+			metrics.RateLimitRejections.WithLabelValues(name).Inc()
 			c.JSON(http.StatusTooManyRequests, gin.H{
 				"code":    429,
 				"message": "请求过于频繁，请稍后再试",
@@ -81,15 +83,15 @@ func RateLimitMiddleware(requestsPerSecond float64, burst int) gin.HandlerFunc {
 
 // APIRateLimitMiddleware API速率限制中间件
 func APIRateLimitMiddleware() gin.HandlerFunc {
-	return RateLimitMiddleware(10.0, 20) // 每秒10个请求，突发20个
+	return RateLimitMiddleware("api", 10.0, 20) // 每秒10个请求，突发20个
 }
 
 // WebSocketRateLimitMiddleware WebSocket速率限制中间件
 func WebSocketRateLimitMiddleware() gin.HandlerFunc {
-	return RateLimitMiddleware(5.0, 10) // 每秒5个请求，突发10个
+	return RateLimitMiddleware("websocket", 5.0, 10) // 每秒5个请求，突发10个
 }
 
 // LoginRateLimitMiddleware 登录速率限制中间件
 func LoginRateLimitMiddleware() gin.HandlerFunc {
-	return RateLimitMiddleware(1.0, 3) // 每秒1个请求，突发3个
+	return RateLimitMiddleware("login", 1.0, 3) // 每秒1个请求，突发3个
 }
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"game-backend/pkg/logger"
+)
+
+// RequestIDHeader 请求ID头
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDMiddleware 请求ID中间件（需在TracingMiddleware之后）
+// 沿用上游（如Nginx）传入的请求ID，否则生成新的ID，并写入响应头和请求日志上下文
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if requestID == "" || len(requestID) > 64 {
+			requestID = uuid.NewString()
+		}
+
+		c.Set("request_id", requestID)
+		c.Header(RequestIDHeader, requestID)
+
+		fields := logrus.Fields{
+			logger.FieldRequestID: requestID,
+		}
+
+		// 关联追踪：日志带上trace ID，span带上请求ID
+		if span := trace.SpanFromContext(c.Request.Context()); span.SpanContext().IsValid() {
+			fields[logger.FieldTraceID] = span.SpanContext().TraceID().String()
+			span.SetAttributes(attribute.String("request.id", requestID))
+		}
+
+		ctx := logger.WithFields(c.Request.Context(), fields)
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+	}
+}
+
+// GetRequestID 从上下文中获取请求ID
+func GetRequestID(c *gin.Context) string {
+	return c.GetString("request_id")
+}
+
+// Logger 获取携带请求上下文字段的日志条目
+func Logger(c *gin.Context) *logrus.Entry {
+	return logger.FromContext(c.Request.Context())
+}
@@ -0,0 +1,75 @@
+package model
+
+import (
+	"time"
+)
+
+// 审计动作
+const (
+	AuditActionBetPlace         = "bet.place"
+	AuditActionBetCashout       = "bet.cashout"
+	AuditActionBetAutoCashout   = "bet.auto_cashout"
+	AuditActionBetForced        = "bet.forced_cashout"
+	AuditActionBetCancel        = "bet.cancel"
+	AuditActionWalletCredit     = "wallet.credit"
+	AuditActionPaymentRequest   = "payment.request"
+	AuditActionPaymentApprove   = "payment.approve"
+	AuditActionPaymentReject    = "payment.reject"
+	AuditActionPaymentUpdate    = "payment.update"
+	AuditActionLimitUpdate      = "responsible.limit_update"
+	AuditActionExclusion        = "responsible.exclusion"
+	AuditActionAutoBetStart     = "autobet.start"
+	AuditActionAutoBetStop      = "autobet.stop"
+	AuditActionUserRegister     = "user.register"
+	AuditActionUserLogin        = "user.login"
+	AuditActionUserLoginFail    = "user.login_failed"
+	AuditActionUserLogout       = "user.logout"
+	AuditActionProfileUpdate    = "user.profile_update"
+	AuditActionAdminQuery       = "admin.audit_query"
+	AuditActionAdminVerify      = "admin.audit_verify"
+	AuditActionChatSanction     = "admin.chat_sanction"
+	AuditActionChatSanctionLift = "admin.chat_sanction_lift"
+)
+
+// 审计操作者类型
+const (
+	AuditActorUser   = "user"
+	AuditActorAdmin  = "admin"
+	AuditActorSystem = "system"
+)
+
+// AuditLog 审计日志（只追加，通过哈希链检测篡改）
+type AuditLog struct {
+	ID          uint64    `json:"id" gorm:"primaryKey"`
+	ChainID     uint64    `json:"chain_id" gorm:"index;default:0"`
+	ActorID     uint      `json:"actor_id" gorm:"index;default:0"`
+	ActorType   string    `json:"actor_type" gorm:"size:20;not null"`
+	Action      string    `json:"action" gorm:"size:50;not null;index"`
+	TargetType  string    `json:"target_type" gorm:"size:50"`
+	TargetID    string    `json:"target_id" gorm:"size:100;index"`
+	Reason      string    `json:"reason" gorm:"size:255"`
+	BeforeValue string    `json:"before_value" gorm:"type:text"`
+	AfterValue  string    `json:"after_value" gorm:"type:text"`
+	RequestID   string    `json:"request_id" gorm:"size:64;index"`
+	ClientIP    string    `json:"client_ip" gorm:"size:45"`
+	PrevHash    string    `json:"prev_hash" gorm:"size:64;not null"`
+	Hash        string    `json:"hash" gorm:"uniqueIndex;size:64;not null"`
+	CreatedAt   time.Time `json:"created_at" gorm:"index"`
+}
+
+// AuditChainHead 审计哈希链头，每条链一行（ID为玩家ID，0为公共链），用于串行化同一条链的追加
+type AuditChainHead struct {
+	ID        uint64    `json:"id" gorm:"primaryKey"`
+	LastID    uint64    `json:"last_id" gorm:"default:0"`
+	LastHash  string    `json:"last_hash" gorm:"size:64;not null"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
+
+func (AuditChainHead) TableName() string {
+	return "audit_chain_head"
+}
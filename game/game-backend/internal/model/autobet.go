@@ -0,0 +1,60 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// 自动下注会话状态
+const (
+	AutoBetStatusRunning   = "running"   // 进行中
+	AutoBetStatusCompleted = "completed" // 已完成设定轮数
+	AutoBetStatusStopped   = "stopped"   // 触发止盈止损、玩家停止或下注失败
+)
+
+// 自动下注输赢后的下注金额调整方式
+const (
+	AutoBetAdjustReset    = "reset"    // 恢复为基础金额
+	AutoBetAdjustMultiply = "multiply" // 乘以指定倍数
+)
+
+// 自动下注停止原因
+const (
+	AutoBetStopProfit    = "stop_on_profit" // 净盈利达到止盈额
+	AutoBetStopLoss      = "stop_on_loss"   // 净亏损达到止损额
+	AutoBetStopUser      = "user"           // 玩家停止
+	AutoBetStopBetFailed = "bet_failed"     // 下注失败（余额不足、超出限额等）
+)
+
+// AutoBetSession 服务端自动下注会话，由游戏循环在每局下注阶段执行，与WebSocket连接无关
+// 每位玩家同时只能有一个进行中的会话，金额均为会话币种
+type AutoBetSession struct {
+	ID               uint64          `json:"id" gorm:"primaryKey"`
+	UserID           uint            `json:"user_id" gorm:"not null;index:idx_auto_bet_user_status"`
+	Status           string          `json:"status" gorm:"size:20;not null;index:idx_auto_bet_user_status"`
+	Currency         string          `json:"currency" gorm:"size:10;not null"`
+	BaseAmount       decimal.Decimal `json:"base_amount" gorm:"type:decimal(30,8);not null"`
+	CurrentAmount    decimal.Decimal `json:"current_amount" gorm:"type:decimal(30,8);not null"` // 下一局的下注金额
+	AutoCashout      decimal.Decimal `json:"auto_cashout" gorm:"type:decimal(10,2);not null"`
+	Rounds           int             `json:"rounds" gorm:"not null"`
+	RoundsPlayed     int             `json:"rounds_played" gorm:"default:0"`
+	OnWin            string          `json:"on_win" gorm:"size:20;not null"`
+	OnWinMultiplier  decimal.Decimal `json:"on_win_multiplier" gorm:"type:decimal(10,2);default:0"`
+	OnLoss           string          `json:"on_loss" gorm:"size:20;not null"`
+	OnLossMultiplier decimal.Decimal `json:"on_loss_multiplier" gorm:"type:decimal(10,2);default:0"`
+	StopOnProfit     decimal.Decimal `json:"stop_on_profit" gorm:"type:decimal(30,8);default:0"` // 0表示不限
+	StopOnLoss       decimal.Decimal `json:"stop_on_loss" gorm:"type:decimal(30,8);default:0"`   // 0表示不限
+	Profit           decimal.Decimal `json:"profit" gorm:"type:decimal(30,8);default:0"`         // 已结束下注的净盈亏
+	LastBetID        string          `json:"last_bet_id" gorm:"size:50"`                         // 等待结果的下注，结算后清空
+	StopReason       string          `json:"stop_reason,omitempty" gorm:"size:30"`
+	StopMessage      string          `json:"stop_message,omitempty" gorm:"size:255"`
+	EndedAt          *time.Time      `json:"ended_at,omitempty"`
+	CreatedAt        time.Time       `json:"created_at"`
+	UpdatedAt        time.Time       `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (AutoBetSession) TableName() string {
+	return "auto_bet_sessions"
+}
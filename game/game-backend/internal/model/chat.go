@@ -0,0 +1,51 @@
+package model
+
+import (
+	"time"
+)
+
+// 聊天处罚类型
+const (
+	ChatSanctionMute = "mute" // 禁言：可以接收消息，不能发言
+	ChatSanctionBan  = "ban"  // 封禁：不能加入聊天室
+)
+
+// ChatMessage 聊天消息，全部持久化以便审核
+type ChatMessage struct {
+	ID        uint64    `json:"id" gorm:"primaryKey"`
+	Room      string    `json:"room" gorm:"size:50;not null;index:idx_chat_message_room"`
+	UserID    uint      `json:"user_id" gorm:"not null;index"`
+	Username  string    `json:"username" gorm:"size:50;not null"`
+	Content   string    `json:"content" gorm:"size:1000;not null"`   // 过滤后下发的内容
+	Original  string    `json:"original,omitempty" gorm:"size:1000"` // 命中屏蔽词时的原始内容
+	Flagged   bool      `json:"flagged" gorm:"default:false;index"`  // 命中屏蔽词，待审核
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
+
+// ChatSanction 客服/管理员对玩家的禁言与封禁，Room为空表示所有聊天室
+type ChatSanction struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	UserID      uint       `json:"user_id" gorm:"not null;index:idx_chat_sanction_user"`
+	Type        string     `json:"type" gorm:"size:20;not null"`
+	Room        string     `json:"room" gorm:"size:50"`
+	Reason      string     `json:"reason" gorm:"size:255"`
+	ModeratorID uint       `json:"moderator_id" gorm:"not null"`
+	Until       *time.Time `json:"until,omitempty"`     // 为空表示永久
+	LiftedAt    *time.Time `json:"lifted_at,omitempty"` // 提前解除时间
+	LiftedBy    uint       `json:"lifted_by,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// Active 处罚在now时是否仍然有效
+func (s *ChatSanction) Active(now time.Time) bool {
+	return s.LiftedAt == nil && (s.Until == nil || s.Until.After(now))
+}
+
+// TableName 指定表名
+func (ChatMessage) TableName() string {
+	return "chat_messages"
+}
+
+func (ChatSanction) TableName() string {
+	return "chat_sanctions"
+}
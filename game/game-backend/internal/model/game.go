@@ -2,6 +2,8 @@ package model
 
 import (
 	"time"
+
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
@@ -11,10 +13,10 @@ type Game struct {
 	GameID      string         `json:"game_id" gorm:"uniqueIndex;size:50;not null"`
 	Status      int            `json:"status" gorm:"default:0"` // 0:等待 1:进行中 2:已结束
 	RoundID     string         `json:"round_id" gorm:"size:50"`
-	Multiplier  float64        `json:"multiplier" gorm:"type:decimal(10,2);default:0"`
+	Multiplier  decimal.Decimal `json:"multiplier" gorm:"type:decimal(10,2);default:0"`
 	PlayersCount int32         `json:"players_count" gorm:"default:0"`
-	TotalBets   float64        `json:"total_bets" gorm:"type:decimal(15,2);default:0"`
-	TotalPayout float64        `json:"total_payout" gorm:"type:decimal(15,2);default:0"`
+	TotalBets   decimal.Decimal `json:"total_bets" gorm:"type:decimal(15,2);default:0"`
+	TotalPayout decimal.Decimal `json:"total_payout" gorm:"type:decimal(15,2);default:0"`
 	StartTime   *time.Time     `json:"start_time"`
 	EndTime     *time.Time     `json:"end_time"`
 	CreatedAt   time.Time      `json:"created_at"`
@@ -26,44 +28,83 @@ type Game struct {
 type Bet struct {
 	ID           uint           `json:"id" gorm:"primaryKey"`
 	BetID        string         `json:"bet_id" gorm:"uniqueIndex;size:50;not null"`
-	UserID       uint           `json:"user_id" gorm:"not null"`
+	UserID       uint           `json:"user_id" gorm:"not null;uniqueIndex:idx_bet_round_slot;index:idx_bets_user_amount;index:idx_bets_user_payout;index:idx_bets_user_multiplier"`
 	GameID       string         `json:"game_id" gorm:"size:50;not null"`
-	RoundID      string         `json:"round_id" gorm:"size:50"`
-	Amount       float64        `json:"amount" gorm:"type:decimal(15,2);not null"`
-	AutoCashout  float64        `json:"auto_cashout" gorm:"type:decimal(10,2);default:0"`
-	Multiplier   float64        `json:"multiplier" gorm:"type:decimal(10,2);default:0"`
-	Payout       float64        `json:"payout" gorm:"type:decimal(15,2);default:0"`
-	Status       int            `json:"status" gorm:"default:0"` // 0:进行中 1:已止盈 2:已崩盘
+	RoundID      string         `json:"round_id" gorm:"size:50;uniqueIndex:idx_bet_round_slot"`
+	Slot         int            `json:"slot" gorm:"default:0;uniqueIndex:idx_bet_round_slot"` // 本局内的下注位，从0开始
+	Currency     string         `json:"currency" gorm:"size:10;not null"`
+	Amount       decimal.Decimal `json:"amount" gorm:"type:decimal(30,8);not null;index:idx_bets_user_amount"`
+	AutoCashout  decimal.Decimal `json:"auto_cashout" gorm:"type:decimal(10,2);default:0"`
+	Multiplier   decimal.Decimal `json:"multiplier" gorm:"type:decimal(10,2);default:0;index:idx_bets_user_multiplier"` // 最近一次结算的倍数
+	Payout       decimal.Decimal `json:"payout" gorm:"type:decimal(30,8);default:0;index:idx_bets_user_payout"` // 各次结算赔付合计
+	RemainingAmount decimal.Decimal `json:"remaining_amount" gorm:"type:decimal(30,8);default:0"` // 尚未结算的本金，崩盘时全部亏损
+	Status       int            `json:"status" gorm:"default:0"` // 0:进行中 1:已止盈 2:已崩盘 3:已撤销
 	CashoutTime  *time.Time     `json:"cashout_time"`
 	CreatedAt    time.Time      `json:"created_at"`
 	UpdatedAt    time.Time      `json:"updated_at"`
 	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
+
+	Settlements []BetSettlement `json:"settlements,omitempty" gorm:"-"` // 结算明细，按需加载
+}
+
+// 结算类型
+const (
+	SettlementManual = "manual" // 玩家手动止盈
+	SettlementAuto   = "auto"   // 自动止盈
+	SettlementForced = "forced" // 风控强制止盈
+)
+
+// BetSettlement 下注结算明细，部分止盈时每次结算一条，剩余本金全部结算后下注变为已止盈
+type BetSettlement struct {
+	ID         uint64          `json:"id" gorm:"primaryKey"`
+	BetID      string          `json:"bet_id" gorm:"size:50;not null;index"`
+	Type       string          `json:"type" gorm:"size:20;not null"`
+	Stake      decimal.Decimal `json:"stake" gorm:"type:decimal(30,8);not null"` // 本次结算的本金
+	Multiplier decimal.Decimal `json:"multiplier" gorm:"type:decimal(10,2);not null"`
+	Payout     decimal.Decimal `json:"payout" gorm:"type:decimal(30,8);not null"`
+	CreatedAt  time.Time       `json:"created_at"`
 }
 
-// GameHistory 游戏历史记录
+// GameHistory 游戏历史记录，每局崩盘且下注全部结算后写入，金额折算为基准币种
 type GameHistory struct {
 	ID            uint      `json:"id" gorm:"primaryKey"`
 	RoundID       string    `json:"round_id" gorm:"uniqueIndex;size:50;not null"`
 	GameID        string    `json:"game_id" gorm:"size:50;not null"`
-	FinalMultiplier float64 `json:"final_multiplier" gorm:"type:decimal(10,2);not null"`
+	FinalMultiplier decimal.Decimal `json:"final_multiplier" gorm:"type:decimal(10,2);not null"`
 	PlayersCount  int32     `json:"players_count" gorm:"default:0"`
-	TotalBets     float64   `json:"total_bets" gorm:"type:decimal(15,2);default:0"`
-	TotalPayout   float64   `json:"total_payout" gorm:"type:decimal(15,2);default:0"`
+	BetsCount     int32     `json:"bets_count" gorm:"default:0"`
+	TotalBets     decimal.Decimal `json:"total_bets" gorm:"type:decimal(15,2);default:0;index:idx_total_bets"`
+	TotalPayout   decimal.Decimal `json:"total_payout" gorm:"type:decimal(15,2);default:0;index:idx_total_payout"`
 	WinnersCount  int32     `json:"winners_count" gorm:"default:0"`
 	StartTime     time.Time `json:"start_time"`
 	EndTime       time.Time `json:"end_time"`
 	CreatedAt     time.Time `json:"created_at"`
 }
 
-// Leaderboard 排行榜
-type Leaderboard struct {
-	ID                uint      `json:"id" gorm:"primaryKey"`
-	UserID            uint      `json:"user_id" gorm:"not null"`
-	Username          string    `json:"username" gorm:"size:50;not null"`
-	TotalWinnings     float64   `json:"total_winnings" gorm:"type:decimal(15,2);default:0"`
-	BiggestMultiplier float64   `json:"biggest_multiplier" gorm:"type:decimal(10,2);default:0"`
-	Rank              int32     `json:"rank" gorm:"default:0"`
-	UpdatedAt         time.Time `json:"updated_at"`
+// 排行榜周期
+const (
+	LeaderboardDaily   = "daily"
+	LeaderboardWeekly  = "weekly"
+	LeaderboardAllTime = "all"
+)
+
+// 排行榜类型
+const (
+	LeaderboardProfit     = "profit"     // 净盈亏，折算为基准币种
+	LeaderboardMultiplier = "multiplier" // 最大止盈倍数
+)
+
+// LeaderboardSnapshot 排行榜快照，每个周期的每个名次一行，定期覆盖写入，周期结束后保留最终排名
+type LeaderboardSnapshot struct {
+	ID        uint64          `json:"id" gorm:"primaryKey"`
+	Period    string          `json:"period" gorm:"size:10;not null;uniqueIndex:idx_leaderboard_snapshot_rank"`
+	Metric    string          `json:"metric" gorm:"size:20;not null;uniqueIndex:idx_leaderboard_snapshot_rank"`
+	Bucket    string          `json:"bucket" gorm:"size:20;not null;uniqueIndex:idx_leaderboard_snapshot_rank"` // 日榜20240101，周榜2024W01，总榜all
+	Rank      int32           `json:"rank" gorm:"not null;uniqueIndex:idx_leaderboard_snapshot_rank"`
+	UserID    uint            `json:"user_id" gorm:"not null;index"`
+	Username  string          `json:"username" gorm:"size:50;not null"`
+	Score     decimal.Decimal `json:"score" gorm:"type:decimal(30,8);not null"`
+	TakenAt   time.Time       `json:"taken_at"`
 }
 
 // TableName 指定表名
@@ -79,6 +120,6 @@ func (GameHistory) TableName() string {
 	return "game_history"
 }
 
-func (Leaderboard) TableName() string {
-	return "leaderboard"
+func (LeaderboardSnapshot) TableName() string {
+	return "leaderboard_snapshots"
 }
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// 充值提现类型
+const (
+	PaymentTypeDeposit    = "deposit"
+	PaymentTypeWithdrawal = "withdrawal"
+)
+
+// 充值提现状态
+const (
+	PaymentStatusPending   = "pending"   // 已创建，等待渠道回调或人工审批
+	PaymentStatusApproved  = "approved"  // 提现已审批，已提交渠道打款
+	PaymentStatusCompleted = "completed" // 已完成
+	PaymentStatusFailed    = "failed"    // 失败或被拒绝
+	PaymentStatusReversed  = "reversed"  // 完成后被渠道冲正
+)
+
+// paymentTransitions 各类型允许的状态流转
+var paymentTransitions = map[string]map[string][]string{
+	PaymentTypeDeposit: {
+		PaymentStatusPending:   {PaymentStatusCompleted, PaymentStatusFailed},
+		PaymentStatusCompleted: {PaymentStatusReversed},
+	},
+	PaymentTypeWithdrawal: {
+		PaymentStatusPending:   {PaymentStatusApproved, PaymentStatusFailed},
+		PaymentStatusApproved:  {PaymentStatusCompleted, PaymentStatusFailed},
+		PaymentStatusCompleted: {PaymentStatusReversed},
+	},
+}
+
+// PaymentRequest 充值提现请求
+type PaymentRequest struct {
+	ID            uint64          `json:"id" gorm:"primaryKey"`
+	RequestID     string          `json:"request_id" gorm:"size:50;uniqueIndex;not null"`
+	UserID        uint            `json:"user_id" gorm:"not null;index:idx_payment_user_type"`
+	Type          string          `json:"type" gorm:"size:20;not null;index:idx_payment_user_type"`
+	Currency      string          `json:"currency" gorm:"size:10;not null"`
+	Amount        decimal.Decimal `json:"amount" gorm:"type:decimal(30,8);not null"`
+	Status        string          `json:"status" gorm:"size:20;not null;index"`
+	Provider      string          `json:"provider" gorm:"size:30;not null"`
+	ProviderRef   string          `json:"provider_ref" gorm:"size:100;index"`
+	PaymentURL    string          `json:"payment_url,omitempty" gorm:"size:500"`
+	FailureReason string          `json:"failure_reason,omitempty" gorm:"size:255"`
+	ReviewedBy    *uint           `json:"reviewed_by,omitempty"`
+	ReviewedAt    *time.Time      `json:"reviewed_at,omitempty"`
+	CompletedAt   *time.Time      `json:"completed_at,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+
+	// 关联
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+// CanTransition 判断当前状态能否流转到目标状态
+func (p *PaymentRequest) CanTransition(to string) bool {
+	for _, next := range paymentTransitions[p.Type][p.Status] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// TableName 指定表名
+func (PaymentRequest) TableName() string {
+	return "payment_requests"
+}
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// 玩家限额类型
+const (
+	LimitTypeDeposit = "deposit" // 充值限额
+	LimitTypeLoss    = "loss"    // 净亏损限额
+	LimitTypeWager   = "wager"   // 下注总额限额
+	LimitTypeSession = "session" // 单次游戏会话时长（分钟）
+)
+
+// 限额统计周期（滚动窗口）
+const (
+	LimitPeriodDaily   = "daily"
+	LimitPeriodWeekly  = "weekly"
+	LimitPeriodMonthly = "monthly"
+	LimitPeriodSession = "session"
+)
+
+// 自我限制类型
+const (
+	ExclusionTypeCoolOff = "cool_off"       // 冷静期
+	ExclusionTypeSelf    = "self_exclusion" // 自我排除
+)
+
+// PlayerLimit 玩家自设限额，金额以基准币种计，会话时长以分钟计
+// 放宽限额先写入待生效字段，等待冷却期后生效；收紧立即生效
+type PlayerLimit struct {
+	ID                 uint             `json:"id" gorm:"primaryKey"`
+	UserID             uint             `json:"user_id" gorm:"not null;uniqueIndex:idx_player_limit"`
+	Type               string           `json:"type" gorm:"size:20;not null;uniqueIndex:idx_player_limit"`
+	Period             string           `json:"period" gorm:"size:20;not null;uniqueIndex:idx_player_limit"`
+	Amount             decimal.Decimal  `json:"amount" gorm:"type:decimal(30,8);not null"` // 0表示不限
+	PendingAmount      *decimal.Decimal `json:"pending_amount,omitempty" gorm:"type:decimal(30,8)"`
+	PendingEffectiveAt *time.Time       `json:"pending_effective_at,omitempty"`
+	CreatedAt          time.Time        `json:"created_at"`
+	UpdatedAt          time.Time        `json:"updated_at"`
+}
+
+// PlayerExclusion 冷静期与自我排除记录（只追加），到期前不可撤销
+type PlayerExclusion struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"not null;index:idx_player_exclusion_user_until"`
+	Type      string    `json:"type" gorm:"size:20;not null"`
+	Until     time.Time `json:"until" gorm:"not null;index:idx_player_exclusion_user_until"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PlayerSession 玩家游戏会话，每个玩家一行，由下注更新，登出不删除
+// 两次下注间隔达到session_break时开始新的会话，会话时长限额从StartedAt起算
+type PlayerSession struct {
+	UserID       uint      `json:"user_id" gorm:"primaryKey;autoIncrement:false"`
+	StartedAt    time.Time `json:"started_at" gorm:"not null"`
+	LastActiveAt time.Time `json:"last_active_at" gorm:"not null"`
+}
+
+// TableName 指定表名
+func (PlayerLimit) TableName() string {
+	return "player_limits"
+}
+
+func (PlayerExclusion) TableName() string {
+	return "player_exclusions"
+}
+
+func (PlayerSession) TableName() string {
+	return "player_sessions"
+}
@@ -2,6 +2,8 @@ package model
 
 import (
 	"time"
+
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
@@ -11,24 +13,60 @@ type User struct {
 	Username  string         `json:"username" gorm:"uniqueIndex;size:50;not null"`
 	Password  string         `json:"-" gorm:"size:255;not null"`
 	Email     string         `json:"email" gorm:"size:100"`
-	Balance   float64        `json:"balance" gorm:"type:decimal(15,2);default:0"`
 	Avatar    string         `json:"avatar" gorm:"size:255"`
-	Status    int            `json:"status" gorm:"default:1"` // 1:正常 0:禁用
+	Role      string         `json:"role" gorm:"size:20;default:user"` // user, support, admin
+	Status    int            `json:"status" gorm:"default:1"`          // 1:正常 0:禁用
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	Wallets []Wallet `json:"wallets,omitempty" gorm:"foreignKey:UserID"`
 }
 
-// UserStats 用户统计信息
+// 用户角色
+const (
+	RoleUser    = "user"
+	RoleSupport = "support"
+	RoleAdmin   = "admin"
+)
+
+// UserStats 用户统计信息（按币种），下注结束（全部止盈或崩盘）时计入下注数与输赢，每笔止盈结算计入止盈次数与倍数
 type UserStats struct {
-	ID                uint    `json:"id" gorm:"primaryKey"`
-	UserID            uint    `json:"user_id" gorm:"not null"`
-	TotalBets         int64   `json:"total_bets" gorm:"default:0"`
-	TotalWinnings     float64 `json:"total_winnings" gorm:"type:decimal(15,2);default:0"`
-	BiggestMultiplier float64 `json:"biggest_multiplier" gorm:"type:decimal(10,2);default:0"`
-	GamesPlayed       int64   `json:"games_played" gorm:"default:0"`
-	CreatedAt         time.Time `json:"created_at"`
-	UpdatedAt         time.Time `json:"updated_at"`
+	ID                   uint            `json:"id" gorm:"primaryKey"`
+	UserID               uint            `json:"user_id" gorm:"not null;uniqueIndex:idx_user_stats_user_currency"`
+	Currency             string          `json:"currency" gorm:"size:10;not null;uniqueIndex:idx_user_stats_user_currency"`
+	TotalBets            int64           `json:"total_bets" gorm:"default:0"` // 已结束的下注数，不含撤销
+	Wins                 int64           `json:"wins" gorm:"default:0"`       // 赔付大于本金的下注数
+	Losses               int64           `json:"losses" gorm:"default:0"`
+	TotalWagered         decimal.Decimal `json:"total_wagered" gorm:"type:decimal(30,8);default:0"`
+	TotalWinnings        decimal.Decimal `json:"total_winnings" gorm:"type:decimal(30,8);default:0"` // 累计赔付
+	BiggestMultiplier    decimal.Decimal `json:"biggest_multiplier" gorm:"type:decimal(10,2);default:0"`
+	Cashouts             int64           `json:"cashouts" gorm:"default:0"` // 止盈结算次数，部分止盈每次计一次
+	CashoutMultiplierSum decimal.Decimal `json:"cashout_multiplier_sum" gorm:"type:decimal(20,2);default:0"`
+	CurrentStreak        int64           `json:"current_streak" gorm:"default:0"` // 大于0为连胜，小于0为连败
+	LongestWinStreak     int64           `json:"longest_win_streak" gorm:"default:0"`
+	LongestLossStreak    int64           `json:"longest_loss_streak" gorm:"default:0"`
+	GamesPlayed          int64           `json:"games_played" gorm:"default:0"` // 参与的局数
+	LastRoundID          string          `json:"-" gorm:"size:50"`              // 最近一次结束下注的轮次，用于统计参与局数
+	CreatedAt            time.Time       `json:"created_at"`
+	UpdatedAt            time.Time       `json:"updated_at"`
+}
+
+// UserDailyStats 用户每日统计（按币种），按结算日期（服务器时区）汇总
+type UserDailyStats struct {
+	ID                   uint64          `json:"id" gorm:"primaryKey"`
+	UserID               uint            `json:"user_id" gorm:"not null;uniqueIndex:idx_user_daily_stats_day"`
+	Currency             string          `json:"currency" gorm:"size:10;not null;uniqueIndex:idx_user_daily_stats_day"`
+	Day                  time.Time       `json:"day" gorm:"type:date;not null;uniqueIndex:idx_user_daily_stats_day"`
+	Bets                 int64           `json:"bets" gorm:"default:0"`
+	Wins                 int64           `json:"wins" gorm:"default:0"`
+	Losses               int64           `json:"losses" gorm:"default:0"`
+	Wagered              decimal.Decimal `json:"wagered" gorm:"type:decimal(30,8);default:0"`
+	Winnings             decimal.Decimal `json:"winnings" gorm:"type:decimal(30,8);default:0"`
+	Cashouts             int64           `json:"cashouts" gorm:"default:0"`
+	CashoutMultiplierSum decimal.Decimal `json:"cashout_multiplier_sum" gorm:"type:decimal(20,2);default:0"`
+	BiggestMultiplier    decimal.Decimal `json:"biggest_multiplier" gorm:"type:decimal(10,2);default:0"`
+	UpdatedAt            time.Time       `json:"updated_at"`
 }
 
 // UserSession 用户会话
@@ -49,6 +87,10 @@ func (UserStats) TableName() string {
 	return "user_stats"
 }
 
+func (UserDailyStats) TableName() string {
+	return "user_daily_stats"
+}
+
 func (UserSession) TableName() string {
 	return "user_sessions"
 }
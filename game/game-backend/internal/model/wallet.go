@@ -0,0 +1,53 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// 钱包流水类型
+const (
+	WalletTxBet         = "bet"
+	WalletTxBetRefund   = "bet_refund" // 下注阶段撤销下注时退回本金
+	WalletTxPayout      = "payout"
+	WalletTxSignupBonus = "signup_bonus"
+
+	WalletTxDeposit          = "deposit"
+	WalletTxDepositReversal  = "deposit_reversal"
+	WalletTxWithdrawal       = "withdrawal"        // 申请提现时冻结扣款
+	WalletTxWithdrawalRefund = "withdrawal_refund" // 提现失败或冲正时退回
+)
+
+// Wallet 用户钱包，每个用户每个币种一条
+type Wallet struct {
+	ID        uint            `json:"id" gorm:"primaryKey"`
+	UserID    uint            `json:"user_id" gorm:"not null;uniqueIndex:idx_wallet_user_currency"`
+	Currency  string          `json:"currency" gorm:"size:10;not null;uniqueIndex:idx_wallet_user_currency"`
+	Balance   decimal.Decimal `json:"balance" gorm:"type:decimal(30,8);default:0"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// WalletTransaction 钱包流水（只追加），余额变动均需记录
+type WalletTransaction struct {
+	ID           uint64          `json:"id" gorm:"primaryKey"`
+	WalletID     uint            `json:"wallet_id" gorm:"not null;index"`
+	UserID       uint            `json:"user_id" gorm:"not null;index:idx_wallet_tx_user_currency"`
+	Currency     string          `json:"currency" gorm:"size:10;not null;index:idx_wallet_tx_user_currency"`
+	Type         string          `json:"type" gorm:"size:30;not null"`
+	Amount       decimal.Decimal `json:"amount" gorm:"type:decimal(30,8);not null"` // 正数入账，负数出账
+	BalanceAfter decimal.Decimal `json:"balance_after" gorm:"type:decimal(30,8);not null"`
+	RefType      string          `json:"ref_type" gorm:"size:30"`
+	RefID        string          `json:"ref_id" gorm:"size:100;index"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+// TableName 指定表名
+func (Wallet) TableName() string {
+	return "wallets"
+}
+
+func (WalletTransaction) TableName() string {
+	return "wallet_transactions"
+}
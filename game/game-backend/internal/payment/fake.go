@@ -0,0 +1,97 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"game-backend/config"
+	"game-backend/pkg/logger"
+)
+
+// FakeProvider 本地模拟支付渠道，受理后延迟向回调地址发送签名的成功回调
+type FakeProvider struct {
+	secret      string
+	callbackURL string
+	delay       time.Duration
+	client      *http.Client
+}
+
+// NewFakeProvider 创建模拟支付渠道
+func NewFakeProvider(cfg config.PaymentConfig) *FakeProvider {
+	return &FakeProvider{
+		secret:      cfg.WebhookSecret,
+		callbackURL: cfg.FakeCallbackURL,
+		delay:       time.Duration(cfg.FakeCallbackDelay) * time.Second,
+		client:      &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Name 渠道名称
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+// CreateDeposit 创建充值订单
+func (p *FakeProvider) CreateDeposit(ctx context.Context, req Request) (*Result, error) {
+	return p.accept(ctx, req), nil
+}
+
+// CreatePayout 发起提现打款
+func (p *FakeProvider) CreatePayout(ctx context.Context, req Request) (*Result, error) {
+	return p.accept(ctx, req), nil
+}
+
+// accept 生成渠道订单号并安排模拟回调
+func (p *FakeProvider) accept(ctx context.Context, req Request) *Result {
+	ref := "fake_" + uuid.NewString()
+	if p.callbackURL != "" {
+		log := logger.FromContext(ctx)
+		go p.callback(log, Webhook{
+			RequestID:   req.RequestID,
+			ProviderRef: ref,
+			Status:      "completed",
+		})
+	}
+	return &Result{ProviderRef: ref}
+}
+
+// callback 发送签名回调
+func (p *FakeProvider) callback(log *logrus.Entry, hook Webhook) {
+	time.Sleep(p.delay)
+
+	body, err := json.Marshal(hook)
+	if err != nil {
+		log.WithError(err).Error("编码模拟支付回调失败")
+		return
+	}
+
+	ts := time.Now().Unix()
+	req, err := http.NewRequest(http.MethodPost, p.callbackURL, bytes.NewReader(body))
+	if err != nil {
+		log.WithError(err).Error("创建模拟支付回调请求失败")
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(ts, 10))
+	req.Header.Set(HeaderSignature, Sign(p.secret, ts, body))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		log.WithError(err).WithField("request_id", hook.RequestID).Warn("发送模拟支付回调失败")
+		return
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		log.WithFields(logrus.Fields{
+			"request_id": hook.RequestID,
+			"status":     resp.StatusCode,
+		}).Warn("模拟支付回调被拒绝")
+	}
+}
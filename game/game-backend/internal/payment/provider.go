@@ -0,0 +1,45 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	"game-backend/config"
+)
+
+// Request 发往支付渠道的充值/提现请求
+type Request struct {
+	RequestID string
+	UserID    uint
+	Type      string // deposit, withdrawal
+	Currency  string
+	Amount    decimal.Decimal
+}
+
+// Result 支付渠道受理结果
+type Result struct {
+	ProviderRef string // 渠道侧订单号
+	PaymentURL  string // 充值时用户跳转支付的地址，可为空
+}
+
+// Provider 支付渠道接口，最终结果通过签名回调通知
+type Provider interface {
+	// Name 渠道名称，与回调路由中的 :provider 对应
+	Name() string
+	// CreateDeposit 创建充值订单
+	CreateDeposit(ctx context.Context, req Request) (*Result, error)
+	// CreatePayout 发起提现打款
+	CreatePayout(ctx context.Context, req Request) (*Result, error)
+}
+
+// NewProvider 根据配置创建支付渠道
+func NewProvider(cfg config.PaymentConfig) (Provider, error) {
+	switch strings.ToLower(cfg.Provider) {
+	case "", "fake":
+		return NewFakeProvider(cfg), nil
+	default:
+		return nil, fmt.Errorf("不支持的支付渠道: %s", cfg.Provider)
+	}
+}
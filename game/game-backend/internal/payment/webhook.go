@@ -0,0 +1,63 @@
+package payment
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 回调请求头
+const (
+	HeaderSignature = "X-Payment-Signature"
+	HeaderTimestamp = "X-Payment-Timestamp"
+)
+
+var (
+	// ErrInvalidSignature 回调签名无效
+	ErrInvalidSignature = errors.New("回调签名无效")
+	// ErrStaleWebhook 回调时间戳超出允许范围
+	ErrStaleWebhook = errors.New("回调已过期")
+)
+
+// Webhook 支付渠道回调内容
+type Webhook struct {
+	RequestID   string `json:"request_id"`
+	ProviderRef string `json:"provider_ref"`
+	Status      string `json:"status"` // completed, failed, reversed
+	Reason      string `json:"reason,omitempty"`
+}
+
+// Sign 计算回调签名：HMAC-SHA256(secret, timestamp + "." + body)
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify 校验回调签名与时间戳，tolerance内的时间偏差视为有效
+func Verify(secret, timestamp, signature string, body []byte, tolerance time.Duration) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	skew := time.Since(time.Unix(ts, 0))
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > tolerance {
+		return ErrStaleWebhook
+	}
+
+	expected := Sign(secret, ts, body)
+	if !hmac.Equal([]byte(expected), []byte(strings.TrimSpace(signature))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,295 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"game-backend/internal/model"
+)
+
+// auditGenesisHash 哈希链起点
+const auditGenesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+// AuditMeta 审计请求上下文
+type AuditMeta struct {
+	ActorID   uint
+	ActorType string
+	RequestID string
+	ClientIP  string
+}
+
+// SystemAuditMeta 系统操作的审计上下文
+func SystemAuditMeta() AuditMeta {
+	return AuditMeta{ActorType: model.AuditActorSystem}
+}
+
+// AuditEvent 待记录的审计事件
+type AuditEvent struct {
+	UserID     uint // 事件涉及的玩家，决定追加到哪条哈希链；为0且操作者是玩家时取操作者
+	Action     string
+	TargetType string
+	TargetID   string
+	Reason     string
+	Before     interface{}
+	After      interface{}
+}
+
+// AuditQuery 审计日志查询条件
+type AuditQuery struct {
+	ActorID    uint
+	Action     string
+	TargetType string
+	TargetID   string
+	RequestID  string
+	StartTime  *time.Time
+	EndTime    *time.Time
+	PageQuery
+}
+
+// AuditVerifyResult 哈希链校验结果
+type AuditVerifyResult struct {
+	Checked  int64  `json:"checked"`
+	Valid    bool   `json:"valid"`
+	BrokenID uint64 `json:"broken_id,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// AuditService 审计服务
+type AuditService struct {
+	db *gorm.DB
+}
+
+// NewAuditService 创建审计服务
+func NewAuditService(db *gorm.DB) *AuditService {
+	return &AuditService{
+		db: db,
+	}
+}
+
+// WithContext 返回绑定请求上下文的服务副本
+func (s *AuditService) WithContext(ctx context.Context) *AuditService {
+	clone := *s
+	clone.db = s.db.WithContext(ctx)
+	return &clone
+}
+
+// Record 记录审计事件（独立事务）
+func (s *AuditService) Record(meta AuditMeta, event AuditEvent) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return s.RecordTx(tx, meta, event)
+	})
+}
+
+// RecordTx 在给定事务中记录审计事件
+// 哈希链按玩家分链：玩家相关事件追加到该玩家自己的链，与玩家无关的事件追加到公共链（链0）
+// 追加时锁定对应链头行，同一玩家的变动本就持有该玩家的钱包锁，不同玩家之间不会互相等待
+func (s *AuditService) RecordTx(tx *gorm.DB, meta AuditMeta, event AuditEvent) error {
+	before, err := encodeAuditValue(event.Before)
+	if err != nil {
+		return err
+	}
+	after, err := encodeAuditValue(event.After)
+	if err != nil {
+		return err
+	}
+
+	chainID := auditChainID(meta, event)
+
+	// 确保链头存在；链0的主键为0，不能交给GORM按零值主键处理
+	if err := tx.Exec("INSERT INTO `audit_chain_head` (`id`, `last_id`, `last_hash`) VALUES (?, 0, ?) ON DUPLICATE KEY UPDATE `id` = `id`",
+		chainID, auditGenesisHash).Error; err != nil {
+		return fmt.Errorf("初始化审计链头失败: %v", err)
+	}
+
+	// 锁定链头
+	var head model.AuditChainHead
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", chainID).First(&head).Error; err != nil {
+		return fmt.Errorf("锁定审计链头失败: %v", err)
+	}
+
+	actorType := meta.ActorType
+	if actorType == "" {
+		actorType = model.AuditActorUser
+	}
+
+	entry := &model.AuditLog{
+		ChainID:     chainID,
+		ActorID:     meta.ActorID,
+		ActorType:   actorType,
+		Action:      event.Action,
+		TargetType:  event.TargetType,
+		TargetID:    event.TargetID,
+		Reason:      event.Reason,
+		BeforeValue: before,
+		AfterValue:  after,
+		RequestID:   meta.RequestID,
+		ClientIP:    meta.ClientIP,
+		PrevHash:    head.LastHash,
+		CreatedAt:   time.Now().Truncate(time.Millisecond),
+	}
+	entry.Hash = computeAuditHash(entry)
+
+	if err := tx.Create(entry).Error; err != nil {
+		return fmt.Errorf("写入审计日志失败: %v", err)
+	}
+
+	return tx.Model(&model.AuditChainHead{}).Where("id = ?", chainID).Updates(map[string]interface{}{
+		"last_id":   entry.ID,
+		"last_hash": entry.Hash,
+	}).Error
+}
+
+// auditChainID 事件所属的哈希链
+func auditChainID(meta AuditMeta, event AuditEvent) uint64 {
+	if event.UserID != 0 {
+		return uint64(event.UserID)
+	}
+	if meta.ActorType == "" || meta.ActorType == model.AuditActorUser {
+		return uint64(meta.ActorID)
+	}
+	return 0
+}
+
+// Query 查询审计日志
+func (s *AuditService) Query(q AuditQuery) ([]model.AuditLog, *Page, error) {
+	db := s.db.Model(&model.AuditLog{})
+	if q.ActorID > 0 {
+		db = db.Where("actor_id = ?", q.ActorID)
+	}
+	if q.Action != "" {
+		db = db.Where("action = ?", q.Action)
+	}
+	if q.TargetType != "" {
+		db = db.Where("target_type = ?", q.TargetType)
+	}
+	if q.TargetID != "" {
+		db = db.Where("target_id = ?", q.TargetID)
+	}
+	if q.RequestID != "" {
+		db = db.Where("request_id = ?", q.RequestID)
+	}
+	if q.StartTime != nil {
+		db = db.Where("created_at >= ?", *q.StartTime)
+	}
+	if q.EndTime != nil {
+		db = db.Where("created_at < ?", *q.EndTime)
+	}
+
+	return paginate(db, q.PageQuery, timeSorts[model.AuditLog](), func(l *model.AuditLog) uint64 { return l.ID })
+}
+
+// VerifyChain 校验哈希链完整性，从fromID开始最多检查limit条，各条链分别校验前后衔接
+func (s *AuditService) VerifyChain(fromID uint64, limit int) (*AuditVerifyResult, error) {
+	result := &AuditVerifyResult{Valid: true}
+
+	var logs []model.AuditLog
+	if err := s.db.Where("id >= ?", fromID).Order("id ASC").Limit(limit).Find(&logs).Error; err != nil {
+		return nil, err
+	}
+
+	// 各条链在检查范围内的前一个哈希
+	prevHashes := make(map[uint64]string)
+	for i := range logs {
+		entry := &logs[i]
+		result.Checked++
+
+		prevHash, ok := prevHashes[entry.ChainID]
+		if !ok {
+			var err error
+			if prevHash, err = s.chainHashBefore(entry.ChainID, fromID); err != nil {
+				return nil, err
+			}
+		}
+
+		if entry.PrevHash != prevHash {
+			result.Valid = false
+			result.BrokenID = entry.ID
+			result.Reason = "前序哈希不匹配"
+			return result, nil
+		}
+
+		if computeAuditHash(entry) != entry.Hash {
+			result.Valid = false
+			result.BrokenID = entry.ID
+			result.Reason = "记录内容与哈希不匹配"
+			return result, nil
+		}
+
+		prevHashes[entry.ChainID] = entry.Hash
+	}
+
+	// 检查到链尾时与各链头比对，发现尾部记录被删除的情况
+	if len(logs) < limit {
+		var heads []model.AuditChainHead
+		if err := s.db.Where("last_id >= ?", fromID).Find(&heads).Error; err != nil {
+			return nil, err
+		}
+		for _, head := range heads {
+			if head.LastHash != prevHashes[head.ID] {
+				result.Valid = false
+				result.BrokenID = head.LastID
+				result.Reason = "链尾与链头记录不一致"
+				return result, nil
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// chainHashBefore 返回链中fromID之前最后一条记录的哈希，没有时为链起点
+func (s *AuditService) chainHashBefore(chainID, fromID uint64) (string, error) {
+	var prev model.AuditLog
+	err := s.db.Where("chain_id = ? AND id < ?", chainID, fromID).Order("id DESC").First(&prev).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return auditGenesisHash, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return prev.Hash, nil
+}
+
+// computeAuditHash 计算审计记录哈希
+func computeAuditHash(entry *model.AuditLog) string {
+	fields := []string{
+		entry.PrevHash,
+		fmt.Sprintf("%d", entry.ActorID),
+		entry.ActorType,
+		entry.Action,
+		entry.TargetType,
+		entry.TargetID,
+		entry.Reason,
+		entry.BeforeValue,
+		entry.AfterValue,
+		entry.RequestID,
+		entry.ClientIP,
+		fmt.Sprintf("%d", entry.CreatedAt.UnixMilli()),
+	}
+
+	// 以JSON数组编码字段，避免分隔符歧义
+	data, _ := json.Marshal(fields)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// encodeAuditValue 将变更前后的值序列化为JSON
+func encodeAuditValue(v interface{}) (string, error) {
+	if v == nil {
+		return "", nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("序列化审计数据失败: %v", err)
+	}
+
+	return string(data), nil
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"testing"
+
+	"game-backend/internal/model"
+)
+
+func TestRecordTxAppendsToPlayerChain(t *testing.T) {
+	tests := []struct {
+		name      string
+		meta      AuditMeta
+		event     AuditEvent
+		wantChain uint64
+	}{
+		{"玩家操作", AuditMeta{ActorID: 12, ActorType: model.AuditActorUser}, AuditEvent{Action: model.AuditActionBetPlace}, 12},
+		{"系统代玩家操作", SystemAuditMeta(), AuditEvent{UserID: 34, Action: model.AuditActionBetAutoCashout}, 34},
+		{"管理员处罚玩家", AuditMeta{ActorID: 1, ActorType: model.AuditActorAdmin}, AuditEvent{UserID: 56, Action: model.AuditActionChatSanction}, 56},
+		{"管理员查询", AuditMeta{ActorID: 1, ActorType: model.AuditActorAdmin}, AuditEvent{Action: model.AuditActionAdminQuery}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDB(t)
+			fake.rows["audit_chain_head"] = []fakeRow{{"id": int64(tt.wantChain), "last_hash": auditGenesisHash}}
+
+			if err := NewAuditService(db).RecordTx(db, tt.meta, tt.event); err != nil {
+				t.Fatalf("记录审计失败: %v", err)
+			}
+
+			// 只初始化、锁定并推进所属链的链头
+			inits := fake.executed("INSERT", "audit_chain_head")
+			if len(inits) != 1 || inits[0].args[0] != int64(tt.wantChain) {
+				t.Fatalf("初始化链头 %v, want 链%d", inits, tt.wantChain)
+			}
+			updates := fake.executed("UPDATE", "audit_chain_head")
+			if len(updates) != 1 || updates[0].args[len(updates[0].args)-1] != int64(tt.wantChain) {
+				t.Fatalf("推进链头 %v, want 链%d", updates, tt.wantChain)
+			}
+		})
+	}
+}
@@ -1,33 +1,51 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"strconv"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 	"game-backend/internal/model"
+	"game-backend/pkg/logger"
+	"game-backend/pkg/money"
 	"game-backend/config"
 )
 
 // AuthService 认证服务
 type AuthService struct {
-	db *gorm.DB
+	db     *gorm.DB
+	wallet *WalletService
+	log    *logrus.Entry
 }
 
 // NewAuthService 创建认证服务
-func NewAuthService(db *gorm.DB) *AuthService {
+func NewAuthService(db *gorm.DB, wallet *WalletService) *AuthService {
 	return &AuthService{
-		db: db,
+		db:     db,
+		wallet: wallet,
+		log:    logrus.NewEntry(logrus.StandardLogger()),
 	}
 }
 
-// ValidateUser 验证用户凭据
+// WithContext 返回绑定请求上下文的服务副本，数据库操作和日志携带请求字段
+func (s *AuthService) WithContext(ctx context.Context) *AuthService {
+	clone := *s
+	clone.db = s.db.WithContext(ctx)
+	clone.log = logger.FromContext(ctx)
+	return &clone
+}
+
+// ValidateUser 验证用户凭据，处于冷静期、自我排除期或游戏会话已超过时长上限的用户不能登录
 func (s *AuthService) ValidateUser(username, password string) (*model.User, error) {
 	var user model.User
 	
 	// 查找用户
-	if err := s.db.Where("username = ? AND status = 1", username).First(&user).Error; err != nil {
+	if err := s.db.Preload("Wallets").Where("username = ? AND status = 1", username).First(&user).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.New("用户不存在")
 		}
@@ -39,6 +57,16 @@ func (s *AuthService) ValidateUser(username, password string) (*model.User, erro
 		return nil, errors.New("密码错误")
 	}
 
+	// 检查冷静期与自我排除
+	if err := checkExclusion(s.db, user.ID); err != nil {
+		return nil, err
+	}
+
+	// 检查游戏会话时长，超过上限后需休息满session_break才能重新登录
+	if err := checkSessionLimit(s.db, user.ID); err != nil {
+		return nil, err
+	}
+
 	return &user, nil
 }
 
@@ -68,34 +96,67 @@ func (s *AuthService) CreateUser(username, password, email string) (*model.User,
 		Username: username,
 		Password: string(hashedPassword),
 		Email:    email,
-		Balance:  100.0, // 新用户初始余额
-		Status:   1,     // 正常状态
+		Status:   1, // 正常状态
 	}
 
-	if err := s.db.Create(user).Error; err != nil {
-		return nil, err
-	}
+	base := money.Base()
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
 
-	// 创建用户统计记录
-	userStats := &model.UserStats{
-		UserID:            user.ID,
-		TotalBets:         0,
-		TotalWinnings:     0,
-		BiggestMultiplier: 0,
-		GamesPlayed:       0,
-	}
+		// 创建基准币种的用户统计记录
+		userStats := &model.UserStats{
+			UserID:            user.ID,
+			Currency:          base.Code,
+			TotalBets:         0,
+			TotalWinnings:     decimal.Zero,
+			BiggestMultiplier: decimal.Zero,
+			GamesPlayed:       0,
+		}
+		if err := tx.Create(userStats).Error; err != nil {
+			return err
+		}
 
-	if err := s.db.Create(userStats).Error; err != nil {
+		// 新用户注册赠送，通过钱包流水入账，配置为0时不赠送
+		bonus := decimal.NewFromFloat(config.AppConfig.Wallet.SignupBonus).RoundFloor(base.Precision)
+		if !bonus.IsPositive() {
+			return nil
+		}
+		userRef := strconv.FormatUint(uint64(user.ID), 10)
+		_, err := s.wallet.PostTx(tx, LedgerEntry{
+			UserID:   user.ID,
+			Currency: base.Code,
+			Amount:   bonus,
+			Type:     model.WalletTxSignupBonus,
+			RefType:  "user",
+			RefID:    userRef,
+		}, SystemAuditMeta(), AuditEvent{
+			Action:     model.AuditActionWalletCredit,
+			TargetType: "user",
+			TargetID:   userRef,
+			Reason:     "新用户注册赠送",
+		})
+		return err
+	})
+	if err != nil {
 		return nil, err
 	}
 
+	s.log.WithFields(logrus.Fields{
+		logger.FieldUserID: user.ID,
+		"username":         user.Username,
+	}).Info("用户注册成功")
+
 	return user, nil
 }
 
 // SaveUserSession 保存用户会话
 func (s *AuthService) SaveUserSession(userID uint, token string) error {
 	// 删除旧会话
-	s.db.Where("user_id = ?", userID).Delete(&model.UserSession{})
+	if err := s.db.Where("user_id = ?", userID).Delete(&model.UserSession{}).Error; err != nil {
+		s.log.WithError(err).WithField(logger.FieldUserID, userID).Warn("删除旧会话失败")
+	}
 
 	// 创建新会话
 	session := &model.UserSession{
@@ -126,10 +187,20 @@ func (s *AuthService) UpdateUserSession(userID uint, token string) error {
 // GetUserByID 根据ID获取用户
 func (s *AuthService) GetUserByID(userID uint) (*model.User, error) {
 	var user model.User
-	err := s.db.Where("id = ? AND status = 1", userID).First(&user).Error
+	err := s.db.Preload("Wallets").Where("id = ? AND status = 1", userID).First(&user).Error
 	return &user, err
 }
 
+// CurrentRole 读取用户当前角色，用户不存在或已禁用时返回空角色
+func (s *AuthService) CurrentRole(userID uint) (string, error) {
+	var user model.User
+	err := s.db.Select("role").Where("id = ? AND status = 1", userID).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	return user.Role, err
+}
+
 // UpdateUser 更新用户信息
 func (s *AuthService) UpdateUser(userID uint, email, avatar string) error {
 	updates := make(map[string]interface{})
@@ -148,20 +219,15 @@ func (s *AuthService) UpdateUser(userID uint, email, avatar string) error {
 	return s.db.Model(&model.User{}).Where("id = ?", userID).Updates(updates).Error
 }
 
-// ValidateToken 验证Token
-func (s *AuthService) ValidateToken(token string) (*model.User, error) {
-	var session model.UserSession
-	
-	// 查找会话
-	if err := s.db.Where("token = ? AND expires_at > ?", token, time.Now()).First(&session).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("会话不存在或已过期")
-		}
-		return nil, err
-	}
-
-	// 获取用户信息
-	return s.GetUserByID(session.UserID)
+// SessionActive 检查令牌对应的登录会话是否仍有效
+// 登出、重新登录、开启冷静期/自我排除会删除会话，账号禁用后会话也不再有效
+func (s *AuthService) SessionActive(token string) (bool, error) {
+	var count int64
+	err := s.db.Model(&model.UserSession{}).
+		Joins("JOIN users ON users.id = user_sessions.user_id AND users.status = 1 AND users.deleted_at IS NULL").
+		Where("user_sessions.token = ? AND user_sessions.expires_at > ?", token, time.Now()).
+		Count(&count).Error
+	return count > 0, err
 }
 
 // CleanExpiredSessions 清理过期会话
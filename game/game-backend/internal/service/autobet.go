@@ -0,0 +1,382 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"game-backend/config"
+	"game-backend/internal/model"
+	"game-backend/pkg/logger"
+	"game-backend/pkg/money"
+)
+
+var (
+	// ErrAutoBetRunning 已有进行中的自动下注
+	ErrAutoBetRunning = errors.New("已有进行中的自动下注")
+	// ErrAutoBetNotRunning 没有进行中的自动下注
+	ErrAutoBetNotRunning = errors.New("没有进行中的自动下注")
+	// ErrInvalidAutoBet 自动下注参数不合法
+	ErrInvalidAutoBet = errors.New("自动下注参数不合法")
+)
+
+// AutoBetParams 自动下注参数，金额为会话币种，币种为空时使用基准币种
+type AutoBetParams struct {
+	Currency         string          `json:"currency"`
+	BaseAmount       decimal.Decimal `json:"base_amount"`
+	AutoCashout      decimal.Decimal `json:"auto_cashout"`
+	Rounds           int             `json:"rounds"`
+	OnWin            string          `json:"on_win"` // reset, multiply
+	OnWinMultiplier  decimal.Decimal `json:"on_win_multiplier"`
+	OnLoss           string          `json:"on_loss"` // reset, multiply
+	OnLossMultiplier decimal.Decimal `json:"on_loss_multiplier"`
+	StopOnProfit     decimal.Decimal `json:"stop_on_profit"` // 0表示不限
+	StopOnLoss       decimal.Decimal `json:"stop_on_loss"`   // 0表示不限
+}
+
+// AutoBetService 自动下注服务：会话的创建、停止以及每局结果结算，下注由游戏循环执行
+type AutoBetService struct {
+	db    *gorm.DB
+	game  *GameService
+	audit *AuditService
+	log   *logrus.Entry
+}
+
+// NewAutoBetService 创建自动下注服务
+func NewAutoBetService(db *gorm.DB, game *GameService, audit *AuditService) *AutoBetService {
+	return &AutoBetService{
+		db:    db,
+		game:  game,
+		audit: audit,
+		log:   logrus.NewEntry(logrus.StandardLogger()),
+	}
+}
+
+// WithContext 返回绑定请求上下文的服务副本，数据库操作和日志携带请求字段
+func (s *AutoBetService) WithContext(ctx context.Context) *AutoBetService {
+	clone := *s
+	clone.db = s.db.WithContext(ctx)
+	clone.log = logger.FromContext(ctx)
+	return &clone
+}
+
+// Validate 校验自动下注参数，下注金额与自动止盈倍数的规则与手动下注相同
+func (s *AutoBetService) Validate(params AutoBetParams) error {
+	_, err := s.validate(&params)
+	return err
+}
+
+// validate 校验自动下注参数，倍数调整取值(0, 100]且最多两位小数，止盈止损不能为负
+func (s *AutoBetService) validate(params *AutoBetParams) (money.Currency, error) {
+	cur, err := money.Lookup(params.Currency)
+	if err != nil {
+		return cur, err
+	}
+	if err := s.game.ValidateBet(cur.Code, params.BaseAmount, params.AutoCashout); err != nil {
+		return cur, err
+	}
+	if !params.AutoCashout.IsPositive() {
+		return cur, ErrInvalidAutoCashout
+	}
+	if params.Rounds < 1 || params.Rounds > config.AppConfig.Game.AutoBetMaxRounds {
+		return cur, ErrInvalidAutoBet
+	}
+
+	for _, adjust := range []struct {
+		mode       string
+		multiplier decimal.Decimal
+	}{
+		{params.OnWin, params.OnWinMultiplier},
+		{params.OnLoss, params.OnLossMultiplier},
+	} {
+		switch adjust.mode {
+		case model.AutoBetAdjustReset:
+		case model.AutoBetAdjustMultiply:
+			if money.ValidateMultiplier(adjust.multiplier) != nil || !adjust.multiplier.IsPositive() ||
+				adjust.multiplier.GreaterThan(decimal.NewFromInt(100)) {
+				return cur, ErrInvalidAutoBet
+			}
+		default:
+			return cur, ErrInvalidAutoBet
+		}
+	}
+
+	for _, threshold := range []decimal.Decimal{params.StopOnProfit, params.StopOnLoss} {
+		if threshold.IsNegative() || !threshold.Equal(threshold.Truncate(cur.Precision)) {
+			return cur, ErrInvalidAutoBet
+		}
+	}
+	return cur, nil
+}
+
+// Start 开启自动下注，每位玩家同时只能有一个进行中的会话，从下一局下注阶段开始执行
+func (s *AutoBetService) Start(userID uint, params AutoBetParams, meta AuditMeta) (*model.AutoBetSession, error) {
+	cur, err := s.validate(&params)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &model.AutoBetSession{
+		UserID:           userID,
+		Status:           model.AutoBetStatusRunning,
+		Currency:         cur.Code,
+		BaseAmount:       params.BaseAmount,
+		CurrentAmount:    params.BaseAmount,
+		AutoCashout:      params.AutoCashout,
+		Rounds:           params.Rounds,
+		OnWin:            params.OnWin,
+		OnWinMultiplier:  params.OnWinMultiplier,
+		OnLoss:           params.OnLoss,
+		OnLossMultiplier: params.OnLossMultiplier,
+		StopOnProfit:     params.StopOnProfit,
+		StopOnLoss:       params.StopOnLoss,
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// 锁定用户行，串行化同一玩家的开启请求
+		if err := lockUserTx(tx, userID); err != nil {
+			return err
+		}
+
+		var running int64
+		if err := tx.Model(&model.AutoBetSession{}).
+			Where("user_id = ? AND status = ?", userID, model.AutoBetStatusRunning).
+			Count(&running).Error; err != nil {
+			return err
+		}
+		if running > 0 {
+			return ErrAutoBetRunning
+		}
+
+		if err := tx.Create(session).Error; err != nil {
+			return err
+		}
+
+		return s.audit.RecordTx(tx, meta, AuditEvent{
+			UserID:     session.UserID,
+			Action:     model.AuditActionAutoBetStart,
+			TargetType: "auto_bet",
+			TargetID:   strconv.FormatUint(session.ID, 10),
+			Reason: fmt.Sprintf("自动下注 %s %s × %d局，自动止盈 %sx", cur.Format(session.BaseAmount), cur.Code,
+				session.Rounds, session.AutoCashout.StringFixed(money.MultiplierScale)),
+			After: session,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.WithFields(logrus.Fields{
+		logger.FieldUserID: userID,
+		"session_id":       session.ID,
+		"currency":         session.Currency,
+		"base_amount":      session.BaseAmount.String(),
+		"rounds":           session.Rounds,
+	}).Info("自动下注已开启")
+
+	return session, nil
+}
+
+// Stop 玩家停止进行中的自动下注，已下注的本局照常结算
+func (s *AutoBetService) Stop(userID uint, meta AuditMeta) (*model.AutoBetSession, error) {
+	var session model.AutoBetSession
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND status = ?", userID, model.AutoBetStatusRunning).
+			First(&session).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrAutoBetNotRunning
+		}
+		if err != nil {
+			return err
+		}
+
+		return s.stopTx(tx, &session, model.AutoBetStopUser, "", meta)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// Current 获取玩家进行中或最近一次的自动下注会话
+func (s *AutoBetService) Current(userID uint) (*model.AutoBetSession, error) {
+	var session model.AutoBetSession
+	err := s.db.Where("user_id = ? AND status = ?", userID, model.AutoBetStatusRunning).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = s.db.Where("user_id = ?", userID).Order("id DESC").First(&session).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// Running 获取所有进行中的自动下注会话ID
+func (s *AutoBetService) Running() ([]uint64, error) {
+	var ids []uint64
+	err := s.db.Model(&model.AutoBetSession{}).
+		Where("status = ?", model.AutoBetStatusRunning).
+		Order("id").
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// Advance 结算会话上一局的下注结果并调整下一局下注金额，检查止盈止损与轮数
+// 返回的bool表示本局是否需要下注；上一局下注尚未结束时跳过本局
+func (s *AutoBetService) Advance(sessionID uint64) (*model.AutoBetSession, bool, error) {
+	var session model.AutoBetSession
+	place := false
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&session, sessionID).Error; err != nil {
+			return err
+		}
+		if session.Status != model.AutoBetStatusRunning {
+			return nil
+		}
+
+		if session.LastBetID != "" {
+			var bet model.Bet
+			if err := tx.Where("bet_id = ?", session.LastBetID).First(&bet).Error; err != nil {
+				return err
+			}
+			switch bet.Status {
+			case 0:
+				return nil
+			case 3:
+				// 玩家撤销了该局下注，不计入轮数与盈亏，下一局金额不变
+				session.RoundsPlayed--
+			default:
+				cur, err := money.Lookup(session.Currency)
+				if err != nil {
+					return err
+				}
+
+				session.Profit = session.Profit.Add(bet.Payout.Sub(bet.Amount))
+				session.CurrentAmount = adjustAutoBet(cur, &session, bet.Payout.GreaterThan(bet.Amount))
+			}
+			session.LastBetID = ""
+		}
+
+		switch {
+		case session.StopOnProfit.IsPositive() && session.Profit.GreaterThanOrEqual(session.StopOnProfit):
+			return s.stopTx(tx, &session, model.AutoBetStopProfit, "", SystemAuditMeta())
+		case session.StopOnLoss.IsPositive() && session.Profit.Neg().GreaterThanOrEqual(session.StopOnLoss):
+			return s.stopTx(tx, &session, model.AutoBetStopLoss, "", SystemAuditMeta())
+		case session.RoundsPlayed >= session.Rounds:
+			now := time.Now()
+			session.Status = model.AutoBetStatusCompleted
+			session.EndedAt = &now
+		default:
+			place = true
+		}
+		return tx.Save(&session).Error
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &session, place, nil
+}
+
+// adjustAutoBet 根据上一局输赢计算下一局下注金额，赔付大于本金为赢，倍数调整后向下取整到币种最小单位
+func adjustAutoBet(cur money.Currency, session *model.AutoBetSession, win bool) decimal.Decimal {
+	mode, multiplier := session.OnLoss, session.OnLossMultiplier
+	if win {
+		mode, multiplier = session.OnWin, session.OnWinMultiplier
+	}
+	if mode == model.AutoBetAdjustMultiply {
+		return session.CurrentAmount.Mul(multiplier).RoundFloor(cur.Precision)
+	}
+	return session.BaseAmount
+}
+
+// RecordBet 记录本局已下注，等待下一局下注阶段结算结果
+func (s *AutoBetService) RecordBet(sessionID uint64, betID string) (*model.AutoBetSession, error) {
+	var session model.AutoBetSession
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&session, sessionID).Error; err != nil {
+			return err
+		}
+
+		// 会话在下注期间被停止时仍记录该下注，本局照常结算
+		session.RoundsPlayed++
+		session.LastBetID = betID
+		return tx.Save(&session).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// Fail 下注失败时停止会话，message为失败原因
+func (s *AutoBetService) Fail(sessionID uint64, message string) (*model.AutoBetSession, error) {
+	var session model.AutoBetSession
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&session, sessionID).Error; err != nil {
+			return err
+		}
+		if session.Status != model.AutoBetStatusRunning {
+			return nil
+		}
+		return s.stopTx(tx, &session, model.AutoBetStopBetFailed, message, SystemAuditMeta())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// stopTx 在事务中停止会话并记录审计日志
+func (s *AutoBetService) stopTx(tx *gorm.DB, session *model.AutoBetSession, reason, message string, meta AuditMeta) error {
+	now := time.Now()
+	session.Status = model.AutoBetStatusStopped
+	session.StopReason = reason
+	session.StopMessage = message
+	session.EndedAt = &now
+
+	if err := tx.Save(session).Error; err != nil {
+		return err
+	}
+
+	err := s.audit.RecordTx(tx, meta, AuditEvent{
+		UserID:     session.UserID,
+		Action:     model.AuditActionAutoBetStop,
+		TargetType: "auto_bet",
+		TargetID:   strconv.FormatUint(session.ID, 10),
+		Reason:     fmt.Sprintf("停止自动下注: %s %s", reason, message),
+		After: map[string]interface{}{
+			"rounds_played": session.RoundsPlayed,
+			"profit":        session.Profit,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	s.log.WithFields(logrus.Fields{
+		logger.FieldUserID: session.UserID,
+		"session_id":       session.ID,
+		"reason":           reason,
+		"rounds_played":    session.RoundsPlayed,
+		"profit":           session.Profit.String(),
+	}).Info("自动下注已停止")
+	return nil
+}
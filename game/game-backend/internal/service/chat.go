@@ -0,0 +1,395 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"game-backend/config"
+	"game-backend/internal/model"
+	"game-backend/pkg/logger"
+)
+
+var (
+	// ErrChatRoomNotFound 聊天室不存在
+	ErrChatRoomNotFound = errors.New("聊天室不存在")
+	// ErrChatMessageEmpty 消息内容为空
+	ErrChatMessageEmpty = errors.New("消息内容不能为空")
+	// ErrChatMessageTooLong 消息内容过长
+	ErrChatMessageTooLong = errors.New("消息内容过长")
+	// ErrChatMuted 已被禁言
+	ErrChatMuted = errors.New("已被禁言")
+	// ErrChatBanned 已被禁止进入聊天室
+	ErrChatBanned = errors.New("已被禁止进入聊天室")
+	// ErrInvalidChatSanction 禁言/封禁参数不合法
+	ErrInvalidChatSanction = errors.New("处罚类型、聊天室或时长不合法")
+	// ErrChatSanctionNotActive 处罚已解除或已过期
+	ErrChatSanctionNotActive = errors.New("处罚已解除或已过期")
+)
+
+// ChatFilter 聊天内容过滤器，返回下发的内容以及是否命中需要审核
+type ChatFilter interface {
+	Filter(content string) (string, bool)
+}
+
+// WordListFilter 屏蔽词过滤器，不区分大小写，命中的字符替换为*
+type WordListFilter struct {
+	words [][]rune
+}
+
+// NewWordListFilter 创建屏蔽词过滤器
+func NewWordListFilter(words []string) *WordListFilter {
+	f := &WordListFilter{}
+	for _, word := range words {
+		if word = strings.TrimSpace(word); word != "" {
+			f.words = append(f.words, lowerRunes(word))
+		}
+	}
+	return f
+}
+
+// Filter 替换内容中的屏蔽词
+func (f *WordListFilter) Filter(content string) (string, bool) {
+	if len(f.words) == 0 {
+		return content, false
+	}
+
+	runes := []rune(content)
+	lowered := lowerRunes(content)
+	flagged := false
+	for _, word := range f.words {
+		for i := 0; i+len(word) <= len(lowered); i++ {
+			if !runesEqual(lowered[i:i+len(word)], word) {
+				continue
+			}
+			for j := i; j < i+len(word); j++ {
+				runes[j] = '*'
+			}
+			flagged = true
+		}
+	}
+	return string(runes), flagged
+}
+
+// lowerRunes 逐字符转小写，与原内容的字符一一对应
+func lowerRunes(s string) []rune {
+	runes := []rune(s)
+	for i, r := range runes {
+		runes[i] = unicode.ToLower(r)
+	}
+	return runes
+}
+
+func runesEqual(a, b []rune) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ChatSanctionParams 禁言/封禁参数，Room为空表示所有聊天室，Minutes为0表示永久
+type ChatSanctionParams struct {
+	UserID  uint   `json:"user_id" binding:"required"`
+	Type    string `json:"type" binding:"required"` // mute, ban
+	Room    string `json:"room"`
+	Reason  string `json:"reason" binding:"required"`
+	Minutes int    `json:"minutes"`
+}
+
+// ChatMessageQuery 聊天消息审核查询条件
+type ChatMessageQuery struct {
+	Room      string
+	UserID    uint
+	Flagged   *bool
+	StartTime *time.Time
+	EndTime   *time.Time
+	PageQuery
+}
+
+// ChatSanctionQuery 禁言/封禁查询条件
+type ChatSanctionQuery struct {
+	UserID uint
+	Active bool // 仅查询仍然有效的处罚
+	PageQuery
+}
+
+// ChatService 聊天服务：消息过滤与持久化、禁言/封禁，聊天室成员与广播由WebSocket中心维护
+type ChatService struct {
+	db     *gorm.DB
+	audit  *AuditService
+	filter ChatFilter
+	log    *logrus.Entry
+}
+
+// NewChatService 创建聊天服务
+func NewChatService(db *gorm.DB, audit *AuditService, filter ChatFilter) *ChatService {
+	return &ChatService{
+		db:     db,
+		audit:  audit,
+		filter: filter,
+		log:    logrus.NewEntry(logrus.StandardLogger()),
+	}
+}
+
+// WithContext 返回绑定请求上下文的服务副本，数据库操作和日志携带请求字段
+func (s *ChatService) WithContext(ctx context.Context) *ChatService {
+	clone := *s
+	clone.db = s.db.WithContext(ctx)
+	clone.log = logger.FromContext(ctx)
+	return &clone
+}
+
+// ValidRoom 聊天室是否存在
+func (s *ChatService) ValidRoom(room string) bool {
+	for _, r := range config.AppConfig.Chat.Rooms {
+		if r == room {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckJoin 检查玩家能否加入聊天室
+func (s *ChatService) CheckJoin(userID uint, room string) error {
+	if !s.ValidRoom(room) {
+		return ErrChatRoomNotFound
+	}
+
+	sanctions, err := s.activeSanctions(userID, room)
+	if err != nil {
+		return err
+	}
+	for _, sanction := range sanctions {
+		if sanction.Type == model.ChatSanctionBan {
+			return sanctionError(ErrChatBanned, &sanction)
+		}
+	}
+	return nil
+}
+
+// Post 校验并过滤消息后持久化，被禁言或封禁时拒绝
+func (s *ChatService) Post(userID uint, username, room, content string) (*model.ChatMessage, error) {
+	if !s.ValidRoom(room) {
+		return nil, ErrChatRoomNotFound
+	}
+
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil, ErrChatMessageEmpty
+	}
+	if utf8.RuneCountInString(content) > config.AppConfig.Chat.MaxLength {
+		return nil, ErrChatMessageTooLong
+	}
+
+	sanctions, err := s.activeSanctions(userID, room)
+	if err != nil {
+		return nil, err
+	}
+	if len(sanctions) > 0 {
+		if sanctions[0].Type == model.ChatSanctionBan {
+			return nil, sanctionError(ErrChatBanned, &sanctions[0])
+		}
+		return nil, sanctionError(ErrChatMuted, &sanctions[0])
+	}
+
+	message := &model.ChatMessage{
+		Room:     room,
+		UserID:   userID,
+		Username: username,
+		Content:  content,
+	}
+	if filtered, flagged := s.filter.Filter(content); flagged {
+		message.Content = filtered
+		message.Original = content
+		message.Flagged = true
+	}
+
+	if err := s.db.Create(message).Error; err != nil {
+		return nil, err
+	}
+
+	if message.Flagged {
+		s.log.WithFields(logrus.Fields{
+			logger.FieldUserID: userID,
+			"room":             room,
+			"message_id":       message.ID,
+		}).Info("聊天消息命中屏蔽词，待审核")
+	}
+	return message, nil
+}
+
+// History 聊天室最近limit条消息，按时间正序
+func (s *ChatService) History(room string, limit int) ([]model.ChatMessage, error) {
+	var messages []model.ChatMessage
+	if limit <= 0 {
+		return messages, nil
+	}
+
+	if err := s.db.Where("room = ?", room).Order("id DESC").Limit(limit).Find(&messages).Error; err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages, nil
+}
+
+// activeSanctions 玩家在聊天室仍然有效的处罚，封禁在前
+func (s *ChatService) activeSanctions(userID uint, room string) ([]model.ChatSanction, error) {
+	var sanctions []model.ChatSanction
+	err := s.db.Where("user_id = ? AND lifted_at IS NULL AND (until IS NULL OR until > ?) AND (room = '' OR room = ?)",
+		userID, time.Now(), room).
+		Order(fmt.Sprintf("type = '%s' DESC, id DESC", model.ChatSanctionBan)).
+		Find(&sanctions).Error
+	return sanctions, err
+}
+
+// sanctionError 在错误中附带处罚截止时间
+func sanctionError(err error, sanction *model.ChatSanction) error {
+	if sanction.Until == nil {
+		return err
+	}
+	return fmt.Errorf("%w，至%s", err, sanction.Until.Format("2006-01-02 15:04"))
+}
+
+// Sanction 禁言或封禁玩家
+func (s *ChatService) Sanction(meta AuditMeta, params ChatSanctionParams) (*model.ChatSanction, error) {
+	if params.Type != model.ChatSanctionMute && params.Type != model.ChatSanctionBan {
+		return nil, ErrInvalidChatSanction
+	}
+	if params.Room != "" && !s.ValidRoom(params.Room) {
+		return nil, ErrInvalidChatSanction
+	}
+	if params.Minutes < 0 {
+		return nil, ErrInvalidChatSanction
+	}
+
+	sanction := &model.ChatSanction{
+		UserID:      params.UserID,
+		Type:        params.Type,
+		Room:        params.Room,
+		Reason:      params.Reason,
+		ModeratorID: meta.ActorID,
+	}
+	if params.Minutes > 0 {
+		until := time.Now().Add(time.Duration(params.Minutes) * time.Minute)
+		sanction.Until = &until
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&model.User{}, params.UserID).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(sanction).Error; err != nil {
+			return err
+		}
+		return s.audit.RecordTx(tx, meta, AuditEvent{
+			UserID:     params.UserID,
+			Action:     model.AuditActionChatSanction,
+			TargetType: "user",
+			TargetID:   strconv.FormatUint(uint64(params.UserID), 10),
+			Reason:     params.Reason,
+			After:      sanction,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.WithFields(logrus.Fields{
+		logger.FieldUserID: params.UserID,
+		"type":             params.Type,
+		"room":             params.Room,
+		"until":            sanction.Until,
+	}).Info("玩家已被禁言/封禁")
+
+	return sanction, nil
+}
+
+// Lift 提前解除处罚
+func (s *ChatService) Lift(meta AuditMeta, id uint) (*model.ChatSanction, error) {
+	var sanction model.ChatSanction
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&sanction, id).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if !sanction.Active(now) {
+			return ErrChatSanctionNotActive
+		}
+
+		before := sanction
+		sanction.LiftedAt = &now
+		sanction.LiftedBy = meta.ActorID
+		if err := tx.Model(&sanction).Updates(map[string]interface{}{
+			"lifted_at": now,
+			"lifted_by": meta.ActorID,
+		}).Error; err != nil {
+			return err
+		}
+
+		return s.audit.RecordTx(tx, meta, AuditEvent{
+			UserID:     sanction.UserID,
+			Action:     model.AuditActionChatSanctionLift,
+			TargetType: "user",
+			TargetID:   strconv.FormatUint(uint64(sanction.UserID), 10),
+			Before:     before,
+			After:      sanction,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.WithFields(logrus.Fields{
+		logger.FieldUserID: sanction.UserID,
+		"sanction_id":      sanction.ID,
+	}).Info("禁言/封禁已解除")
+
+	return &sanction, nil
+}
+
+// Messages 查询聊天消息供审核
+func (s *ChatService) Messages(q ChatMessageQuery) ([]model.ChatMessage, *Page, error) {
+	db := s.db.Model(&model.ChatMessage{})
+	if q.Room != "" {
+		db = db.Where("room = ?", q.Room)
+	}
+	if q.UserID > 0 {
+		db = db.Where("user_id = ?", q.UserID)
+	}
+	if q.Flagged != nil {
+		db = db.Where("flagged = ?", *q.Flagged)
+	}
+	if q.StartTime != nil {
+		db = db.Where("created_at >= ?", *q.StartTime)
+	}
+	if q.EndTime != nil {
+		db = db.Where("created_at < ?", *q.EndTime)
+	}
+
+	return paginate(db, q.PageQuery, timeSorts[model.ChatMessage](), func(m *model.ChatMessage) uint64 { return m.ID })
+}
+
+// Sanctions 查询禁言/封禁记录
+func (s *ChatService) Sanctions(q ChatSanctionQuery) ([]model.ChatSanction, *Page, error) {
+	db := s.db.Model(&model.ChatSanction{})
+	if q.UserID > 0 {
+		db = db.Where("user_id = ?", q.UserID)
+	}
+	if q.Active {
+		db = db.Where("lifted_at IS NULL AND (until IS NULL OR until > ?)", time.Now())
+	}
+
+	return paginate(db, q.PageQuery, timeSorts[model.ChatSanction](), func(s *model.ChatSanction) uint64 { return uint64(s.ID) })
+}
@@ -1,178 +1,503 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"game-backend/internal/model"
+	"game-backend/pkg/logger"
+	"game-backend/pkg/metrics"
+	"game-backend/pkg/money"
 	"game-backend/config"
 )
 
+var (
+	// ErrInsufficientBalance 余额不足
+	ErrInsufficientBalance = errors.New("余额不足")
+	// ErrBetNotActive 下注已处理
+	ErrBetNotActive = errors.New("下注已处理")
+	// ErrBetNotOwned 下注不属于当前用户
+	ErrBetNotOwned = errors.New("无权操作此下注")
+	// ErrBetBelowMin 下注金额小于最小限制
+	ErrBetBelowMin = errors.New("下注金额不能小于最小限制")
+	// ErrBetAboveMax 下注金额大于最大限制
+	ErrBetAboveMax = errors.New("下注金额不能大于最大限制")
+	// ErrInvalidAutoCashout 自动止盈倍数不合法
+	ErrInvalidAutoCashout = errors.New("自动止盈倍数超出范围或超过两位小数")
+	// ErrInvalidCashoutFraction 止盈比例不合法
+	ErrInvalidCashoutFraction = errors.New("止盈比例需大于0且不超过1")
+	// ErrCashoutTooSmall 部分止盈的本金不足币种最小单位
+	ErrCashoutTooSmall = errors.New("止盈金额过小")
+)
+
+// fullCashout 全部止盈
+var fullCashout = decimal.NewFromInt(1)
+
 // GameService 游戏服务
 type GameService struct {
-	db *gorm.DB
+	db          *gorm.DB
+	wallet      *WalletService
+	responsible *ResponsibleService
+	leaderboard *LeaderboardService
+	log         *logrus.Entry
 }
 
 // NewGameService 创建游戏服务
-func NewGameService(db *gorm.DB) *GameService {
+func NewGameService(db *gorm.DB, wallet *WalletService, responsible *ResponsibleService, leaderboard *LeaderboardService) *GameService {
 	return &GameService{
-		db: db,
+		db:          db,
+		wallet:      wallet,
+		responsible: responsible,
+		leaderboard: leaderboard,
+		log:         logrus.NewEntry(logrus.StandardLogger()),
 	}
 }
 
-// CreateBet 创建下注记录
-func (s *GameService) CreateBet(userID uint, amount, autoCashout float64) (*model.Bet, error) {
-	betID := fmt.Sprintf("bet_%d_%d", userID, time.Now().Unix())
-	
-	bet := &model.Bet{
-		BetID:       betID,
-		UserID:      userID,
-		GameID:      "crash_001",
-		Amount:      amount,
-		AutoCashout: autoCashout,
-		Status:      0, // 进行中
+// WithContext 返回绑定请求上下文的服务副本，数据库操作和日志携带请求字段
+func (s *GameService) WithContext(ctx context.Context) *GameService {
+	clone := *s
+	clone.db = s.db.WithContext(ctx)
+	clone.log = logger.FromContext(ctx)
+	return &clone
+}
+
+// ValidateBet 校验下注币种、金额与自动止盈倍数（0表示手动止盈）
+func (s *GameService) ValidateBet(currency string, amount, autoCashout decimal.Decimal) error {
+	cur, err := money.Lookup(currency)
+	if err != nil {
+		return err
+	}
+	if err := cur.ValidateAmount(amount); err != nil {
+		return err
+	}
+	if amount.LessThan(cur.MinBet) {
+		return ErrBetBelowMin
 	}
+	if amount.GreaterThan(cur.MaxBet) {
+		return ErrBetAboveMax
+	}
+
+	if autoCashout.IsZero() {
+		return nil
+	}
+	if money.ValidateMultiplier(autoCashout) != nil ||
+		autoCashout.LessThan(s.GetMinMultiplier()) ||
+		autoCashout.GreaterThan(s.GetMaxMultiplier()) {
+		return ErrInvalidAutoCashout
+	}
+	return nil
+}
 
-	if err := s.db.Create(bet).Error; err != nil {
+// PlaceBet 下注：在同一事务中检查玩家限额、创建下注记录、扣除对应币种余额并记账，currency为空时使用基准币种
+// roundID与slot由游戏循环分配，同一玩家同一局的下注位唯一
+func (s *GameService) PlaceBet(userID uint, roundID string, slot int, currency string, amount, autoCashout decimal.Decimal, meta AuditMeta) (*model.Bet, error) {
+	cur, err := money.Lookup(currency)
+	if err != nil {
 		return nil, err
 	}
 
+	var bet *model.Bet
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// 检查自我限制、会话时长与下注/亏损限额
+		if err := s.responsible.CheckBetTx(tx, userID, cur.ToBase(amount)); err != nil {
+			return err
+		}
+		// 任一币种存在拒付欠款时不能用其他币种下注
+		if err := checkWalletDebtTx(tx, userID); err != nil {
+			return err
+		}
+
+		var err error
+		if bet, err = createBetTx(tx, userID, roundID, slot, cur.Code, amount, autoCashout); err != nil {
+			return err
+		}
+
+		_, err = s.wallet.PostTx(tx, LedgerEntry{
+			UserID:   userID,
+			Currency: cur.Code,
+			Amount:   amount.Neg(),
+			Type:     model.WalletTxBet,
+			RefType:  "bet",
+			RefID:    bet.BetID,
+		}, meta, AuditEvent{
+			Action:     model.AuditActionBetPlace,
+			TargetType: "bet",
+			TargetID:   bet.BetID,
+			Reason:     fmt.Sprintf("下注 %s %s", cur.Format(amount), cur.Code),
+		})
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.ObserveBet(cur.ToBase(bet.Amount).InexactFloat64())
+	s.log.WithFields(logrus.Fields{
+		logger.FieldBetID:   bet.BetID,
+		logger.FieldRoundID: bet.RoundID,
+		"slot":              bet.Slot,
+		"currency":          bet.Currency,
+		"amount":            bet.Amount.String(),
+		"auto_cashout":      bet.AutoCashout.String(),
+	}).Info("下注成功")
+
 	return bet, nil
 }
 
-// GetBetByID 根据ID获取下注记录
-func (s *GameService) GetBetByID(betID string) (*model.Bet, error) {
+// CancelBet 撤销下注：在同一事务中将下注标记为已撤销并全额退回本金，只能撤销尚未结算的下注
+// 是否处于下注阶段由游戏循环判断
+func (s *GameService) CancelBet(userID uint, betID string, meta AuditMeta) (*model.Bet, error) {
 	var bet model.Bet
-	err := s.db.Where("bet_id = ?", betID).First(&bet).Error
-	return &bet, err
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("bet_id = ?", betID).First(&bet).Error; err != nil {
+			return err
+		}
+
+		if bet.UserID != userID {
+			return ErrBetNotOwned
+		}
+		if bet.Status != 0 || !bet.RemainingAmount.Equal(bet.Amount) {
+			return ErrBetNotActive
+		}
+
+		cur, err := money.Lookup(bet.Currency)
+		if err != nil {
+			return err
+		}
+
+		result := tx.Model(&model.Bet{}).Where("id = ? AND status = 0", bet.ID).Updates(map[string]interface{}{
+			"status":           3, // 已撤销
+			"remaining_amount": decimal.Zero,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrBetNotActive
+		}
+		bet.Status = 3
+		bet.RemainingAmount = decimal.Zero
+
+		_, err = s.wallet.PostTx(tx, LedgerEntry{
+			UserID:   userID,
+			Currency: cur.Code,
+			Amount:   bet.Amount,
+			Type:     model.WalletTxBetRefund,
+			RefType:  "bet",
+			RefID:    bet.BetID,
+		}, meta, AuditEvent{
+			Action:     model.AuditActionBetCancel,
+			TargetType: "bet",
+			TargetID:   bet.BetID,
+			Reason:     fmt.Sprintf("撤销下注 退回 %s %s", cur.Format(bet.Amount), cur.Code),
+		})
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if cur, err := money.Lookup(bet.Currency); err == nil {
+		metrics.ObserveBetCancel(cur.ToBase(bet.Amount).InexactFloat64())
+	}
+	s.log.WithFields(logrus.Fields{
+		logger.FieldBetID:   bet.BetID,
+		logger.FieldRoundID: bet.RoundID,
+		"slot":              bet.Slot,
+		"currency":          bet.Currency,
+		"amount":            bet.Amount.String(),
+	}).Info("撤销下注成功")
+
+	return &bet, nil
 }
 
-// UpdateBetCashout 更新下注止盈信息
-func (s *GameService) UpdateBetCashout(betID string, multiplier, payout float64) error {
-	now := time.Now()
-	return s.db.Model(&model.Bet{}).Where("bet_id = ?", betID).Updates(map[string]interface{}{
-		"multiplier":   multiplier,
-		"payout":       payout,
-		"status":       1, // 已止盈
-		"cashout_time": &now,
-	}).Error
+// ValidateCashoutFraction 校验止盈比例，取值(0, 1]，1表示全部止盈
+func (s *GameService) ValidateCashoutFraction(fraction decimal.Decimal) error {
+	if !fraction.IsPositive() || fraction.GreaterThan(fullCashout) {
+		return ErrInvalidCashoutFraction
+	}
+	return nil
 }
 
-// UpdateBetCrash 更新下注崩盘信息
-func (s *GameService) UpdateBetCrash(betID string) error {
-	return s.db.Model(&model.Bet{}).Where("bet_id = ?", betID).Updates(map[string]interface{}{
-		"status": 2, // 已崩盘
-	}).Error
+// CashoutBet 止盈：按比例结算剩余本金，在同一事务中更新下注、写入结算明细、增加余额并写入审计日志
+// fraction为剩余本金的止盈比例，小于1时为部分止盈，其余本金继续参与本局
+func (s *GameService) CashoutBet(userID uint, betID string, multiplier, fraction decimal.Decimal, meta AuditMeta) (*model.Bet, *model.BetSettlement, error) {
+	var bet model.Bet
+	var settlement *model.BetSettlement
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// 锁定下注记录，防止重复止盈
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("bet_id = ?", betID).First(&bet).Error; err != nil {
+			return err
+		}
+
+		if bet.UserID != userID {
+			return ErrBetNotOwned
+		}
+
+		var err error
+		settlement, err = s.settleCashoutTx(tx, &bet, multiplier, fraction, meta, model.AuditActionBetCashout)
+		return err
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	observeCashout(bet.Currency, settlement)
+	s.recordCashout(&bet, settlement)
+	s.log.WithFields(logrus.Fields{
+		logger.FieldBetID:   bet.BetID,
+		logger.FieldRoundID: bet.RoundID,
+		"currency":          bet.Currency,
+		"stake":             settlement.Stake.String(),
+		"multiplier":        settlement.Multiplier.String(),
+		"payout":            settlement.Payout.String(),
+		"remaining":         bet.RemainingAmount.String(),
+	}).Info("止盈成功")
+
+	return &bet, settlement, nil
 }
 
-// GetUserBetHistory 获取用户下注历史
-func (s *GameService) GetUserBetHistory(userID uint, page, pageSize int) ([]model.Bet, int64, error) {
-	var bets []model.Bet
-	var total int64
+// SystemCashoutBet 系统结算一笔下注的全部剩余本金（自动止盈或风控强制止盈），action区分审计动作
+func (s *GameService) SystemCashoutBet(betID string, multiplier decimal.Decimal, action string) (*model.Bet, *model.BetSettlement, error) {
+	var bet model.Bet
+	var settlement *model.BetSettlement
 
-	// 获取总数
-	if err := s.db.Model(&model.Bet{}).Where("user_id = ?", userID).Count(&total).Error; err != nil {
-		return nil, 0, err
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("bet_id = ?", betID).First(&bet).Error; err != nil {
+			return err
+		}
+
+		var err error
+		settlement, err = s.settleCashoutTx(tx, &bet, multiplier, fullCashout, SystemAuditMeta(), action)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
 	}
 
-	// 获取分页数据
-	offset := (page - 1) * pageSize
-	err := s.db.Where("user_id = ?", userID).
-		Order("created_at DESC").
-		Offset(offset).
-		Limit(pageSize).
-		Find(&bets).Error
+	observeCashout(bet.Currency, settlement)
+	s.recordCashout(&bet, settlement)
 
-	return bets, total, err
+	return &bet, settlement, nil
 }
 
-// GetGameHistory 获取游戏历史
-func (s *GameService) GetGameHistory(page, pageSize int) ([]model.GameHistory, int64, error) {
-	var games []model.GameHistory
-	var total int64
+// settleCashoutTx 在事务中按比例结算一笔进行中下注的剩余本金，倍数截断到两位小数，本金与赔付向下取整到币种最小单位
+// 剩余本金全部结算后下注变为已止盈，否则保持进行中
+func (s *GameService) settleCashoutTx(tx *gorm.DB, bet *model.Bet, multiplier, fraction decimal.Decimal, meta AuditMeta, action string) (*model.BetSettlement, error) {
+	if bet.Status != 0 {
+		return nil, ErrBetNotActive
+	}
 
-	// 获取总数
-	if err := s.db.Model(&model.GameHistory{}).Count(&total).Error; err != nil {
-		return nil, 0, err
+	cur, err := money.Lookup(bet.Currency)
+	if err != nil {
+		return nil, err
 	}
 
-	// 获取分页数据
-	offset := (page - 1) * pageSize
-	err := s.db.Order("created_at DESC").
-		Offset(offset).
-		Limit(pageSize).
-		Find(&games).Error
+	stake := bet.RemainingAmount
+	if fraction.LessThan(fullCashout) {
+		stake = stake.Mul(fraction).RoundFloor(cur.Precision)
+		if !stake.IsPositive() {
+			return nil, ErrCashoutTooSmall
+		}
+	}
 
-	return games, total, err
-}
+	now := time.Now()
+	multiplier = money.TruncMultiplier(multiplier)
+	payout := cur.Payout(stake, multiplier)
+	remaining := bet.RemainingAmount.Sub(stake)
+
+	updates := map[string]interface{}{
+		"multiplier":       multiplier,
+		"payout":           bet.Payout.Add(payout),
+		"remaining_amount": remaining,
+		"cashout_time":     &now,
+	}
+	if remaining.IsZero() {
+		updates["status"] = 1 // 已止盈
+	}
+	// 按读取到的剩余本金条件更新，下注已被其他结算修改时不再赔付
+	result := tx.Model(&model.Bet{}).Where("id = ? AND status = 0 AND remaining_amount = ?", bet.ID, bet.RemainingAmount).Updates(updates)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrBetNotActive
+	}
+
+	settlement := &model.BetSettlement{
+		BetID:      bet.BetID,
+		Type:       settlementType(action),
+		Stake:      stake,
+		Multiplier: multiplier,
+		Payout:     payout,
+	}
+	if err := tx.Create(settlement).Error; err != nil {
+		return nil, err
+	}
 
-// GetLeaderboard 获取排行榜
-func (s *GameService) GetLeaderboard() ([]model.Leaderboard, error) {
-	var leaderboard []model.Leaderboard
-	
-	err := s.db.Order("total_winnings DESC").
-		Limit(100).
-		Find(&leaderboard).Error
+	bet.Multiplier = multiplier
+	bet.Payout = bet.Payout.Add(payout)
+	bet.RemainingAmount = remaining
+	bet.CashoutTime = &now
+	if remaining.IsZero() {
+		bet.Status = 1
+	}
 
-	return leaderboard, err
+	if err := recordStatsTx(tx, bet, settlement, now); err != nil {
+		return nil, err
+	}
+
+	reason := fmt.Sprintf("止盈 %sx 赔付 %s %s", multiplier.StringFixed(money.MultiplierScale), cur.Format(payout), cur.Code)
+	if !remaining.IsZero() {
+		reason = fmt.Sprintf("部分止盈 本金 %s %s × %sx 赔付 %s %s，剩余本金 %s %s",
+			cur.Format(stake), cur.Code, multiplier.StringFixed(money.MultiplierScale), cur.Format(payout), cur.Code, cur.Format(remaining), cur.Code)
+	}
+
+	_, err = s.wallet.PostTx(tx, LedgerEntry{
+		UserID:   bet.UserID,
+		Currency: cur.Code,
+		Amount:   payout,
+		Type:     model.WalletTxPayout,
+		RefType:  "bet",
+		RefID:    bet.BetID,
+	}, meta, AuditEvent{
+		Action:     action,
+		TargetType: "bet",
+		TargetID:   bet.BetID,
+		Reason:     reason,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return settlement, nil
 }
 
-// GetUserStats 获取用户统计
-func (s *GameService) GetUserStats(userID uint) (*model.UserStats, error) {
-	var stats model.UserStats
-	err := s.db.Where("user_id = ?", userID).First(&stats).Error
-	return &stats, err
+// settlementType 根据审计动作确定结算类型
+func settlementType(action string) string {
+	switch action {
+	case model.AuditActionBetAutoCashout:
+		return model.SettlementAuto
+	case model.AuditActionBetForced:
+		return model.SettlementForced
+	default:
+		return model.SettlementManual
+	}
 }
 
-// UpdateUserStats 更新用户统计
-func (s *GameService) UpdateUserStats(userID uint, betAmount, payout, multiplier float64) error {
-	stats := &model.UserStats{}
-	
-	// 查找或创建统计记录
-	if err := s.db.Where("user_id = ?", userID).First(stats).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			// 创建新统计记录
-			stats = &model.UserStats{
-				UserID:            userID,
-				TotalBets:         1,
-				TotalWinnings:     payout,
-				BiggestMultiplier: multiplier,
-				GamesPlayed:       1,
-			}
-			return s.db.Create(stats).Error
-		}
-		return err
+// observeCashout 记录止盈指标，按结算类型区分，金额折算为基准币种
+func observeCashout(currency string, settlement *model.BetSettlement) {
+	cur, err := money.Lookup(currency)
+	if err != nil {
+		return
 	}
+	metrics.ObserveCashout(settlement.Type, cur.ToBase(settlement.Payout).InexactFloat64())
+}
 
-	// 更新统计信息
-	updates := map[string]interface{}{
-		"total_bets":     stats.TotalBets + 1,
-		"total_winnings": stats.TotalWinnings + payout,
-		"games_played":   stats.GamesPlayed + 1,
+// recordCashout 止盈结算计入排行榜，Redis失败只记录日志，不影响已提交的结算
+func (s *GameService) recordCashout(bet *model.Bet, settlement *model.BetSettlement) {
+	err := s.leaderboard.RecordCashout(bet.UserID, bet.Currency, settlement.Stake, settlement.Payout, settlement.Multiplier, settlement.CreatedAt)
+	if err != nil {
+		s.log.WithError(err).WithFields(logrus.Fields{
+			logger.FieldUserID: bet.UserID,
+			logger.FieldBetID:  bet.BetID,
+		}).Warn("更新排行榜失败")
 	}
+}
 
-	// 更新最大倍数
-	if multiplier > stats.BiggestMultiplier {
-		updates["biggest_multiplier"] = multiplier
+// createBetTx 在给定连接或事务中创建下注记录，下注ID使用UUID避免同一秒内多次下注冲突
+func createBetTx(tx *gorm.DB, userID uint, roundID string, slot int, currency string, amount, autoCashout decimal.Decimal) (*model.Bet, error) {
+	bet := &model.Bet{
+		BetID:           "bet_" + uuid.NewString(),
+		UserID:          userID,
+		GameID:          "crash_001",
+		RoundID:         roundID,
+		Slot:            slot,
+		Currency:        currency,
+		Amount:          amount,
+		AutoCashout:     autoCashout,
+		RemainingAmount: amount,
+		Status:          0, // 进行中
+	}
+
+	if err := tx.Create(bet).Error; err != nil {
+		return nil, err
 	}
 
-	return s.db.Model(stats).Updates(updates).Error
+	return bet, nil
 }
 
-// DeductUserBalance 扣除用户余额
-func (s *GameService) DeductUserBalance(userID uint, amount float64) error {
-	return s.db.Model(&model.User{}).
-		Where("id = ?", userID).
-		Update("balance", gorm.Expr("balance - ?", amount)).Error
+// GetBetByID 根据ID获取下注记录
+func (s *GameService) GetBetByID(betID string) (*model.Bet, error) {
+	var bet model.Bet
+	err := s.db.Where("bet_id = ?", betID).First(&bet).Error
+	return &bet, err
+}
+
+// loadSettlements 加载下注的结算明细，按结算顺序排列
+func loadSettlements(db *gorm.DB, bets []model.Bet) error {
+	if len(bets) == 0 {
+		return nil
+	}
+
+	betIDs := make([]string, len(bets))
+	for i := range bets {
+		betIDs[i] = bets[i].BetID
+	}
+
+	var settlements []model.BetSettlement
+	if err := db.Where("bet_id IN ?", betIDs).Order("id").Find(&settlements).Error; err != nil {
+		return err
+	}
+
+	byBet := make(map[string][]model.BetSettlement, len(bets))
+	for _, settlement := range settlements {
+		byBet[settlement.BetID] = append(byBet[settlement.BetID], settlement)
+	}
+	for i := range bets {
+		bets[i].Settlements = byBet[bets[i].BetID]
+	}
+	return nil
 }
 
-// AddUserBalance 增加用户余额
-func (s *GameService) AddUserBalance(userID uint, amount float64) error {
-	return s.db.Model(&model.User{}).
-		Where("id = ?", userID).
-		Update("balance", gorm.Expr("balance + ?", amount)).Error
+// GetCurrencyLeaderboard 按单一币种的累计赔付排名，不折算汇率
+func (s *GameService) GetCurrencyLeaderboard(currency string, limit int) ([]LeaderboardEntry, error) {
+	cur, err := money.Lookup(currency)
+	if err != nil {
+		return nil, err
+	}
+
+	var leaderboard []LeaderboardEntry
+	err = s.db.Table("user_stats us").
+		Select("us.user_id, u.username, us.total_winnings AS score").
+		Joins("JOIN users u ON u.id = us.user_id AND u.status = 1").
+		Where("us.currency = ?", cur.Code).
+		Order("us.total_winnings DESC").
+		Limit(limit).
+		Scan(&leaderboard).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range leaderboard {
+		leaderboard[i].Rank = int32(i + 1)
+	}
+	return leaderboard, nil
 }
 
 // GetUserByID 根据ID获取用户
@@ -182,102 +507,104 @@ func (s *GameService) GetUserByID(userID uint) (*model.User, error) {
 	return &user, err
 }
 
-// CreateGameHistory 创建游戏历史记录
-func (s *GameService) CreateGameHistory(roundID, gameID string, finalMultiplier float64, playersCount int32, totalBets, totalPayout float64, winnersCount int32) error {
+// RecordRound 写入一局的游戏历史，在该局下注全部结算后调用
+// 按已结束的下注汇总玩家数、下注数、投注额、赔付与赢家数（赔付大于本金的玩家），金额折算为基准币种
+func (s *GameService) RecordRound(roundID, gameID string, crashPoint decimal.Decimal, startTime, endTime time.Time) (*model.GameHistory, error) {
+	var bets []model.Bet
+	if err := s.db.Select("user_id", "currency", "amount", "payout").
+		Where("round_id = ? AND status IN ?", roundID, []int{1, 2}).Find(&bets).Error; err != nil {
+		return nil, err
+	}
+
 	history := &model.GameHistory{
 		RoundID:         roundID,
 		GameID:          gameID,
-		FinalMultiplier: finalMultiplier,
-		PlayersCount:    playersCount,
-		TotalBets:       totalBets,
-		TotalPayout:     totalPayout,
-		WinnersCount:    winnersCount,
-		StartTime:       time.Now().Add(-30 * time.Second), // 假设游戏持续30秒
-		EndTime:         time.Now(),
+		FinalMultiplier: money.TruncMultiplier(crashPoint),
+		BetsCount:       int32(len(bets)),
+		StartTime:       startTime,
+		EndTime:         endTime,
 	}
-
-	return s.db.Create(history).Error
-}
-
-// UpdateLeaderboard 更新排行榜
-func (s *GameService) UpdateLeaderboard() error {
-	// 删除旧排行榜数据
-	if err := s.db.Exec("DELETE FROM leaderboard").Error; err != nil {
-		return err
+	players := make(map[uint]bool)
+	winners := make(map[uint]bool)
+	for _, bet := range bets {
+		cur, err := money.Lookup(bet.Currency)
+		if err != nil {
+			return nil, err
+		}
+		history.TotalBets = history.TotalBets.Add(cur.ToBase(bet.Amount))
+		history.TotalPayout = history.TotalPayout.Add(cur.ToBase(bet.Payout))
+		players[bet.UserID] = true
+		if bet.Payout.GreaterThan(bet.Amount) {
+			winners[bet.UserID] = true
+		}
 	}
+	history.PlayersCount = int32(len(players))
+	history.WinnersCount = int32(len(winners))
 
-	// 重新计算排行榜
-	sql := `
-		INSERT INTO leaderboard (user_id, username, total_winnings, biggest_multiplier, rank, updated_at)
-		SELECT 
-			u.id as user_id,
-			u.username,
-			COALESCE(us.total_winnings, 0) as total_winnings,
-			COALESCE(us.biggest_multiplier, 0) as biggest_multiplier,
-			ROW_NUMBER() OVER (ORDER BY COALESCE(us.total_winnings, 0) DESC) as rank,
-			NOW() as updated_at
-		FROM users u
-		LEFT JOIN user_stats us ON u.id = us.user_id
-		WHERE u.status = 1
-		ORDER BY COALESCE(us.total_winnings, 0) DESC
-		LIMIT 100
-	`
-
-	return s.db.Exec(sql).Error
-}
-
-// GetMinBetAmount 获取最小下注金额
-func (s *GameService) GetMinBetAmount() float64 {
-	return config.AppConfig.Game.MinBetAmount
-}
-
-// GetMaxBetAmount 获取最大下注金额
-func (s *GameService) GetMaxBetAmount() float64 {
-	return config.AppConfig.Game.MaxBetAmount
+	// 同一局只记录一次
+	if err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(history).Error; err != nil {
+		return nil, err
+	}
+	return history, nil
 }
 
 // GetMinMultiplier 获取最小倍数
-func (s *GameService) GetMinMultiplier() float64 {
-	return config.AppConfig.Game.MinMultiplier
+func (s *GameService) GetMinMultiplier() decimal.Decimal {
+	return money.TruncMultiplier(decimal.NewFromFloat(config.AppConfig.Game.MinMultiplier))
 }
 
 // GetMaxMultiplier 获取最大倍数
-func (s *GameService) GetMaxMultiplier() float64 {
-	return config.AppConfig.Game.MaxMultiplier
+func (s *GameService) GetMaxMultiplier() decimal.Decimal {
+	return money.TruncMultiplier(decimal.NewFromFloat(config.AppConfig.Game.MaxMultiplier))
 }
 
-// ProcessAutoCashouts 处理自动止盈
-func (s *GameService) ProcessAutoCashouts(currentMultiplier float64) error {
-	// 查找需要自动止盈的下注
-	var bets []model.Bet
-	err := s.db.Where("status = 0 AND auto_cashout > 0 AND auto_cashout <= ?", currentMultiplier).Find(&bets).Error
-	if err != nil {
-		return err
+// CrashBets 将指定的进行中下注标记为崩盘，未止盈的剩余本金计入排行榜亏损
+func (s *GameService) CrashBets(betIDs []string) error {
+	if len(betIDs) == 0 {
+		return nil
 	}
 
-	// 处理每个自动止盈
-	for _, bet := range bets {
-		payout := bet.Amount * currentMultiplier
-		
-		// 更新下注状态
-		if err := s.UpdateBetCashout(bet.BetID, currentMultiplier, payout); err != nil {
-			continue
+	now := time.Now()
+	var bets []model.Bet
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// 锁定后再更新，与并发止盈互斥，计入亏损的剩余本金与标记崩盘时一致
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("bet_id IN ? AND status = 0", betIDs).Order("user_id, id").Find(&bets).Error; err != nil {
+			return err
+		}
+		if len(bets) == 0 {
+			return nil
 		}
 
-		// 增加用户余额
-		if err := s.AddUserBalance(bet.UserID, payout); err != nil {
-			continue
+		ids := make([]uint, len(bets))
+		for i := range bets {
+			ids[i] = bets[i].ID
+		}
+		if err := tx.Model(&model.Bet{}).Where("id IN ?", ids).Update("status", 2).Error; err != nil {
+			return err
 		}
 
-		// 更新用户统计
-		s.UpdateUserStats(bet.UserID, bet.Amount, payout, currentMultiplier)
+		// 按用户顺序更新统计，避免与其他结算事务死锁
+		for i := range bets {
+			bets[i].Status = 2
+			if err := recordStatsTx(tx, &bets[i], nil, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
+	for i := range bets {
+		bet := &bets[i]
+		if err := s.leaderboard.RecordLoss(bet.UserID, bet.Currency, bet.RemainingAmount, now); err != nil {
+			s.log.WithError(err).WithFields(logrus.Fields{
+				logger.FieldUserID: bet.UserID,
+				logger.FieldBetID:  bet.BetID,
+			}).Warn("更新排行榜失败")
+		}
+	}
 	return nil
 }
-
-// ProcessCrashedBets 处理崩盘的下注
-func (s *GameService) ProcessCrashedBets() error {
-	// 将所有进行中的下注标记为崩盘
-	return s.db.Model(&model.Bet{}).Where("status = 0").Update("status", 2).Error
-}
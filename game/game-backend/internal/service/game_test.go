@@ -0,0 +1,93 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"game-backend/internal/model"
+)
+
+func TestSettleCashoutTx(t *testing.T) {
+	setupCurrencies(t)
+
+	tests := []struct {
+		name          string
+		status        int
+		remaining     string
+		fraction      string
+		multiplier    string
+		stale         bool // 下注已被并发结算修改，条件更新未命中
+		wantStake     string
+		wantPayout    string
+		wantRemaining string
+		wantErr       error
+	}{
+		{"全部止盈", 0, "100", "1", "2.567", false, "100", "256", "0", nil},
+		{"部分止盈", 0, "100", "0.3", "1.5", false, "30", "45", "70", nil},
+		{"部分止盈本金向下取整", 0, "10.01", "0.5", "1.99", false, "5", "9.95", "5.01", nil},
+		{"止盈金额过小", 0, "0.01", "0.5", "2", false, "", "", "", ErrCashoutTooSmall},
+		{"下注已结束", 1, "0", "1", "2", false, "", "", "", ErrBetNotActive},
+		{"并发结算后不再赔付", 0, "100", "1", "2", true, "", "", "", ErrBetNotActive},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDB(t)
+			fake.rows["wallets"] = []fakeRow{{"id": int64(7), "user_id": int64(1), "currency": "CNY", "balance": "0"}}
+			fake.rows["user_stats"] = []fakeRow{{"user_id": int64(1), "currency": "CNY"}}
+			fake.rows["audit_chain_head"] = []fakeRow{{"id": int64(1), "last_hash": auditGenesisHash}}
+			if tt.stale {
+				fake.affected["bets"] = 0
+			}
+
+			game := NewGameService(db, NewWalletService(db, NewAuditService(db)), nil, nil)
+			bet := &model.Bet{
+				ID:              1,
+				BetID:           "bet_1",
+				UserID:          1,
+				Currency:        "CNY",
+				Amount:          decimal.NewFromInt(100),
+				RemainingAmount: decimal.RequireFromString(tt.remaining),
+				Status:          tt.status,
+			}
+
+			settlement, err := game.settleCashoutTx(db, bet, decimal.RequireFromString(tt.multiplier), decimal.RequireFromString(tt.fraction),
+				SystemAuditMeta(), model.AuditActionBetCashout)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if len(fake.executed("INSERT", "bet_settlements")) != 0 || len(fake.executed("UPDATE", "wallets")) != 0 {
+					t.Fatalf("结算失败时不应写入结算明细或赔付")
+				}
+				return
+			}
+
+			if !settlement.Stake.Equal(decimal.RequireFromString(tt.wantStake)) || !settlement.Payout.Equal(decimal.RequireFromString(tt.wantPayout)) {
+				t.Fatalf("settlement = 本金 %s 赔付 %s, want 本金 %s 赔付 %s", settlement.Stake, settlement.Payout, tt.wantStake, tt.wantPayout)
+			}
+			if !bet.RemainingAmount.Equal(decimal.RequireFromString(tt.wantRemaining)) || !bet.Payout.Equal(settlement.Payout) {
+				t.Fatalf("bet = 剩余本金 %s 赔付 %s, want 剩余本金 %s 赔付 %s", bet.RemainingAmount, bet.Payout, tt.wantRemaining, tt.wantPayout)
+			}
+
+			// 全部结算后下注变为已止盈，部分止盈保持进行中
+			full := bet.RemainingAmount.IsZero()
+			if full != (bet.Status == 1) || (!full && bet.Status != 0) {
+				t.Fatalf("status = %d，剩余本金 %s", bet.Status, bet.RemainingAmount)
+			}
+			updates := fake.executed("UPDATE", "bets")
+			if len(updates) != 1 {
+				t.Fatalf("下注应更新1次，实际 %d 次", len(updates))
+			}
+			set := strings.SplitN(updates[0].query, "WHERE", 2)[0]
+			if strings.Contains(set, "`status`") != full {
+				t.Fatalf("全部止盈才应更新状态: %s", updates[0].query)
+			}
+			if len(fake.executed("UPDATE", "wallets")) != 1 {
+				t.Fatalf("赔付应记入钱包")
+			}
+		})
+	}
+}
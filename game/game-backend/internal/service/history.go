@@ -0,0 +1,330 @@
+package service
+
+import (
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"game-backend/internal/model"
+	"game-backend/pkg/money"
+)
+
+// crashBuckets 崩盘倍数分布区间，左闭右开，Max为0表示不设上限
+var crashBuckets = []struct {
+	Label    string
+	Min, Max decimal.Decimal
+}{
+	{"<2x", decimal.Zero, decimal.NewFromInt(2)},
+	{"2x-10x", decimal.NewFromInt(2), decimal.NewFromInt(10)},
+	{">=10x", decimal.NewFromInt(10), decimal.Zero},
+}
+
+// CrashBucket 崩盘倍数分布的一个区间
+type CrashBucket struct {
+	Label string           `json:"label"`
+	Min   decimal.Decimal  `json:"min"`
+	Max   *decimal.Decimal `json:"max"` // 为空表示不设上限
+	Count int              `json:"count"`
+	Ratio decimal.Decimal  `json:"ratio"` // 占统计局数的比例，0-1
+}
+
+// CrashStreak 崩盘倍数达到Multiplier的间隔
+type CrashStreak struct {
+	Multiplier     decimal.Decimal `json:"multiplier"`
+	RoundsSince    int64           `json:"rounds_since"`    // 最近一次达到该倍数之后的局数，从未达到时为全部局数
+	Found          bool            `json:"found"`           // 历史上是否达到过该倍数
+	LongestDrought int             `json:"longest_drought"` // 统计局数内连续未达到该倍数的最长局数
+}
+
+// RoundAnalytics 最近若干局的崩盘倍数统计，RoundIDs与CrashPoints一一对应，最新的在前
+type RoundAnalytics struct {
+	Rounds      int             `json:"rounds"`
+	RoundIDs    []string        `json:"round_ids"`
+	CrashPoints []string        `json:"crash_points"`
+	Average     decimal.Decimal `json:"average"`
+	Median      decimal.Decimal `json:"median"`
+	Highest     decimal.Decimal `json:"highest"`
+	Buckets     []CrashBucket   `json:"buckets"`
+	Streaks     []CrashStreak   `json:"streaks"`
+}
+
+// RoundBetDetail 一局中的一笔下注
+type RoundBetDetail struct {
+	BetID       string                `json:"bet_id"`
+	UserID      uint                  `json:"user_id"`
+	Username    string                `json:"username"`
+	Slot        int                   `json:"slot"`
+	Currency    string                `json:"currency"`
+	Amount      decimal.Decimal       `json:"amount"`
+	AutoCashout decimal.Decimal       `json:"auto_cashout"`
+	Payout      decimal.Decimal       `json:"payout"`
+	Profit      decimal.Decimal       `json:"profit"`
+	Status      int                   `json:"status"` // 1:已止盈 2:已崩盘 3:已撤销
+	Settlements []model.BetSettlement `json:"settlements"`
+}
+
+// RoundDetail 一局的游戏历史与全部下注，下注按下注时间排序
+type RoundDetail struct {
+	model.GameHistory
+	Bets []RoundBetDetail `json:"bets"`
+}
+
+// HistoryFilter 历史查询共用的范围条件，时间范围为[StartTime, EndTime)，金额与倍数范围包含边界，为空时不限
+type HistoryFilter struct {
+	StartTime     *time.Time
+	EndTime       *time.Time
+	MinAmount     *decimal.Decimal
+	MaxAmount     *decimal.Decimal
+	MinMultiplier *decimal.Decimal
+	MaxMultiplier *decimal.Decimal
+}
+
+// apply 按时间、金额与倍数列过滤
+func (f HistoryFilter) apply(db *gorm.DB, timeColumn, amountColumn, multiplierColumn string) *gorm.DB {
+	if f.StartTime != nil {
+		db = db.Where(timeColumn+" >= ?", *f.StartTime)
+	}
+	if f.EndTime != nil {
+		db = db.Where(timeColumn+" < ?", *f.EndTime)
+	}
+	if f.MinAmount != nil {
+		db = db.Where(amountColumn+" >= ?", *f.MinAmount)
+	}
+	if f.MaxAmount != nil {
+		db = db.Where(amountColumn+" <= ?", *f.MaxAmount)
+	}
+	if f.MinMultiplier != nil {
+		db = db.Where(multiplierColumn+" >= ?", *f.MinMultiplier)
+	}
+	if f.MaxMultiplier != nil {
+		db = db.Where(multiplierColumn+" <= ?", *f.MaxMultiplier)
+	}
+	return db
+}
+
+// BetHistoryQuery 下注历史查询条件：时间为下注时间，金额为下注本金，倍数为最近一次止盈的倍数
+type BetHistoryQuery struct {
+	PageQuery
+	HistoryFilter
+	Currency string
+	RoundID  string
+	Statuses []int
+}
+
+// GameHistoryQuery 游戏历史查询条件：时间为记录时间，金额为折算后的总下注额，倍数为崩盘倍数
+type GameHistoryQuery struct {
+	PageQuery
+	HistoryFilter
+	RoundID string
+}
+
+// betSorts 下注历史的排序字段
+var betSorts = map[string]pageSort[model.Bet]{
+	SortTime:     {},
+	"amount":     {Column: "amount", Cast: "DECIMAL(30,8)", Value: func(b *model.Bet) decimal.Decimal { return b.Amount }},
+	"payout":     {Column: "payout", Cast: "DECIMAL(30,8)", Value: func(b *model.Bet) decimal.Decimal { return b.Payout }},
+	"multiplier": {Column: "multiplier", Cast: "DECIMAL(10,2)", Value: func(b *model.Bet) decimal.Decimal { return b.Multiplier }},
+}
+
+// gameHistorySorts 游戏历史的排序字段
+var gameHistorySorts = map[string]pageSort[model.GameHistory]{
+	SortTime:       {},
+	"multiplier":   {Column: "final_multiplier", Cast: "DECIMAL(10,2)", Value: func(g *model.GameHistory) decimal.Decimal { return g.FinalMultiplier }},
+	"total_bets":   {Column: "total_bets", Cast: "DECIMAL(15,2)", Value: func(g *model.GameHistory) decimal.Decimal { return g.TotalBets }},
+	"total_payout": {Column: "total_payout", Cast: "DECIMAL(15,2)", Value: func(g *model.GameHistory) decimal.Decimal { return g.TotalPayout }},
+}
+
+// GetUserBetHistory 获取用户下注历史，按游标分页
+func (s *GameService) GetUserBetHistory(userID uint, q BetHistoryQuery) ([]model.Bet, *Page, error) {
+	db := s.db.Model(&model.Bet{}).Where("user_id = ?", userID)
+	if q.Currency != "" {
+		cur, err := money.Lookup(q.Currency)
+		if err != nil {
+			return nil, nil, err
+		}
+		db = db.Where("currency = ?", cur.Code)
+	}
+	if q.RoundID != "" {
+		db = db.Where("round_id = ?", q.RoundID)
+	}
+	if len(q.Statuses) > 0 {
+		db = db.Where("status IN ?", q.Statuses)
+	}
+	db = q.HistoryFilter.apply(db, "created_at", "amount", "multiplier")
+
+	bets, page, err := paginate(db, q.PageQuery, betSorts, func(b *model.Bet) uint64 { return uint64(b.ID) })
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := loadSettlements(s.db, bets); err != nil {
+		return nil, nil, err
+	}
+	return bets, page, nil
+}
+
+// GetGameHistory 获取游戏历史，按游标分页
+func (s *GameService) GetGameHistory(q GameHistoryQuery) ([]model.GameHistory, *Page, error) {
+	db := s.db.Model(&model.GameHistory{})
+	if q.RoundID != "" {
+		db = db.Where("round_id = ?", q.RoundID)
+	}
+	db = q.HistoryFilter.apply(db, "created_at", "total_bets", "final_multiplier")
+
+	return paginate(db, q.PageQuery, gameHistorySorts, func(g *model.GameHistory) uint64 { return uint64(g.ID) })
+}
+
+// GetRoundAnalytics 统计最近limit局的崩盘倍数：分布区间、均值与中位数，以及距上次达到各倍数的局数
+func (s *GameService) GetRoundAnalytics(limit int, thresholds []decimal.Decimal) (*RoundAnalytics, error) {
+	var rounds []model.GameHistory
+	if err := s.db.Select("id", "round_id", "final_multiplier").
+		Order("id DESC").Limit(limit).Find(&rounds).Error; err != nil {
+		return nil, err
+	}
+
+	result := &RoundAnalytics{
+		Rounds:      len(rounds),
+		RoundIDs:    make([]string, len(rounds)),
+		CrashPoints: make([]string, len(rounds)),
+	}
+	points := make([]decimal.Decimal, len(rounds))
+	sum := decimal.Zero
+	for i, round := range rounds {
+		result.RoundIDs[i] = round.RoundID
+		result.CrashPoints[i] = round.FinalMultiplier.StringFixed(money.MultiplierScale)
+		points[i] = round.FinalMultiplier
+		sum = sum.Add(round.FinalMultiplier)
+		if round.FinalMultiplier.GreaterThan(result.Highest) {
+			result.Highest = round.FinalMultiplier
+		}
+	}
+
+	for _, bucket := range crashBuckets {
+		view := CrashBucket{Label: bucket.Label, Min: bucket.Min}
+		if !bucket.Max.IsZero() {
+			max := bucket.Max
+			view.Max = &max
+		}
+		for _, point := range points {
+			if point.GreaterThanOrEqual(bucket.Min) && (bucket.Max.IsZero() || point.LessThan(bucket.Max)) {
+				view.Count++
+			}
+		}
+		view.Ratio = ratio(decimal.NewFromInt(int64(view.Count)), decimal.NewFromInt(int64(len(points))), 4)
+		result.Buckets = append(result.Buckets, view)
+	}
+
+	if len(points) > 0 {
+		result.Average = sum.DivRound(decimal.NewFromInt(int64(len(points))), money.MultiplierScale)
+
+		sorted := make([]decimal.Decimal, len(points))
+		copy(sorted, points)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i].LessThan(sorted[j]) })
+		mid := len(sorted) / 2
+		result.Median = sorted[mid]
+		if len(sorted)%2 == 0 {
+			result.Median = sorted[mid-1].Add(sorted[mid]).Div(decimal.NewFromInt(2)).Truncate(money.MultiplierScale)
+		}
+	}
+
+	result.Streaks = make([]CrashStreak, 0, len(thresholds))
+	for _, threshold := range thresholds {
+		streak, err := s.crashStreak(threshold, points)
+		if err != nil {
+			return nil, err
+		}
+		result.Streaks = append(result.Streaks, *streak)
+	}
+	return result, nil
+}
+
+// crashStreak 距上次崩盘倍数达到threshold的局数，points为最近若干局的崩盘倍数（最新的在前）
+func (s *GameService) crashStreak(threshold decimal.Decimal, points []decimal.Decimal) (*CrashStreak, error) {
+	streak := &CrashStreak{Multiplier: threshold}
+
+	// 统计范围内连续未达到的最长局数
+	run := 0
+	for _, point := range points {
+		if point.LessThan(threshold) {
+			run++
+			if run > streak.LongestDrought {
+				streak.LongestDrought = run
+			}
+			continue
+		}
+		run = 0
+	}
+
+	// 最近一次出现在统计范围内时直接计算，否则查询全部历史
+	for i, point := range points {
+		if point.GreaterThanOrEqual(threshold) {
+			streak.RoundsSince = int64(i)
+			streak.Found = true
+			return streak, nil
+		}
+	}
+
+	var last model.GameHistory
+	err := s.db.Select("id").Where("final_multiplier >= ?", threshold).Order("id DESC").Limit(1).Find(&last).Error
+	if err != nil {
+		return nil, err
+	}
+	streak.Found = last.ID != 0
+	err = s.db.Model(&model.GameHistory{}).Where("id > ?", last.ID).Count(&streak.RoundsSince).Error
+	return streak, err
+}
+
+// GetRoundDetail 获取一局的游戏历史与全部下注及结算明细
+func (s *GameService) GetRoundDetail(roundID string) (*RoundDetail, error) {
+	var detail RoundDetail
+	if err := s.db.Where("round_id = ?", roundID).First(&detail.GameHistory).Error; err != nil {
+		return nil, err
+	}
+
+	var bets []model.Bet
+	if err := s.db.Where("round_id = ?", roundID).Order("id").Find(&bets).Error; err != nil {
+		return nil, err
+	}
+	if err := loadSettlements(s.db, bets); err != nil {
+		return nil, err
+	}
+
+	userIDs := make([]uint, 0, len(bets))
+	for _, bet := range bets {
+		userIDs = append(userIDs, bet.UserID)
+	}
+	usernames := make(map[uint]string, len(userIDs))
+	if len(userIDs) > 0 {
+		var users []model.User
+		if err := s.db.Select("id", "username").Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+			return nil, err
+		}
+		for _, user := range users {
+			usernames[user.ID] = user.Username
+		}
+	}
+
+	detail.Bets = make([]RoundBetDetail, 0, len(bets))
+	for _, bet := range bets {
+		view := RoundBetDetail{
+			BetID:       bet.BetID,
+			UserID:      bet.UserID,
+			Username:    usernames[bet.UserID],
+			Slot:        bet.Slot,
+			Currency:    bet.Currency,
+			Amount:      bet.Amount,
+			AutoCashout: bet.AutoCashout,
+			Payout:      bet.Payout,
+			Status:      bet.Status,
+			Settlements: bet.Settlements,
+		}
+		if bet.Status != 3 {
+			view.Profit = bet.Payout.Sub(bet.Amount)
+		}
+		if view.Settlements == nil {
+			view.Settlements = []model.BetSettlement{}
+		}
+		detail.Bets = append(detail.Bets, view)
+	}
+	return &detail, nil
+}
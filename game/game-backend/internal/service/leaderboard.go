@@ -0,0 +1,373 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"game-backend/internal/model"
+	"game-backend/pkg/logger"
+	"game-backend/pkg/money"
+)
+
+// ErrInvalidLeaderboard 排行榜周期或类型不合法
+var ErrInvalidLeaderboard = errors.New("排行榜周期须为daily、weekly或all，类型须为profit或multiplier")
+
+// 排行榜有序集合 leaderboard:{类型}:{周期}:{周期标识}，成员为用户ID
+// 盈亏榜的分数为基准币种最小单位的整数，每次结算累加；倍数榜只在新倍数更高时更新
+const leaderboardKey = "leaderboard:%s:%s:%s"
+
+// LeaderboardPeriods 排行榜周期
+var LeaderboardPeriods = []string{model.LeaderboardDaily, model.LeaderboardWeekly, model.LeaderboardAllTime}
+
+// LeaderboardMetrics 排行榜类型
+var LeaderboardMetrics = []string{model.LeaderboardProfit, model.LeaderboardMultiplier}
+
+// LeaderboardEntry 排行榜一个名次，盈亏榜的分数为基准币种金额，倍数榜为最大止盈倍数
+type LeaderboardEntry struct {
+	Rank     int32           `json:"rank"`
+	UserID   uint            `json:"user_id"`
+	Username string          `json:"username"`
+	Score    decimal.Decimal `json:"score"`
+}
+
+// LeaderboardBoard 一个排行榜的前若干名
+type LeaderboardBoard struct {
+	Period  string             `json:"period"`
+	Metric  string             `json:"metric"`
+	Bucket  string             `json:"bucket"`
+	Entries []LeaderboardEntry `json:"entries"`
+}
+
+// LeaderboardRank 玩家在一个排行榜中的名次，Rank为0表示未上榜
+type LeaderboardRank struct {
+	Period string          `json:"period"`
+	Metric string          `json:"metric"`
+	Bucket string          `json:"bucket"`
+	Rank   int64           `json:"rank"`
+	Score  decimal.Decimal `json:"score"`
+	Total  int64           `json:"total"` // 上榜玩家数
+}
+
+// LeaderboardService 排行榜服务，日榜、周榜与总榜实时维护在Redis有序集合中，按服务器时区划分周期
+type LeaderboardService struct {
+	db    *gorm.DB
+	redis *redis.Client
+	ctx   context.Context
+	log   *logrus.Entry
+}
+
+// NewLeaderboardService 创建排行榜服务
+func NewLeaderboardService(db *gorm.DB, redisClient *redis.Client) *LeaderboardService {
+	return &LeaderboardService{
+		db:    db,
+		redis: redisClient,
+		ctx:   context.Background(),
+		log:   logrus.NewEntry(logrus.StandardLogger()),
+	}
+}
+
+// WithContext 返回绑定请求上下文的服务副本，数据库与Redis操作和日志携带请求字段
+func (s *LeaderboardService) WithContext(ctx context.Context) *LeaderboardService {
+	clone := *s
+	clone.db = s.db.WithContext(ctx)
+	clone.ctx = ctx
+	clone.log = logger.FromContext(ctx)
+	return &clone
+}
+
+// ValidBoard 校验排行榜周期与类型
+func ValidBoard(period, metric string) error {
+	if !contains(LeaderboardPeriods, period) || !contains(LeaderboardMetrics, metric) {
+		return ErrInvalidLeaderboard
+	}
+	return nil
+}
+
+// contains 列表中是否包含value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// LeaderboardBucket t所在周期的标识：日榜20240101，周榜按ISO周2024W01，总榜all
+func LeaderboardBucket(period string, t time.Time) string {
+	switch period {
+	case model.LeaderboardDaily:
+		return t.Format("20060102")
+	case model.LeaderboardWeekly:
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%dW%02d", year, week)
+	default:
+		return model.LeaderboardAllTime
+	}
+}
+
+// leaderboardTTL 周期排行榜在Redis中的保留时长，周期结束后保留一段时间供最后一次快照读取，总榜不过期
+func leaderboardTTL(period string) time.Duration {
+	switch period {
+	case model.LeaderboardDaily:
+		return 2 * 24 * time.Hour
+	case model.LeaderboardWeekly:
+		return 2 * 7 * 24 * time.Hour
+	default:
+		return 0
+	}
+}
+
+// boardKey 排行榜的Redis键
+func boardKey(metric, period, bucket string) string {
+	return fmt.Sprintf(leaderboardKey, metric, period, bucket)
+}
+
+// RecordCashout 记录一次止盈结算：盈亏榜累加本次结算的净盈利，倍数榜更新最大止盈倍数
+func (s *LeaderboardService) RecordCashout(userID uint, currency string, stake, payout, multiplier decimal.Decimal, at time.Time) error {
+	cur, err := money.Lookup(currency)
+	if err != nil {
+		return err
+	}
+	profit := baseUnits(cur.ToBase(payout.Sub(stake)))
+	return s.record(userID, profit, multiplier, at)
+}
+
+// RecordLoss 记录崩盘时未止盈的本金，盈亏榜扣减
+func (s *LeaderboardService) RecordLoss(userID uint, currency string, stake decimal.Decimal, at time.Time) error {
+	cur, err := money.Lookup(currency)
+	if err != nil {
+		return err
+	}
+	loss := baseUnits(cur.ToBase(stake))
+	return s.record(userID, -loss, decimal.Zero, at)
+}
+
+// record 在同一事务中更新各周期的排行榜，multiplier为0时不更新倍数榜
+func (s *LeaderboardService) record(userID uint, profit int64, multiplier decimal.Decimal, at time.Time) error {
+	if profit == 0 && !multiplier.IsPositive() {
+		return nil
+	}
+
+	member := strconv.FormatUint(uint64(userID), 10)
+	_, err := s.redis.TxPipelined(s.ctx, func(pipe redis.Pipeliner) error {
+		for _, period := range LeaderboardPeriods {
+			bucket := LeaderboardBucket(period, at)
+			ttl := leaderboardTTL(period)
+
+			profitKey := boardKey(model.LeaderboardProfit, period, bucket)
+			pipe.ZIncrBy(s.ctx, profitKey, float64(profit), member)
+			if ttl > 0 {
+				pipe.Expire(s.ctx, profitKey, ttl)
+			}
+
+			if multiplier.IsPositive() {
+				multiplierKey := boardKey(model.LeaderboardMultiplier, period, bucket)
+				pipe.ZAddArgs(s.ctx, multiplierKey, redis.ZAddArgs{
+					GT:      true,
+					Members: []redis.Z{{Score: multiplier.InexactFloat64(), Member: member}},
+				})
+				if ttl > 0 {
+					pipe.Expire(s.ctx, multiplierKey, ttl)
+				}
+			}
+		}
+		return nil
+	})
+	return err
+}
+
+// baseUnits 基准币种金额换算为最小单位的整数
+func baseUnits(amount decimal.Decimal) int64 {
+	return amount.Shift(money.Base().Precision).IntPart()
+}
+
+// leaderboardScore 有序集合分数换算为展示值
+func leaderboardScore(metric string, value float64) decimal.Decimal {
+	if metric == model.LeaderboardProfit {
+		return decimal.New(int64(value), -money.Base().Precision)
+	}
+	return decimal.NewFromFloat(value).Round(money.MultiplierScale)
+}
+
+// Top 获取当前周期排行榜的前limit名
+func (s *LeaderboardService) Top(period, metric string, limit int) (*LeaderboardBoard, error) {
+	return s.top(period, metric, LeaderboardBucket(period, time.Now()), limit)
+}
+
+// top 获取指定周期排行榜的前limit名，用户名从用户表读取
+func (s *LeaderboardService) top(period, metric, bucket string, limit int) (*LeaderboardBoard, error) {
+	if err := ValidBoard(period, metric); err != nil {
+		return nil, err
+	}
+
+	members, err := s.redis.ZRevRangeWithScores(s.ctx, boardKey(metric, period, bucket), 0, int64(limit)-1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	board := &LeaderboardBoard{
+		Period:  period,
+		Metric:  metric,
+		Bucket:  bucket,
+		Entries: make([]LeaderboardEntry, 0, len(members)),
+	}
+	if len(members) == 0 {
+		return board, nil
+	}
+
+	userIDs := make([]uint, 0, len(members))
+	for _, z := range members {
+		userID, err := strconv.ParseUint(fmt.Sprint(z.Member), 10, 64)
+		if err != nil {
+			continue
+		}
+		userIDs = append(userIDs, uint(userID))
+		board.Entries = append(board.Entries, LeaderboardEntry{
+			Rank:   int32(len(board.Entries) + 1),
+			UserID: uint(userID),
+			Score:  leaderboardScore(metric, z.Score),
+		})
+	}
+
+	var users []model.User
+	if err := s.db.Select("id", "username").Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	usernames := make(map[uint]string, len(users))
+	for _, user := range users {
+		usernames[user.ID] = user.Username
+	}
+	for i := range board.Entries {
+		board.Entries[i].Username = usernames[board.Entries[i].UserID]
+	}
+	return board, nil
+}
+
+// Rank 获取玩家在当前周期各排行榜中的名次，period与metric为空时返回全部排行榜
+func (s *LeaderboardService) Rank(userID uint, period, metric string) ([]LeaderboardRank, error) {
+	periods, metrics := LeaderboardPeriods, LeaderboardMetrics
+	if period != "" {
+		periods = []string{period}
+	}
+	if metric != "" {
+		metrics = []string{metric}
+	}
+
+	now := time.Now()
+	member := strconv.FormatUint(uint64(userID), 10)
+
+	type pending struct {
+		rank  *redis.IntCmd
+		score *redis.FloatCmd
+		total *redis.IntCmd
+	}
+	var ranks []LeaderboardRank
+	var cmds []pending
+	pipe := s.redis.Pipeline()
+	for _, p := range periods {
+		for _, m := range metrics {
+			if err := ValidBoard(p, m); err != nil {
+				return nil, err
+			}
+			bucket := LeaderboardBucket(p, now)
+			key := boardKey(m, p, bucket)
+			ranks = append(ranks, LeaderboardRank{Period: p, Metric: m, Bucket: bucket})
+			cmds = append(cmds, pending{
+				rank:  pipe.ZRevRank(s.ctx, key, member),
+				score: pipe.ZScore(s.ctx, key, member),
+				total: pipe.ZCard(s.ctx, key),
+			})
+		}
+	}
+	// 未上榜时ZRevRank与ZScore返回redis.Nil
+	if _, err := pipe.Exec(s.ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	for i, cmd := range cmds {
+		ranks[i].Total = cmd.total.Val()
+		rank, err := cmd.rank.Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		ranks[i].Rank = rank + 1
+		ranks[i].Score = leaderboardScore(ranks[i].Metric, cmd.score.Val())
+	}
+	return ranks, nil
+}
+
+// Snapshot 将各排行榜的前size名写入MySQL，覆盖同一周期的上一次快照
+// 同时写入previous所在的周期，跨过周期边界时保存上一周期的最终排名
+func (s *LeaderboardService) Snapshot(size int, now, previous time.Time) error {
+	for _, period := range LeaderboardPeriods {
+		buckets := []string{LeaderboardBucket(period, now)}
+		if last := LeaderboardBucket(period, previous); last != buckets[0] {
+			buckets = append(buckets, last)
+		}
+
+		for _, metric := range LeaderboardMetrics {
+			for _, bucket := range buckets {
+				board, err := s.top(period, metric, bucket, size)
+				if err != nil {
+					return err
+				}
+				if err := s.saveSnapshot(board, now); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// saveSnapshot 按周期与名次覆盖写入排行榜快照
+func (s *LeaderboardService) saveSnapshot(board *LeaderboardBoard, takenAt time.Time) error {
+	if len(board.Entries) == 0 {
+		return nil
+	}
+
+	rows := make([]model.LeaderboardSnapshot, len(board.Entries))
+	for i, entry := range board.Entries {
+		rows[i] = model.LeaderboardSnapshot{
+			Period:   board.Period,
+			Metric:   board.Metric,
+			Bucket:   board.Bucket,
+			Rank:     entry.Rank,
+			UserID:   entry.UserID,
+			Username: entry.Username,
+			Score:    entry.Score,
+			TakenAt:  takenAt,
+		}
+	}
+
+	return s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "period"}, {Name: "metric"}, {Name: "bucket"}, {Name: "rank"}},
+		DoUpdates: clause.AssignmentColumns([]string{"user_id", "username", "score", "taken_at"}),
+	}).Create(&rows).Error
+}
+
+// Snapshots 查询已保存的排行榜快照，用于查看已结束周期的最终排名
+func (s *LeaderboardService) Snapshots(period, metric, bucket string, limit int) ([]model.LeaderboardSnapshot, error) {
+	if err := ValidBoard(period, metric); err != nil {
+		return nil, err
+	}
+
+	var rows []model.LeaderboardSnapshot
+	err := s.db.Where("period = ? AND metric = ? AND bucket = ?", period, metric, bucket).
+		Order("`rank`").
+		Limit(limit).
+		Find(&rows).Error
+	return rows, err
+}
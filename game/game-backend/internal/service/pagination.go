@@ -0,0 +1,143 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidCursor 游标无法解析，或与本次查询的排序方式不一致
+	ErrInvalidCursor = errors.New("分页游标无效")
+	// ErrInvalidSort 不支持的排序字段
+	ErrInvalidSort = errors.New("不支持的排序字段")
+)
+
+// SortTime 按创建时间排序，自增主键与创建时间同序，直接按主键排序
+const SortTime = "time"
+
+// PageQuery 游标分页参数，Cursor为上一页返回的next_cursor，为空时从第一页开始
+type PageQuery struct {
+	Cursor   string
+	PageSize int
+	Sort     string // 排序字段，为空时按创建时间
+	Asc      bool   // 默认倒序
+}
+
+// Page 所有列表接口统一返回的分页信息，has_more为false时已是最后一页
+type Page struct {
+	PageSize   int    `json:"page_size"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+}
+
+// pageSort 可排序字段，Column为空表示只按主键排序；排序值相同时按主键排序，保证翻页稳定
+type pageSort[T any] struct {
+	Column string
+	Cast   string // 游标中的排序值比较时转换的SQL类型，避免按浮点数比较
+	Value  func(*T) decimal.Decimal
+}
+
+// pageCursor 游标内容：排序方式与本页最后一行的排序值、主键
+type pageCursor struct {
+	Sort  string `json:"s"`
+	Asc   bool   `json:"a,omitempty"`
+	Value string `json:"v,omitempty"`
+	ID    uint64 `json:"i"`
+}
+
+// encode 编码为URL安全的不透明字符串
+func (c pageCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor 解析游标
+func decodeCursor(value string) (*pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var cursor pageCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	if cursor.Value != "" {
+		if _, err := decimal.NewFromString(cursor.Value); err != nil {
+			return nil, err
+		}
+	}
+	return &cursor, nil
+}
+
+// timeSorts 只支持按创建时间排序的列表
+func timeSorts[T any]() map[string]pageSort[T] {
+	return map[string]pageSort[T]{SortTime: {}}
+}
+
+// paginate 按键集分页查询：从游标位置之后取PageSize+1行判断是否还有下一页，
+// 不统计总数也不使用OFFSET，翻页耗时与页码无关。db应为单表查询，id为该表主键
+func paginate[T any](db *gorm.DB, q PageQuery, sorts map[string]pageSort[T], id func(*T) uint64) ([]T, *Page, error) {
+	name := q.Sort
+	if name == "" {
+		name = SortTime
+	}
+	by, ok := sorts[name]
+	if !ok {
+		names := make([]string, 0, len(sorts))
+		for key := range sorts {
+			names = append(names, key)
+		}
+		sort.Strings(names)
+		return nil, nil, fmt.Errorf("%w: %s，可选 %s", ErrInvalidSort, name, strings.Join(names, "/"))
+	}
+
+	op, dir := "<", "DESC"
+	if q.Asc {
+		op, dir = ">", "ASC"
+	}
+
+	if q.Cursor != "" {
+		cursor, err := decodeCursor(q.Cursor)
+		if err != nil || cursor.Sort != name || cursor.Asc != q.Asc || (by.Column != "") != (cursor.Value != "") {
+			return nil, nil, ErrInvalidCursor
+		}
+		if by.Column == "" {
+			db = db.Where("id "+op+" ?", cursor.ID)
+		} else {
+			value := "CAST(? AS " + by.Cast + ")"
+			db = db.Where(fmt.Sprintf("(%s %s %s OR (%s = %s AND id %s ?))", by.Column, op, value, by.Column, value, op),
+				cursor.Value, cursor.Value, cursor.ID)
+		}
+	}
+
+	if by.Column != "" {
+		db = db.Order(by.Column + " " + dir)
+	}
+
+	var items []T
+	if err := db.Order("id " + dir).Limit(q.PageSize + 1).Find(&items).Error; err != nil {
+		return nil, nil, err
+	}
+
+	page := &Page{PageSize: q.PageSize}
+	if len(items) > q.PageSize {
+		items = items[:q.PageSize]
+		page.HasMore = true
+
+		last := &items[len(items)-1]
+		next := pageCursor{Sort: name, Asc: q.Asc, ID: id(last)}
+		if by.Column != "" {
+			next.Value = by.Value(last).String()
+		}
+		page.NextCursor = next.encode()
+	}
+	return items, page, nil
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"game-backend/internal/model"
+)
+
+func TestPaginateCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		sort      string
+		asc       bool
+		wantValue string
+		wantWhere string
+	}{
+		{"按时间倒序", "", false, "", "id < ?"},
+		{"按时间正序", SortTime, true, "", "id > ?"},
+		{"按金额倒序", "amount", false, "20", "(amount < CAST(? AS DECIMAL(30,8)) OR (amount = CAST(? AS DECIMAL(30,8)) AND id < ?))"},
+		{"按倍数正序", "multiplier", true, "1.5", "(multiplier > CAST(? AS DECIMAL(10,2)) OR (multiplier = CAST(? AS DECIMAL(10,2)) AND id > ?))"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDB(t)
+			fake.rows["bets"] = []fakeRow{
+				{"id": int64(9), "amount": "30", "multiplier": "1.2"},
+				{"id": int64(8), "amount": "20", "multiplier": "1.5"},
+				{"id": int64(5), "amount": "10", "multiplier": "2"},
+			}
+			q := PageQuery{PageSize: 2, Sort: tt.sort, Asc: tt.asc}
+			id := func(b *model.Bet) uint64 { return uint64(b.ID) }
+
+			bets, page, err := paginate(db.Model(&model.Bet{}), q, betSorts, id)
+			if err != nil {
+				t.Fatalf("paginate: %v", err)
+			}
+			if len(bets) != 2 || !page.HasMore || page.NextCursor == "" {
+				t.Fatalf("第一页 = %d 行, has_more = %v, next_cursor = %q", len(bets), page.HasMore, page.NextCursor)
+			}
+
+			cursor, err := decodeCursor(page.NextCursor)
+			if err != nil {
+				t.Fatalf("decodeCursor: %v", err)
+			}
+			if cursor.ID != 8 || cursor.Value != tt.wantValue {
+				t.Fatalf("游标 = id %d value %q, want id 8 value %q", cursor.ID, cursor.Value, tt.wantValue)
+			}
+
+			// 下一页从游标之后开始
+			fake.rows["bets"] = []fakeRow{{"id": int64(5), "amount": "10", "multiplier": "2"}}
+			q.Cursor = page.NextCursor
+			bets, page, err = paginate(db.Model(&model.Bet{}), q, betSorts, id)
+			if err != nil {
+				t.Fatalf("paginate 第二页: %v", err)
+			}
+			if len(bets) != 1 || page.HasMore || page.NextCursor != "" {
+				t.Fatalf("最后一页 = %d 行, has_more = %v, next_cursor = %q", len(bets), page.HasMore, page.NextCursor)
+			}
+			query := fake.queries[len(fake.queries)-1].query
+			if !strings.Contains(query, tt.wantWhere) {
+				t.Fatalf("第二页查询缺少游标条件 %s: %s", tt.wantWhere, query)
+			}
+		})
+	}
+}
+
+func TestPaginateRejectsMismatchedCursor(t *testing.T) {
+	amountCursor := pageCursor{Sort: "amount", Value: "20", ID: 8}.encode()
+	timeCursor := pageCursor{Sort: SortTime, ID: 8}.encode()
+
+	tests := []struct {
+		name    string
+		query   PageQuery
+		wantErr error
+	}{
+		{"不支持的排序字段", PageQuery{Sort: "balance"}, ErrInvalidSort},
+		{"游标无法解析", PageQuery{Cursor: "not-a-cursor"}, ErrInvalidCursor},
+		{"排序字段与游标不一致", PageQuery{Sort: "payout", Cursor: amountCursor}, ErrInvalidCursor},
+		{"排序方向与游标不一致", PageQuery{Sort: "amount", Asc: true, Cursor: amountCursor}, ErrInvalidCursor},
+		{"按时间排序的游标带排序值", PageQuery{Cursor: pageCursor{Sort: SortTime, Value: "20", ID: 8}.encode()}, ErrInvalidCursor},
+		{"按金额排序的游标缺少排序值", PageQuery{Sort: "amount", Cursor: pageCursor{Sort: "amount", ID: 8}.encode()}, ErrInvalidCursor},
+		{"游标排序值不是数字", PageQuery{Sort: "amount", Cursor: pageCursor{Sort: "amount", Value: "1 OR 1=1", ID: 8}.encode()}, ErrInvalidCursor},
+		{"匹配的游标", PageQuery{Cursor: timeCursor}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDB(t)
+			tt.query.PageSize = 20
+
+			_, _, err := paginate(db.Model(&model.Bet{}), tt.query, betSorts, func(b *model.Bet) uint64 { return uint64(b.ID) })
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil && len(fake.queries) != 0 {
+				t.Fatalf("无效的分页参数不应查询数据库")
+			}
+		})
+	}
+}
@@ -0,0 +1,524 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"game-backend/config"
+	"game-backend/internal/model"
+	"game-backend/internal/payment"
+	"game-backend/pkg/logger"
+	"game-backend/pkg/money"
+)
+
+var (
+	// ErrDepositOutOfRange 充值金额超出限额
+	ErrDepositOutOfRange = errors.New("充值金额超出限额")
+	// ErrWithdrawalOutOfRange 提现金额超出限额
+	ErrWithdrawalOutOfRange = errors.New("提现金额超出限额")
+	// ErrDailyWithdrawalLimit 超出24小时提现总额上限
+	ErrDailyWithdrawalLimit = errors.New("超出24小时提现总额上限")
+	// ErrInvalidPaymentTransition 充值提现状态不允许此操作
+	ErrInvalidPaymentTransition = errors.New("当前状态不允许此操作")
+	// ErrPaymentProviderMismatch 回调渠道与订单渠道不一致
+	ErrPaymentProviderMismatch = errors.New("回调渠道与订单不一致")
+)
+
+// PaymentService 充值提现服务，余额变动均通过钱包流水记账
+type PaymentService struct {
+	db          *gorm.DB
+	wallet      *WalletService
+	audit       *AuditService
+	responsible *ResponsibleService
+	provider    payment.Provider
+	ctx         context.Context
+	log         *logrus.Entry
+}
+
+// NewPaymentService 创建充值提现服务
+func NewPaymentService(db *gorm.DB, wallet *WalletService, audit *AuditService, responsible *ResponsibleService, provider payment.Provider) *PaymentService {
+	return &PaymentService{
+		db:          db,
+		wallet:      wallet,
+		audit:       audit,
+		responsible: responsible,
+		provider:    provider,
+		ctx:         context.Background(),
+		log:         logrus.NewEntry(logrus.StandardLogger()),
+	}
+}
+
+// WithContext 返回绑定请求上下文的服务副本，数据库操作和日志携带请求字段
+func (s *PaymentService) WithContext(ctx context.Context) *PaymentService {
+	clone := *s
+	clone.db = s.db.WithContext(ctx)
+	clone.ctx = ctx
+	clone.log = logger.FromContext(ctx)
+	return &clone
+}
+
+// ProviderName 当前支付渠道名称
+func (s *PaymentService) ProviderName() string {
+	return s.provider.Name()
+}
+
+// RequestDeposit 创建充值请求并提交渠道下单，到账以渠道回调为准
+func (s *PaymentService) RequestDeposit(userID uint, currency string, amount decimal.Decimal, meta AuditMeta) (*model.PaymentRequest, error) {
+	cur, err := money.Lookup(currency)
+	if err != nil {
+		return nil, err
+	}
+	if err := cur.ValidateAmount(amount); err != nil {
+		return nil, err
+	}
+
+	cfg := config.AppConfig.Payment
+	base := cur.ToBase(amount)
+	if base.LessThan(decimal.NewFromFloat(cfg.MinDeposit)) || base.GreaterThan(decimal.NewFromFloat(cfg.MaxDeposit)) {
+		return nil, ErrDepositOutOfRange
+	}
+
+	req := &model.PaymentRequest{
+		RequestID: "dep_" + uuid.NewString(),
+		UserID:    userID,
+		Type:      model.PaymentTypeDeposit,
+		Currency:  cur.Code,
+		Amount:    amount,
+		Status:    model.PaymentStatusPending,
+		Provider:  s.provider.Name(),
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// 检查自我限制与玩家充值限额
+		if err := s.responsible.CheckDepositTx(tx, userID, base); err != nil {
+			return err
+		}
+
+		if err := tx.Create(req).Error; err != nil {
+			return err
+		}
+		return s.audit.RecordTx(tx, meta, AuditEvent{
+			UserID:     userID,
+			Action:     model.AuditActionPaymentRequest,
+			TargetType: "payment",
+			TargetID:   req.RequestID,
+			Reason:     fmt.Sprintf("申请充值 %s %s", cur.Format(amount), cur.Code),
+			After:      paymentSnapshot(req),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.provider.CreateDeposit(s.ctx, providerRequest(req))
+	if err != nil {
+		s.log.WithError(err).WithField("request_id", req.RequestID).Error("渠道创建充值订单失败")
+		if ferr := s.transition(req.RequestID, model.PaymentStatusFailed, "", "渠道下单失败", SystemAuditMeta()); ferr != nil {
+			s.log.WithError(ferr).WithField("request_id", req.RequestID).Error("标记充值失败出错")
+		}
+		return nil, err
+	}
+
+	req.ProviderRef = result.ProviderRef
+	req.PaymentURL = result.PaymentURL
+	if err := s.db.Model(&model.PaymentRequest{}).
+		Where("id = ? AND (provider_ref IS NULL OR provider_ref = '')", req.ID).
+		Updates(map[string]interface{}{
+			"provider_ref": result.ProviderRef,
+			"payment_url":  result.PaymentURL,
+		}).Error; err != nil {
+		return nil, err
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"request_id": req.RequestID,
+		"currency":   req.Currency,
+		"amount":     req.Amount.String(),
+	}).Info("充值请求已创建")
+
+	return req, nil
+}
+
+// RequestWithdrawal 创建提现请求并冻结扣款，低于自动审批阈值时直接提交渠道打款
+func (s *PaymentService) RequestWithdrawal(userID uint, currency string, amount decimal.Decimal, meta AuditMeta) (*model.PaymentRequest, error) {
+	cur, err := money.Lookup(currency)
+	if err != nil {
+		return nil, err
+	}
+	if err := cur.ValidateAmount(amount); err != nil {
+		return nil, err
+	}
+
+	cfg := config.AppConfig.Payment
+	base := cur.ToBase(amount)
+	if base.LessThan(decimal.NewFromFloat(cfg.MinWithdrawal)) || base.GreaterThan(decimal.NewFromFloat(cfg.MaxWithdrawal)) {
+		return nil, ErrWithdrawalOutOfRange
+	}
+
+	req := &model.PaymentRequest{
+		RequestID: "wd_" + uuid.NewString(),
+		UserID:    userID,
+		Type:      model.PaymentTypeWithdrawal,
+		Currency:  cur.Code,
+		Amount:    amount,
+		Status:    model.PaymentStatusPending,
+		Provider:  s.provider.Name(),
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// 锁定用户行，串行化同一用户的提现以保证24小时限额准确
+		if err := lockUserTx(tx, userID); err != nil {
+			return err
+		}
+		// 任一币种存在拒付欠款时不能提现其他币种
+		if err := checkWalletDebtTx(tx, userID); err != nil {
+			return err
+		}
+
+		used, err := paymentsSinceTx(tx, userID, model.PaymentTypeWithdrawal, time.Now().Add(-24*time.Hour))
+		if err != nil {
+			return err
+		}
+		if used.Add(base).GreaterThan(decimal.NewFromFloat(cfg.DailyWithdrawal)) {
+			return ErrDailyWithdrawalLimit
+		}
+
+		if err := tx.Create(req).Error; err != nil {
+			return err
+		}
+
+		_, err = s.wallet.PostTx(tx, LedgerEntry{
+			UserID:   userID,
+			Currency: cur.Code,
+			Amount:   amount.Neg(),
+			Type:     model.WalletTxWithdrawal,
+			RefType:  "payment",
+			RefID:    req.RequestID,
+		}, meta, AuditEvent{
+			Action:     model.AuditActionPaymentRequest,
+			TargetType: "payment",
+			TargetID:   req.RequestID,
+			Reason:     fmt.Sprintf("申请提现 %s %s", cur.Format(amount), cur.Code),
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"request_id": req.RequestID,
+		"currency":   req.Currency,
+		"amount":     req.Amount.String(),
+	}).Info("提现请求已创建")
+
+	if cfg.AutoApproveBelow > 0 && base.LessThan(decimal.NewFromFloat(cfg.AutoApproveBelow)) {
+		return s.approve(req.ID, 0, SystemAuditMeta())
+	}
+
+	return req, nil
+}
+
+// paymentsSinceTx 统计指定时间后未失败的充值或提现总额（折算为基准币种）
+func paymentsSinceTx(tx *gorm.DB, userID uint, paymentType string, since time.Time) (decimal.Decimal, error) {
+	var rows []struct {
+		Currency string
+		Total    decimal.Decimal
+	}
+	err := tx.Model(&model.PaymentRequest{}).
+		Select("currency, SUM(amount) AS total").
+		Where("user_id = ? AND type = ? AND status <> ? AND created_at >= ?",
+			userID, paymentType, model.PaymentStatusFailed, since).
+		Group("currency").
+		Scan(&rows).Error
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	total := decimal.Zero
+	for _, row := range rows {
+		if cur, err := money.Lookup(row.Currency); err == nil {
+			total = total.Add(cur.ToBase(row.Total))
+		}
+	}
+	return total, nil
+}
+
+// ApproveWithdrawal 人工审批通过提现并提交渠道打款
+func (s *PaymentService) ApproveWithdrawal(id uint64, reviewerID uint, meta AuditMeta) (*model.PaymentRequest, error) {
+	return s.approve(id, reviewerID, meta)
+}
+
+// approve 审批提现，渠道打款失败时退回冻结金额
+func (s *PaymentService) approve(id uint64, reviewerID uint, meta AuditMeta) (*model.PaymentRequest, error) {
+	var req model.PaymentRequest
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockPaymentTx(tx, "id = ?", id, &req); err != nil {
+			return err
+		}
+		if req.Type != model.PaymentTypeWithdrawal || !req.CanTransition(model.PaymentStatusApproved) {
+			return ErrInvalidPaymentTransition
+		}
+
+		before := paymentSnapshot(&req)
+		now := time.Now()
+		updates := map[string]interface{}{
+			"status":      model.PaymentStatusApproved,
+			"reviewed_at": &now,
+		}
+		if reviewerID != 0 {
+			updates["reviewed_by"] = reviewerID
+			req.ReviewedBy = &reviewerID
+		}
+		if err := tx.Model(&req).Updates(updates).Error; err != nil {
+			return err
+		}
+		req.Status = model.PaymentStatusApproved
+		req.ReviewedAt = &now
+
+		return s.audit.RecordTx(tx, meta, AuditEvent{
+			UserID:     req.UserID,
+			Action:     model.AuditActionPaymentApprove,
+			TargetType: "payment",
+			TargetID:   req.RequestID,
+			Before:     before,
+			After:      paymentSnapshot(&req),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.provider.CreatePayout(s.ctx, providerRequest(&req))
+	if err != nil {
+		s.log.WithError(err).WithField("request_id", req.RequestID).Error("渠道提现打款失败")
+		if ferr := s.transition(req.RequestID, model.PaymentStatusFailed, "", "渠道打款失败", SystemAuditMeta()); ferr != nil {
+			s.log.WithError(ferr).WithField("request_id", req.RequestID).Error("退回提现冻结金额失败")
+		}
+		return nil, err
+	}
+
+	req.ProviderRef = result.ProviderRef
+	if err := s.db.Model(&model.PaymentRequest{}).
+		Where("id = ? AND (provider_ref IS NULL OR provider_ref = '')", req.ID).
+		Update("provider_ref", result.ProviderRef).Error; err != nil {
+		return nil, err
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"request_id":  req.RequestID,
+		"reviewed_by": reviewerID,
+	}).Info("提现已审批")
+
+	return &req, nil
+}
+
+// RejectWithdrawal 人工拒绝待审批的提现并退回冻结金额
+func (s *PaymentService) RejectWithdrawal(id uint64, reviewerID uint, reason string, meta AuditMeta) (*model.PaymentRequest, error) {
+	var req model.PaymentRequest
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockPaymentTx(tx, "id = ?", id, &req); err != nil {
+			return err
+		}
+		if req.Type != model.PaymentTypeWithdrawal || req.Status != model.PaymentStatusPending {
+			return ErrInvalidPaymentTransition
+		}
+
+		now := time.Now()
+		req.ReviewedBy = &reviewerID
+		req.ReviewedAt = &now
+		if err := tx.Model(&req).Updates(map[string]interface{}{
+			"reviewed_by": reviewerID,
+			"reviewed_at": &now,
+		}).Error; err != nil {
+			return err
+		}
+
+		return s.applyTx(tx, &req, model.PaymentStatusFailed, reason, meta, model.AuditActionPaymentReject)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"request_id":  req.RequestID,
+		"reviewed_by": reviewerID,
+		"reason":      reason,
+	}).Info("提现已拒绝")
+
+	return &req, nil
+}
+
+// HandleWebhook 处理渠道回调，重复回调幂等返回
+func (s *PaymentService) HandleWebhook(provider string, hook payment.Webhook) (*model.PaymentRequest, error) {
+	var req model.PaymentRequest
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockPaymentTx(tx, "request_id = ?", hook.RequestID, &req); err != nil {
+			return err
+		}
+		if req.Provider != provider {
+			return ErrPaymentProviderMismatch
+		}
+		if req.Status == hook.Status {
+			return nil
+		}
+		if req.ProviderRef == "" && hook.ProviderRef != "" {
+			req.ProviderRef = hook.ProviderRef
+			if err := tx.Model(&req).Update("provider_ref", hook.ProviderRef).Error; err != nil {
+				return err
+			}
+		}
+
+		return s.applyTx(tx, &req, hook.Status, hook.Reason, SystemAuditMeta(), model.AuditActionPaymentUpdate)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"request_id": req.RequestID,
+		"status":     req.Status,
+	}).Info("支付回调已处理")
+
+	return &req, nil
+}
+
+// transition 在独立事务中流转状态
+func (s *PaymentService) transition(requestID, status, providerRef, reason string, meta AuditMeta) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var req model.PaymentRequest
+		if err := lockPaymentTx(tx, "request_id = ?", requestID, &req); err != nil {
+			return err
+		}
+		if providerRef != "" {
+			req.ProviderRef = providerRef
+		}
+		return s.applyTx(tx, &req, status, reason, meta, model.AuditActionPaymentUpdate)
+	})
+}
+
+// applyTx 在事务中按状态机流转充值提现请求，并记账对应的余额变动
+func (s *PaymentService) applyTx(tx *gorm.DB, req *model.PaymentRequest, status, reason string, meta AuditMeta, action string) error {
+	if !req.CanTransition(status) {
+		return ErrInvalidPaymentTransition
+	}
+
+	cur, err := money.Lookup(req.Currency)
+	if err != nil {
+		return err
+	}
+
+	before := paymentSnapshot(req)
+	updates := map[string]interface{}{
+		"status":       status,
+		"provider_ref": req.ProviderRef,
+	}
+	if reason != "" {
+		updates["failure_reason"] = reason
+		req.FailureReason = reason
+	}
+	if status == model.PaymentStatusCompleted {
+		now := time.Now()
+		updates["completed_at"] = &now
+		req.CompletedAt = &now
+	}
+	if err := tx.Model(&model.PaymentRequest{}).Where("id = ?", req.ID).Updates(updates).Error; err != nil {
+		return err
+	}
+	req.Status = status
+
+	event := AuditEvent{
+		UserID:     req.UserID,
+		Action:     action,
+		TargetType: "payment",
+		TargetID:   req.RequestID,
+		Reason:     reason,
+	}
+
+	// 充值到账/冲正、提现失败/冲正需要变动余额，提现完成时金额已在申请时扣除
+	var entry *LedgerEntry
+	switch {
+	case req.Type == model.PaymentTypeDeposit && status == model.PaymentStatusCompleted:
+		entry = &LedgerEntry{Amount: req.Amount, Type: model.WalletTxDeposit}
+	case req.Type == model.PaymentTypeDeposit && status == model.PaymentStatusReversed:
+		// 拒付冲正必须入账，玩家已花掉充值金额时余额记为负数欠款，欠款期间所有币种都不能下注和提现
+		entry = &LedgerEntry{Amount: req.Amount.Neg(), Type: model.WalletTxDepositReversal, AllowDebt: true}
+	case req.Type == model.PaymentTypeWithdrawal && (status == model.PaymentStatusFailed || status == model.PaymentStatusReversed):
+		entry = &LedgerEntry{Amount: req.Amount, Type: model.WalletTxWithdrawalRefund}
+	}
+
+	if entry == nil {
+		event.Before = before
+		event.After = paymentSnapshot(req)
+		return s.audit.RecordTx(tx, meta, event)
+	}
+
+	entry.UserID = req.UserID
+	entry.Currency = cur.Code
+	entry.RefType = "payment"
+	entry.RefID = req.RequestID
+	if event.Reason == "" {
+		event.Reason = fmt.Sprintf("%s %s %s", req.Status, cur.Format(req.Amount), cur.Code)
+	}
+	_, err = s.wallet.PostTx(tx, *entry, meta, event)
+	return err
+}
+
+// lockPaymentTx 加行锁读取充值提现请求
+func lockPaymentTx(tx *gorm.DB, query string, arg interface{}, req *model.PaymentRequest) error {
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where(query, arg).First(req).Error
+}
+
+// paymentSnapshot 审计日志中记录的请求状态
+func paymentSnapshot(req *model.PaymentRequest) map[string]interface{} {
+	return map[string]interface{}{
+		"type":     req.Type,
+		"currency": req.Currency,
+		"amount":   req.Amount.String(),
+		"status":   req.Status,
+	}
+}
+
+// providerRequest 构造渠道请求
+func providerRequest(req *model.PaymentRequest) payment.Request {
+	return payment.Request{
+		RequestID: req.RequestID,
+		UserID:    req.UserID,
+		Type:      req.Type,
+		Currency:  req.Currency,
+		Amount:    req.Amount,
+	}
+}
+
+// GetUserPayments 获取用户充值提现记录，paymentType为空时返回全部
+func (s *PaymentService) GetUserPayments(userID uint, paymentType string, page PageQuery) ([]model.PaymentRequest, *Page, error) {
+	query := s.db.Model(&model.PaymentRequest{}).Where("user_id = ?", userID)
+	if paymentType != "" {
+		query = query.Where("type = ?", paymentType)
+	}
+	return paginatePayments(query, page)
+}
+
+// ListWithdrawals 获取提现审批队列，status为空时返回全部
+func (s *PaymentService) ListWithdrawals(status string, page PageQuery) ([]model.PaymentRequest, *Page, error) {
+	query := s.db.Model(&model.PaymentRequest{}).Where("type = ?", model.PaymentTypeWithdrawal)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	return paginatePayments(query, page)
+}
+
+// paginatePayments 按游标分页查询充值提现记录
+func paginatePayments(query *gorm.DB, page PageQuery) ([]model.PaymentRequest, *Page, error) {
+	return paginate(query, page, timeSorts[model.PaymentRequest](), func(r *model.PaymentRequest) uint64 { return r.ID })
+}
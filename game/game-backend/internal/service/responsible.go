@@ -0,0 +1,483 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"game-backend/config"
+	"game-backend/internal/model"
+	"game-backend/pkg/logger"
+	"game-backend/pkg/money"
+)
+
+var (
+	// ErrSelfExcluded 自我排除期内
+	ErrSelfExcluded = errors.New("账户处于自我排除期")
+	// ErrCoolOffActive 冷静期内
+	ErrCoolOffActive = errors.New("账户处于冷静期")
+	// ErrSessionLimitReached 会话时长已达上限
+	ErrSessionLimitReached = errors.New("本次游戏已达会话时长上限，请休息后再来")
+	// ErrWagerLimitExceeded 超出下注限额
+	ErrWagerLimitExceeded = errors.New("超出下注限额")
+	// ErrLossLimitExceeded 超出亏损限额
+	ErrLossLimitExceeded = errors.New("超出亏损限额")
+	// ErrDepositLimitExceeded 超出充值限额
+	ErrDepositLimitExceeded = errors.New("超出充值限额")
+	// ErrInvalidLimit 限额参数不合法
+	ErrInvalidLimit = errors.New("限额类型、周期或数值不合法")
+	// ErrInvalidExclusion 冷静期或自我排除参数不合法
+	ErrInvalidExclusion = errors.New("冷静期或自我排除天数不合法")
+)
+
+// limitWindows 限额周期对应的滚动窗口
+var limitWindows = map[string]time.Duration{
+	model.LimitPeriodDaily:   24 * time.Hour,
+	model.LimitPeriodWeekly:  7 * 24 * time.Hour,
+	model.LimitPeriodMonthly: 30 * 24 * time.Hour,
+}
+
+// ResponsibleSettings 玩家当前的负责任博彩设置
+type ResponsibleSettings struct {
+	BaseCurrency string                 `json:"base_currency"`
+	Limits       []model.PlayerLimit    `json:"limits"`
+	Exclusion    *model.PlayerExclusion `json:"exclusion,omitempty"`
+}
+
+// ResponsibleService 负责任博彩服务：玩家限额、会话时长、冷静期与自我排除
+type ResponsibleService struct {
+	db    *gorm.DB
+	audit *AuditService
+	log   *logrus.Entry
+}
+
+// NewResponsibleService 创建负责任博彩服务
+func NewResponsibleService(db *gorm.DB, audit *AuditService) *ResponsibleService {
+	return &ResponsibleService{
+		db:    db,
+		audit: audit,
+		log:   logrus.NewEntry(logrus.StandardLogger()),
+	}
+}
+
+// WithContext 返回绑定请求上下文的服务副本，数据库操作和日志携带请求字段
+func (s *ResponsibleService) WithContext(ctx context.Context) *ResponsibleService {
+	clone := *s
+	clone.db = s.db.WithContext(ctx)
+	clone.log = logger.FromContext(ctx)
+	return &clone
+}
+
+// GetSettings 获取玩家限额与生效中的冷静期/自我排除
+func (s *ResponsibleService) GetSettings(userID uint) (*ResponsibleSettings, error) {
+	limits, err := loadLimits(s.db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	exclusion, err := activeExclusion(s.db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ResponsibleSettings{
+		BaseCurrency: money.Base().Code,
+		Limits:       limits,
+		Exclusion:    exclusion,
+	}, nil
+}
+
+// SetLimit 设置限额，amount为0表示取消限额
+// 收紧（新设或调低）立即生效；放宽（调高或取消）等待冷却期后生效
+func (s *ResponsibleService) SetLimit(userID uint, limitType, period string, amount decimal.Decimal, meta AuditMeta) (*model.PlayerLimit, error) {
+	if limitType == model.LimitTypeSession {
+		period = model.LimitPeriodSession
+		if !amount.Equal(amount.Truncate(0)) {
+			return nil, ErrInvalidLimit
+		}
+	} else {
+		if limitType != model.LimitTypeDeposit && limitType != model.LimitTypeLoss && limitType != model.LimitTypeWager {
+			return nil, ErrInvalidLimit
+		}
+		if _, ok := limitWindows[period]; !ok {
+			return nil, ErrInvalidLimit
+		}
+		if !amount.Equal(amount.Truncate(money.Base().Precision)) {
+			return nil, ErrInvalidLimit
+		}
+	}
+	if amount.IsNegative() {
+		return nil, ErrInvalidLimit
+	}
+
+	var limit model.PlayerLimit
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND type = ? AND period = ?", userID, limitType, period).
+			First(&limit).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			limit = model.PlayerLimit{UserID: userID, Type: limitType, Period: period}
+		} else if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		promoteLimit(&limit, now)
+		before := limitSnapshot(&limit)
+
+		switch {
+		case limitTighter(limit.Amount, amount) || limit.Amount.Equal(amount):
+			limit.Amount = amount
+			limit.PendingAmount = nil
+			limit.PendingEffectiveAt = nil
+		default:
+			effectiveAt := now.Add(time.Duration(config.AppConfig.Responsible.CoolingPeriod) * time.Hour)
+			limit.PendingAmount = &amount
+			limit.PendingEffectiveAt = &effectiveAt
+		}
+
+		if err := tx.Save(&limit).Error; err != nil {
+			return err
+		}
+
+		return s.audit.RecordTx(tx, meta, AuditEvent{
+			UserID:     userID,
+			Action:     model.AuditActionLimitUpdate,
+			TargetType: "user",
+			TargetID:   strconv.FormatUint(uint64(userID), 10),
+			Reason:     fmt.Sprintf("设置%s限额(%s) %s", limitType, period, amount.String()),
+			Before:     before,
+			After:      limitSnapshot(&limit),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.WithFields(logrus.Fields{
+		logger.FieldUserID: userID,
+		"type":             limitType,
+		"period":           period,
+		"amount":           limit.Amount.String(),
+		"pending":          limit.PendingAmount != nil,
+	}).Info("玩家限额已更新")
+
+	return &limit, nil
+}
+
+// StartExclusion 开启冷静期或自我排除，到期前不可撤销，并清除当前登录会话使已签发的令牌失效
+// 玩家的WebSocket连接由调用方断开
+func (s *ResponsibleService) StartExclusion(userID uint, exclusionType string, days int, meta AuditMeta) (*model.PlayerExclusion, error) {
+	cfg := config.AppConfig.Responsible
+	switch exclusionType {
+	case model.ExclusionTypeCoolOff:
+		if days < 1 || days > cfg.CoolOffMaxDays {
+			return nil, ErrInvalidExclusion
+		}
+	case model.ExclusionTypeSelf:
+		if days < cfg.SelfExclusionMinDays {
+			return nil, ErrInvalidExclusion
+		}
+	default:
+		return nil, ErrInvalidExclusion
+	}
+
+	exclusion := &model.PlayerExclusion{
+		UserID: userID,
+		Type:   exclusionType,
+		Until:  time.Now().AddDate(0, 0, days),
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(exclusion).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&model.UserSession{}).Error; err != nil {
+			return err
+		}
+		return s.audit.RecordTx(tx, meta, AuditEvent{
+			UserID:     userID,
+			Action:     model.AuditActionExclusion,
+			TargetType: "user",
+			TargetID:   strconv.FormatUint(uint64(userID), 10),
+			Reason:     fmt.Sprintf("%s %d天", exclusionType, days),
+			After:      map[string]interface{}{"type": exclusionType, "until": exclusion.Until},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.WithFields(logrus.Fields{
+		logger.FieldUserID: userID,
+		"type":             exclusionType,
+		"until":            exclusion.Until,
+	}).Info("玩家自我限制已开启")
+
+	return exclusion, nil
+}
+
+// CheckBetTx 在下注事务中检查自我限制、会话时长以及下注/亏损限额，baseAmount为折算成基准币种的下注金额
+// 每笔下注都会更新游戏会话，下注被拒绝时随事务回滚，不计入会话
+func (s *ResponsibleService) CheckBetTx(tx *gorm.DB, userID uint, baseAmount decimal.Decimal) error {
+	if err := checkExclusion(tx, userID); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	startedAt, err := touchSessionTx(tx, userID, now)
+	if err != nil {
+		return err
+	}
+
+	limits, err := loadLimits(tx, userID)
+	if err != nil {
+		return err
+	}
+
+	locked := false
+	for _, limit := range limits {
+		if !limit.Amount.IsPositive() {
+			continue
+		}
+
+		switch limit.Type {
+		case model.LimitTypeSession:
+			if err := checkSession(startedAt, now, limit.Amount); err != nil {
+				return err
+			}
+
+		case model.LimitTypeWager, model.LimitTypeLoss:
+			// 锁定用户行，串行化同一用户的下注以保证限额准确
+			if !locked {
+				if err := lockUserTx(tx, userID); err != nil {
+					return err
+				}
+				locked = true
+			}
+
+			wagered, lost, err := betTotalsSince(tx, userID, time.Now().Add(-limitWindows[limit.Period]))
+			if err != nil {
+				return err
+			}
+			if limit.Type == model.LimitTypeWager && wagered.Add(baseAmount).GreaterThan(limit.Amount) {
+				return ErrWagerLimitExceeded
+			}
+			if limit.Type == model.LimitTypeLoss && lost.Add(baseAmount).GreaterThan(limit.Amount) {
+				return ErrLossLimitExceeded
+			}
+		}
+	}
+
+	return nil
+}
+
+// CheckDepositTx 在充值事务中检查自我限制与充值限额，baseAmount为折算成基准币种的充值金额
+func (s *ResponsibleService) CheckDepositTx(tx *gorm.DB, userID uint, baseAmount decimal.Decimal) error {
+	if err := checkExclusion(tx, userID); err != nil {
+		return err
+	}
+
+	limits, err := loadLimits(tx, userID)
+	if err != nil {
+		return err
+	}
+
+	locked := false
+	for _, limit := range limits {
+		if limit.Type != model.LimitTypeDeposit || !limit.Amount.IsPositive() {
+			continue
+		}
+		if !locked {
+			if err := lockUserTx(tx, userID); err != nil {
+				return err
+			}
+			locked = true
+		}
+
+		deposited, err := paymentsSinceTx(tx, userID, model.PaymentTypeDeposit, time.Now().Add(-limitWindows[limit.Period]))
+		if err != nil {
+			return err
+		}
+		if deposited.Add(baseAmount).GreaterThan(limit.Amount) {
+			return ErrDepositLimitExceeded
+		}
+	}
+
+	return nil
+}
+
+// checkExclusion 检查生效中的冷静期/自我排除
+func checkExclusion(db *gorm.DB, userID uint) error {
+	exclusion, err := activeExclusion(db, userID)
+	if err != nil || exclusion == nil {
+		return err
+	}
+	if exclusion.Type == model.ExclusionTypeSelf {
+		return ErrSelfExcluded
+	}
+	return ErrCoolOffActive
+}
+
+// activeExclusion 获取到期时间最晚且未到期的冷静期/自我排除
+func activeExclusion(db *gorm.DB, userID uint) (*model.PlayerExclusion, error) {
+	var exclusion model.PlayerExclusion
+	err := db.Where("user_id = ? AND until > ?", userID, time.Now()).
+		Order("until DESC").
+		First(&exclusion).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &exclusion, nil
+}
+
+// touchSessionTx 记录一次下注活动并返回当前游戏会话的开始时间：
+// 没有会话记录或距上次下注已达session_break时从now开始新的会话
+func touchSessionTx(tx *gorm.DB, userID uint, now time.Time) (time.Time, error) {
+	breakStart := now.Add(-time.Duration(config.AppConfig.Responsible.SessionBreak) * time.Minute)
+
+	// 按顺序赋值，started_at需要用更新前的last_active_at判断
+	err := tx.Clauses(clause.OnConflict{
+		DoUpdates: clause.Set{
+			{Column: clause.Column{Name: "started_at"}, Value: gorm.Expr("IF(last_active_at <= ?, ?, started_at)", breakStart, now)},
+			{Column: clause.Column{Name: "last_active_at"}, Value: now},
+		},
+	}).Create(&model.PlayerSession{UserID: userID, StartedAt: now, LastActiveAt: now}).Error
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var session model.PlayerSession
+	if err := tx.Select("started_at").Where("user_id = ?", userID).First(&session).Error; err != nil {
+		return time.Time{}, err
+	}
+	return session.StartedAt, nil
+}
+
+// checkSessionLimit 不记录活动地检查游戏会话：设置了会话时长且距上次下注尚未休息满session_break时，会话超过上限即拒绝
+func checkSessionLimit(db *gorm.DB, userID uint) error {
+	limits, err := loadLimits(db, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, limit := range limits {
+		if limit.Type != model.LimitTypeSession || !limit.Amount.IsPositive() {
+			continue
+		}
+
+		var session model.PlayerSession
+		err := db.Where("user_id = ?", userID).First(&session).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		breakStart := now.Add(-time.Duration(config.AppConfig.Responsible.SessionBreak) * time.Minute)
+		if !session.LastActiveAt.After(breakStart) {
+			return nil
+		}
+		return checkSession(session.StartedAt, now, limit.Amount)
+	}
+
+	return nil
+}
+
+// checkSession 检查游戏会话是否超过时长上限（分钟）
+func checkSession(startedAt, now time.Time, minutes decimal.Decimal) error {
+	if now.Sub(startedAt) > time.Duration(minutes.IntPart())*time.Minute {
+		return ErrSessionLimitReached
+	}
+	return nil
+}
+
+// loadLimits 读取玩家限额，已到期的放宽值按生效后返回
+func loadLimits(db *gorm.DB, userID uint) ([]model.PlayerLimit, error) {
+	var limits []model.PlayerLimit
+	if err := db.Where("user_id = ?", userID).Order("type, period").Find(&limits).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for i := range limits {
+		promoteLimit(&limits[i], now)
+	}
+	return limits, nil
+}
+
+// promoteLimit 待生效的放宽值到期后替换当前值
+func promoteLimit(limit *model.PlayerLimit, now time.Time) {
+	if limit.PendingAmount == nil || limit.PendingEffectiveAt == nil || limit.PendingEffectiveAt.After(now) {
+		return
+	}
+	limit.Amount = *limit.PendingAmount
+	limit.PendingAmount = nil
+	limit.PendingEffectiveAt = nil
+}
+
+// limitTighter 判断新限额是否比当前限额更严格（0表示不限）
+func limitTighter(current, next decimal.Decimal) bool {
+	if !next.IsPositive() {
+		return false
+	}
+	return !current.IsPositive() || next.LessThan(current)
+}
+
+// limitSnapshot 审计日志中记录的限额状态
+func limitSnapshot(limit *model.PlayerLimit) map[string]interface{} {
+	snapshot := map[string]interface{}{
+		"type":   limit.Type,
+		"period": limit.Period,
+		"amount": limit.Amount.String(),
+	}
+	if limit.PendingAmount != nil {
+		snapshot["pending_amount"] = limit.PendingAmount.String()
+		snapshot["pending_effective_at"] = limit.PendingEffectiveAt
+	}
+	return snapshot
+}
+
+// lockUserTx 加行锁读取用户，串行化同一用户的资金操作
+func lockUserTx(tx *gorm.DB, userID uint) error {
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id").First(&model.User{}, userID).Error
+}
+
+// betTotalsSince 统计指定时间后的下注总额与净亏损（折算为基准币种），进行中的下注按全部亏损计，已撤销的下注不计
+func betTotalsSince(tx *gorm.DB, userID uint, since time.Time) (decimal.Decimal, decimal.Decimal, error) {
+	var rows []struct {
+		Currency string
+		Wagered  decimal.Decimal
+		Lost     decimal.Decimal
+	}
+	err := tx.Model(&model.Bet{}).
+		Select("currency, SUM(amount) AS wagered, SUM(amount - payout) AS lost").
+		Where("user_id = ? AND created_at >= ? AND status <> 3", userID, since).
+		Group("currency").
+		Scan(&rows).Error
+	if err != nil {
+		return decimal.Zero, decimal.Zero, err
+	}
+
+	wagered, lost := decimal.Zero, decimal.Zero
+	for _, row := range rows {
+		if cur, err := money.Lookup(row.Currency); err == nil {
+			wagered = wagered.Add(cur.ToBase(row.Wagered))
+			lost = lost.Add(cur.ToBase(row.Lost))
+		}
+	}
+	return wagered, lost, nil
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"game-backend/config"
+	"game-backend/internal/model"
+)
+
+func TestCheckSessionLimitAtLogin(t *testing.T) {
+	previous := config.AppConfig
+	config.AppConfig = &config.Config{Responsible: config.ResponsibleConfig{SessionBreak: 30}}
+	t.Cleanup(func() { config.AppConfig = previous })
+
+	now := time.Now()
+	tests := []struct {
+		name       string
+		limit      string // 为空表示未设置会话时长限额
+		startedAt  time.Time
+		lastActive time.Time
+		wantErr    error
+	}{
+		{"未设置限额", "", now.Add(-3 * time.Hour), now.Add(-time.Minute), nil},
+		{"会话未超时", "60", now.Add(-20 * time.Minute), now.Add(-time.Minute), nil},
+		{"会话超时且休息不足", "60", now.Add(-90 * time.Minute), now.Add(-10 * time.Minute), ErrSessionLimitReached},
+		{"会话超时但已休息足够", "60", now.Add(-3 * time.Hour), now.Add(-40 * time.Minute), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDB(t)
+			if tt.limit != "" {
+				fake.rows["player_limits"] = []fakeRow{{"id": int64(1), "user_id": int64(1), "type": model.LimitTypeSession, "period": "", "amount": tt.limit}}
+			}
+			fake.rows["player_sessions"] = []fakeRow{{"user_id": int64(1), "started_at": tt.startedAt, "last_active_at": tt.lastActive}}
+
+			if err := checkSessionLimit(db, 1); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"game-backend/config"
+	"game-backend/pkg/money"
+)
+
+// setupCurrencies 加载测试用币种表：CNY精度2位
+func setupCurrencies(t *testing.T) money.Currency {
+	t.Helper()
+
+	err := money.Init(config.WalletConfig{
+		BaseCurrency: "CNY",
+		Currencies: []config.CurrencyConfig{
+			{Code: "CNY", Precision: 2, MinBet: 1, MaxBet: 10000, Rate: 1},
+			{Code: "USD", Precision: 2, MinBet: 1, MaxBet: 1000, Rate: 7},
+		},
+	})
+	if err != nil {
+		t.Fatalf("初始化币种失败: %v", err)
+	}
+
+	cur, _ := money.Lookup("CNY")
+	return cur
+}
+
+// fakeRow 查询返回的一行，列名到值
+type fakeRow map[string]driver.Value
+
+// fakeExec 一条已执行的语句
+type fakeExec struct {
+	query string
+	args  []driver.Value
+}
+
+// fakeDB 不依赖MySQL的测试数据库：按表名返回预设的查询结果，记录执行过的所有语句
+// 未预设的表查询为空，写语句默认影响1行
+type fakeDB struct {
+	mutex    sync.Mutex
+	rows     map[string][]fakeRow
+	affected map[string]int64 // 按表名指定UPDATE影响的行数
+	execs    []fakeExec
+	queries  []fakeExec
+}
+
+var (
+	fakeTable = regexp.MustCompile("(?:FROM|UPDATE|INTO) `(\\w+)`")
+	// errFakePrepare 测试连接只支持直接执行语句
+	errFakePrepare = errors.New("不支持预处理语句")
+)
+
+// newFakeDB 创建测试数据库
+func newFakeDB(t *testing.T) (*gorm.DB, *fakeDB) {
+	t.Helper()
+
+	fake := &fakeDB{
+		rows:     make(map[string][]fakeRow),
+		affected: make(map[string]int64),
+	}
+	db, err := gorm.Open(mysql.New(mysql.Config{
+		Conn:                      sql.OpenDB(fake),
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	return db, fake
+}
+
+// executed 返回对table执行的op（INSERT/UPDATE）语句
+func (f *fakeDB) executed(op, table string) []fakeExec {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	var execs []fakeExec
+	for _, exec := range f.execs {
+		if strings.HasPrefix(exec.query, op) && tableOf(exec.query) == table {
+			execs = append(execs, exec)
+		}
+	}
+	return execs
+}
+
+func tableOf(query string) string {
+	if m := fakeTable.FindStringSubmatch(query); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return &fakeConn{db: f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return f }
+func (f *fakeDB) Open(string) (driver.Conn, error)             { return &fakeConn{db: f}, nil }
+
+// fakeConn 测试连接，事务提交与回滚均不做任何事
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errFakePrepare }
+func (c *fakeConn) Close() error                        { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)           { return c, nil }
+func (c *fakeConn) Commit() error                       { return nil }
+func (c *fakeConn) Rollback() error                     { return nil }
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.db.mutex.Lock()
+	defer c.db.mutex.Unlock()
+
+	c.db.execs = append(c.db.execs, fakeExec{query: query, args: namedValues(args)})
+
+	affected := int64(1)
+	if n, ok := c.db.affected[tableOf(query)]; ok && strings.HasPrefix(query, "UPDATE") {
+		affected = n
+	}
+	return fakeResult(affected), nil
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.db.mutex.Lock()
+	defer c.db.mutex.Unlock()
+
+	c.db.queries = append(c.db.queries, fakeExec{query: query, args: namedValues(args)})
+	rows := c.db.rows[tableOf(query)]
+	var columns []string
+	if len(rows) > 0 {
+		for column := range rows[0] {
+			columns = append(columns, column)
+		}
+		sort.Strings(columns)
+	}
+	return &fakeRows{columns: columns, rows: rows}, nil
+}
+
+func namedValues(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	return values
+}
+
+type fakeResult int64
+
+func (r fakeResult) LastInsertId() (int64, error) { return 1, nil }
+func (r fakeResult) RowsAffected() (int64, error) { return int64(r), nil }
+
+type fakeRows struct {
+	columns []string
+	rows    []fakeRow
+	next    int
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next == len(r.rows) {
+		return io.EOF
+	}
+	for i, column := range r.columns {
+		dest[i] = r.rows[r.next][column]
+	}
+	r.next++
+	return nil
+}
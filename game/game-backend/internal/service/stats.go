@@ -0,0 +1,336 @@
+package service
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"game-backend/internal/model"
+	"game-backend/pkg/money"
+)
+
+// ErrInvalidStatsRange 统计日期范围不合法
+var ErrInvalidStatsRange = errors.New("统计日期范围不合法，结束日期不能早于开始日期且最长366天")
+
+const (
+	// maxStatsDays 每日统计一次最多查询的天数
+	maxStatsDays = 366
+	// defaultStatsDays 未指定日期范围时返回最近的每日统计天数
+	defaultStatsDays = 30
+	// statsDayLayout 统计日期格式
+	statsDayLayout = "2006-01-02"
+)
+
+// StatsQuery 玩家统计查询条件，日期为服务器时区的自然日并包含首尾两天，均为空时汇总返回累计统计
+type StatsQuery struct {
+	Currency  string
+	StartDate *time.Time
+	EndDate   *time.Time
+}
+
+// StatsStreaks 连胜连败，仅累计统计
+type StatsStreaks struct {
+	Current     int64 `json:"current"` // 大于0为当前连胜，小于0为当前连败
+	LongestWin  int64 `json:"longest_win"`
+	LongestLoss int64 `json:"longest_loss"`
+}
+
+// StatsSummary 玩家一个币种的统计汇总，赔付大于本金的下注为赢
+type StatsSummary struct {
+	Currency             string          `json:"currency"`
+	TotalBets            int64           `json:"total_bets"`
+	Wins                 int64           `json:"wins"`
+	Losses               int64           `json:"losses"`
+	WinRate              decimal.Decimal `json:"win_rate"` // 0-1
+	TotalWagered         decimal.Decimal `json:"total_wagered"`
+	TotalWinnings        decimal.Decimal `json:"total_winnings"`
+	NetProfit            decimal.Decimal `json:"net_profit"`
+	Cashouts             int64           `json:"cashouts"`
+	AvgCashoutMultiplier decimal.Decimal `json:"avg_cashout_multiplier"`
+	BiggestMultiplier    decimal.Decimal `json:"biggest_multiplier"`
+	GamesPlayed          *int64          `json:"games_played,omitempty"` // 仅累计统计
+	Streaks              *StatsStreaks   `json:"streaks,omitempty"`      // 仅累计统计
+}
+
+// DailyStats 玩家一个币种一天的统计
+type DailyStats struct {
+	Day                  string          `json:"day"`
+	Currency             string          `json:"currency"`
+	Bets                 int64           `json:"bets"`
+	Wins                 int64           `json:"wins"`
+	Losses               int64           `json:"losses"`
+	Wagered              decimal.Decimal `json:"wagered"`
+	Winnings             decimal.Decimal `json:"winnings"`
+	NetProfit            decimal.Decimal `json:"net_profit"`
+	Cashouts             int64           `json:"cashouts"`
+	AvgCashoutMultiplier decimal.Decimal `json:"avg_cashout_multiplier"`
+	BiggestMultiplier    decimal.Decimal `json:"biggest_multiplier"`
+}
+
+// PlayerStats 玩家统计，Currencies为累计或日期范围内的汇总，Daily为日期范围内有结算的日期，按日期、币种排序
+type PlayerStats struct {
+	BaseCurrency      string          `json:"base_currency"`
+	StartDate         string          `json:"start_date"`
+	EndDate           string          `json:"end_date"`
+	TotalWageredBase  decimal.Decimal `json:"total_wagered_base"`
+	TotalWinningsBase decimal.Decimal `json:"total_winnings_base"`
+	NetProfitBase     decimal.Decimal `json:"net_profit_base"`
+	Currencies        []StatsSummary  `json:"currencies"`
+	Daily             []DailyStats    `json:"daily"`
+}
+
+// statsDay at所在自然日的零点
+func statsDay(at time.Time) time.Time {
+	year, month, day := at.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, at.Location())
+}
+
+// ratio a/b保留places位小数，b为0时返回0
+func ratio(a, b decimal.Decimal, places int32) decimal.Decimal {
+	if b.IsZero() {
+		return decimal.Zero
+	}
+	return a.DivRound(b, places)
+}
+
+// recordStatsTx 在结算事务中更新玩家累计统计与当日统计
+// settlement不为空时计入一次止盈结算；下注已结束（全部止盈或崩盘）时计入下注数、投注额、赔付、输赢与连胜连败
+func recordStatsTx(tx *gorm.DB, bet *model.Bet, settlement *model.BetSettlement, at time.Time) error {
+	finished := bet.Status == 1 || bet.Status == 2
+	if settlement == nil && !finished {
+		return nil
+	}
+
+	// 首次结算时创建统计记录，之后锁定该记录，同一玩家的并发结算串行更新
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&model.UserStats{UserID: bet.UserID, Currency: bet.Currency}).Error; err != nil {
+		return err
+	}
+	var stats model.UserStats
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND currency = ?", bet.UserID, bet.Currency).First(&stats).Error; err != nil {
+		return err
+	}
+
+	daily := model.UserDailyStats{
+		UserID:   bet.UserID,
+		Currency: bet.Currency,
+		Day:      statsDay(at),
+	}
+	increments := map[string]interface{}{}
+
+	if settlement != nil {
+		stats.Cashouts++
+		stats.CashoutMultiplierSum = stats.CashoutMultiplierSum.Add(settlement.Multiplier)
+		if settlement.Multiplier.GreaterThan(stats.BiggestMultiplier) {
+			stats.BiggestMultiplier = settlement.Multiplier
+		}
+
+		daily.Cashouts = 1
+		daily.CashoutMultiplierSum = settlement.Multiplier
+		daily.BiggestMultiplier = settlement.Multiplier
+		increments["cashouts"] = gorm.Expr("cashouts + 1")
+		increments["cashout_multiplier_sum"] = gorm.Expr("cashout_multiplier_sum + ?", settlement.Multiplier)
+		increments["biggest_multiplier"] = gorm.Expr("GREATEST(biggest_multiplier, ?)", settlement.Multiplier)
+	}
+
+	if finished {
+		win := bet.Payout.GreaterThan(bet.Amount)
+		stats.TotalBets++
+		stats.TotalWagered = stats.TotalWagered.Add(bet.Amount)
+		stats.TotalWinnings = stats.TotalWinnings.Add(bet.Payout)
+		if win {
+			stats.Wins++
+			if stats.CurrentStreak < 0 {
+				stats.CurrentStreak = 0
+			}
+			stats.CurrentStreak++
+			if stats.CurrentStreak > stats.LongestWinStreak {
+				stats.LongestWinStreak = stats.CurrentStreak
+			}
+		} else {
+			stats.Losses++
+			if stats.CurrentStreak > 0 {
+				stats.CurrentStreak = 0
+			}
+			stats.CurrentStreak--
+			if -stats.CurrentStreak > stats.LongestLossStreak {
+				stats.LongestLossStreak = -stats.CurrentStreak
+			}
+		}
+		// 同一局的多笔下注只计一局
+		if stats.LastRoundID != bet.RoundID {
+			stats.GamesPlayed++
+			stats.LastRoundID = bet.RoundID
+		}
+
+		daily.Bets = 1
+		daily.Wagered = bet.Amount
+		daily.Winnings = bet.Payout
+		increments["bets"] = gorm.Expr("bets + 1")
+		increments["wagered"] = gorm.Expr("wagered + ?", bet.Amount)
+		increments["winnings"] = gorm.Expr("winnings + ?", bet.Payout)
+		if win {
+			daily.Wins = 1
+			increments["wins"] = gorm.Expr("wins + 1")
+		} else {
+			daily.Losses = 1
+			increments["losses"] = gorm.Expr("losses + 1")
+		}
+	}
+
+	if err := tx.Save(&stats).Error; err != nil {
+		return err
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "currency"}, {Name: "day"}},
+		DoUpdates: clause.Assignments(increments),
+	}).Create(&daily).Error
+}
+
+// GetUserStats 获取玩家统计：未指定日期时返回各币种累计统计与最近30天的每日统计，
+// 指定日期时返回该范围内的汇总与每日统计；currency为空时返回所有币种
+func (s *GameService) GetUserStats(userID uint, query StatsQuery) (*PlayerStats, error) {
+	if query.Currency != "" {
+		cur, err := money.Lookup(query.Currency)
+		if err != nil {
+			return nil, err
+		}
+		query.Currency = cur.Code
+	}
+
+	lifetime := query.StartDate == nil && query.EndDate == nil
+	end := statsDay(time.Now())
+	if query.EndDate != nil {
+		end = statsDay(*query.EndDate)
+	}
+	start := end.AddDate(0, 0, -(defaultStatsDays - 1))
+	if query.StartDate != nil {
+		start = statsDay(*query.StartDate)
+	}
+	if end.Before(start) || end.Sub(start) >= maxStatsDays*24*time.Hour {
+		return nil, ErrInvalidStatsRange
+	}
+
+	db := s.db.Where("user_id = ? AND day BETWEEN ? AND ?", userID, start, end)
+	if query.Currency != "" {
+		db = db.Where("currency = ?", query.Currency)
+	}
+	var days []model.UserDailyStats
+	if err := db.Order("day, currency").Find(&days).Error; err != nil {
+		return nil, err
+	}
+
+	result := &PlayerStats{
+		BaseCurrency: money.Base().Code,
+		StartDate:    start.Format(statsDayLayout),
+		EndDate:      end.Format(statsDayLayout),
+		Currencies:   []StatsSummary{},
+		Daily:        make([]DailyStats, 0, len(days)),
+	}
+	for _, day := range days {
+		result.Daily = append(result.Daily, DailyStats{
+			Day:                  day.Day.Format(statsDayLayout),
+			Currency:             day.Currency,
+			Bets:                 day.Bets,
+			Wins:                 day.Wins,
+			Losses:               day.Losses,
+			Wagered:              day.Wagered,
+			Winnings:             day.Winnings,
+			NetProfit:            day.Winnings.Sub(day.Wagered),
+			Cashouts:             day.Cashouts,
+			AvgCashoutMultiplier: ratio(day.CashoutMultiplierSum, decimal.NewFromInt(day.Cashouts), money.MultiplierScale),
+			BiggestMultiplier:    day.BiggestMultiplier,
+		})
+	}
+
+	if lifetime {
+		db := s.db.Where("user_id = ?", userID)
+		if query.Currency != "" {
+			db = db.Where("currency = ?", query.Currency)
+		}
+		var stats []model.UserStats
+		if err := db.Order("currency").Find(&stats).Error; err != nil {
+			return nil, err
+		}
+		for i := range stats {
+			result.Currencies = append(result.Currencies, lifetimeSummary(&stats[i]))
+		}
+	} else {
+		result.Currencies = rangeSummaries(days)
+	}
+
+	for _, summary := range result.Currencies {
+		cur, err := money.Lookup(summary.Currency)
+		if err != nil {
+			continue
+		}
+		result.TotalWageredBase = result.TotalWageredBase.Add(cur.ToBase(summary.TotalWagered))
+		result.TotalWinningsBase = result.TotalWinningsBase.Add(cur.ToBase(summary.TotalWinnings))
+	}
+	result.NetProfitBase = result.TotalWinningsBase.Sub(result.TotalWageredBase)
+	return result, nil
+}
+
+// lifetimeSummary 累计统计的汇总
+func lifetimeSummary(stats *model.UserStats) StatsSummary {
+	gamesPlayed := stats.GamesPlayed
+	return StatsSummary{
+		Currency:             stats.Currency,
+		TotalBets:            stats.TotalBets,
+		Wins:                 stats.Wins,
+		Losses:               stats.Losses,
+		WinRate:              ratio(decimal.NewFromInt(stats.Wins), decimal.NewFromInt(stats.TotalBets), 4),
+		TotalWagered:         stats.TotalWagered,
+		TotalWinnings:        stats.TotalWinnings,
+		NetProfit:            stats.TotalWinnings.Sub(stats.TotalWagered),
+		Cashouts:             stats.Cashouts,
+		AvgCashoutMultiplier: ratio(stats.CashoutMultiplierSum, decimal.NewFromInt(stats.Cashouts), money.MultiplierScale),
+		BiggestMultiplier:    stats.BiggestMultiplier,
+		GamesPlayed:          &gamesPlayed,
+		Streaks: &StatsStreaks{
+			Current:     stats.CurrentStreak,
+			LongestWin:  stats.LongestWinStreak,
+			LongestLoss: stats.LongestLossStreak,
+		},
+	}
+}
+
+// rangeSummaries 按币种汇总每日统计，按币种排序
+func rangeSummaries(days []model.UserDailyStats) []StatsSummary {
+	byCurrency := make(map[string]*StatsSummary)
+	var currencies []string
+	multiplierSums := make(map[string]decimal.Decimal)
+	for _, day := range days {
+		summary, ok := byCurrency[day.Currency]
+		if !ok {
+			summary = &StatsSummary{Currency: day.Currency}
+			byCurrency[day.Currency] = summary
+			currencies = append(currencies, day.Currency)
+		}
+		summary.TotalBets += day.Bets
+		summary.Wins += day.Wins
+		summary.Losses += day.Losses
+		summary.TotalWagered = summary.TotalWagered.Add(day.Wagered)
+		summary.TotalWinnings = summary.TotalWinnings.Add(day.Winnings)
+		summary.Cashouts += day.Cashouts
+		multiplierSums[day.Currency] = multiplierSums[day.Currency].Add(day.CashoutMultiplierSum)
+		if day.BiggestMultiplier.GreaterThan(summary.BiggestMultiplier) {
+			summary.BiggestMultiplier = day.BiggestMultiplier
+		}
+	}
+
+	sort.Strings(currencies)
+	summaries := make([]StatsSummary, 0, len(currencies))
+	for _, code := range currencies {
+		summary := byCurrency[code]
+		summary.WinRate = ratio(decimal.NewFromInt(summary.Wins), decimal.NewFromInt(summary.TotalBets), 4)
+		summary.NetProfit = summary.TotalWinnings.Sub(summary.TotalWagered)
+		summary.AvgCashoutMultiplier = ratio(multiplierSums[code], decimal.NewFromInt(summary.Cashouts), money.MultiplierScale)
+		summaries = append(summaries, *summary)
+	}
+	return summaries
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"game-backend/internal/model"
+	"game-backend/pkg/logger"
+	"game-backend/pkg/money"
+)
+
+// ErrWalletInDebt 任一币种钱包存在欠款（负余额）时拒绝下注和提现
+var ErrWalletInDebt = errors.New("钱包存在欠款，请先充值补足")
+
+// LedgerEntry 待记账的余额变动
+type LedgerEntry struct {
+	UserID   uint
+	Currency string
+	Amount   decimal.Decimal // 正数入账，负数出账
+	Type     string
+	RefType  string
+	RefID    string
+	// AllowDebt 允许出账后余额为负，负余额即玩家欠款，之后入账先抵扣欠款
+	// 只用于不能拒绝的出账，如充值被渠道拒付后的冲正
+	AllowDebt bool
+}
+
+// WalletService 钱包服务，所有余额变动都通过钱包流水记账
+type WalletService struct {
+	db    *gorm.DB
+	audit *AuditService
+	log   *logrus.Entry
+}
+
+// NewWalletService 创建钱包服务
+func NewWalletService(db *gorm.DB, audit *AuditService) *WalletService {
+	return &WalletService{
+		db:    db,
+		audit: audit,
+		log:   logrus.NewEntry(logrus.StandardLogger()),
+	}
+}
+
+// WithContext 返回绑定请求上下文的服务副本，数据库操作和日志携带请求字段
+func (s *WalletService) WithContext(ctx context.Context) *WalletService {
+	clone := *s
+	clone.db = s.db.WithContext(ctx)
+	clone.log = logger.FromContext(ctx)
+	return &clone
+}
+
+// Post 在独立事务中记账
+func (s *WalletService) Post(entry LedgerEntry, meta AuditMeta, event AuditEvent) (*model.WalletTransaction, error) {
+	var record *model.WalletTransaction
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		record, err = s.PostTx(tx, entry, meta, event)
+		return err
+	})
+	return record, err
+}
+
+// PostTx 在事务中变更钱包余额，写入钱包流水和变更前后的审计日志
+// 入账时钱包不存在会自动创建，出账后余额不能为负（AllowDebt的出账除外）
+func (s *WalletService) PostTx(tx *gorm.DB, entry LedgerEntry, meta AuditMeta, event AuditEvent) (*model.WalletTransaction, error) {
+	cur, err := money.Lookup(entry.Currency)
+	if err != nil {
+		return nil, err
+	}
+	if !entry.Amount.Equal(entry.Amount.Truncate(cur.Precision)) {
+		return nil, money.ErrInvalidAmount
+	}
+
+	wallet, err := lockWalletTx(tx, entry.UserID, cur.Code)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if entry.Amount.IsNegative() && !entry.AllowDebt {
+			return nil, ErrInsufficientBalance
+		}
+		// 并发创建时以唯一索引为准，随后重新加锁读取
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&model.Wallet{UserID: entry.UserID, Currency: cur.Code}).Error; err != nil {
+			return nil, err
+		}
+		wallet, err = lockWalletTx(tx, entry.UserID, cur.Code)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	after := wallet.Balance.Add(entry.Amount)
+	if after.IsNegative() && !entry.AllowDebt {
+		return nil, ErrInsufficientBalance
+	}
+
+	if err := tx.Model(&model.Wallet{}).
+		Where("id = ?", wallet.ID).
+		Update("balance", gorm.Expr("balance + ?", entry.Amount)).Error; err != nil {
+		return nil, err
+	}
+
+	record := &model.WalletTransaction{
+		WalletID:     wallet.ID,
+		UserID:       entry.UserID,
+		Currency:     cur.Code,
+		Type:         entry.Type,
+		Amount:       entry.Amount,
+		BalanceAfter: after,
+		RefType:      entry.RefType,
+		RefID:        entry.RefID,
+	}
+	if err := tx.Create(record).Error; err != nil {
+		return nil, err
+	}
+
+	if event.UserID == 0 {
+		event.UserID = entry.UserID
+	}
+	if event.Before == nil {
+		event.Before = map[string]interface{}{"currency": cur.Code, "balance": cur.Format(wallet.Balance)}
+	}
+	if event.After == nil {
+		event.After = map[string]interface{}{"currency": cur.Code, "balance": cur.Format(after)}
+	}
+
+	if err := s.audit.RecordTx(tx, meta, event); err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// checkWalletDebtTx 在事务中检查用户是否有负余额的钱包，欠款未补足前所有币种都不能下注和提现
+func checkWalletDebtTx(tx *gorm.DB, userID uint) error {
+	var wallet model.Wallet
+	err := tx.Select("id").Where("user_id = ? AND balance < 0", userID).First(&wallet).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return ErrWalletInDebt
+}
+
+// lockWalletTx 加行锁读取钱包
+func lockWalletTx(tx *gorm.DB, userID uint, currency string) (*model.Wallet, error) {
+	var wallet model.Wallet
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND currency = ?", userID, currency).
+		First(&wallet).Error
+	return &wallet, err
+}
+
+// GetWallets 获取用户全部钱包
+func (s *WalletService) GetWallets(userID uint) ([]model.Wallet, error) {
+	var wallets []model.Wallet
+	err := s.db.Where("user_id = ?", userID).Order("currency").Find(&wallets).Error
+	return wallets, err
+}
+
+// GetWallet 获取用户指定币种钱包，不存在时返回零余额
+func (s *WalletService) GetWallet(userID uint, currency string) (*model.Wallet, error) {
+	cur, err := money.Lookup(currency)
+	if err != nil {
+		return nil, err
+	}
+
+	var wallet model.Wallet
+	err = s.db.Where("user_id = ? AND currency = ?", userID, cur.Code).First(&wallet).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &model.Wallet{UserID: userID, Currency: cur.Code}, nil
+	}
+	return &wallet, err
+}
+
+// GetTransactions 获取用户钱包流水，currency为空时返回全部币种
+func (s *WalletService) GetTransactions(userID uint, currency string, page PageQuery) ([]model.WalletTransaction, *Page, error) {
+	query := s.db.Model(&model.WalletTransaction{}).Where("user_id = ?", userID)
+	if currency != "" {
+		cur, err := money.Lookup(currency)
+		if err != nil {
+			return nil, nil, err
+		}
+		query = query.Where("currency = ?", cur.Code)
+	}
+
+	return paginate(query, page, timeSorts[model.WalletTransaction](), func(t *model.WalletTransaction) uint64 { return t.ID })
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"game-backend/config"
+	"game-backend/internal/model"
+	"game-backend/internal/payment"
+	"game-backend/pkg/money"
+)
+
+func TestPostTxRejectsNegativeBalance(t *testing.T) {
+	setupCurrencies(t)
+
+	tests := []struct {
+		name      string
+		balance   string // 为空表示钱包不存在
+		amount    string
+		allowDebt bool
+		wantAfter string
+		wantErr   error
+	}{
+		{"入账", "50", "20", false, "70", nil},
+		{"出账至零", "50", "-50", false, "0", nil},
+		{"余额不足", "50", "-50.01", false, "", ErrInsufficientBalance},
+		{"钱包不存在时出账", "", "-1", false, "", ErrInsufficientBalance},
+		{"钱包不存在时入账", "", "5", false, "5", nil},
+		{"超出币种精度", "50", "-0.001", false, "", money.ErrInvalidAmount},
+		{"冲正记为欠款", "30", "-50", true, "-20", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDB(t)
+			if tt.balance != "" || tt.wantErr == nil {
+				balance := tt.balance
+				if balance == "" {
+					balance = "0"
+				}
+				// 钱包不存在时入账会先创建再读取，读取到新建的零余额钱包
+				fake.rows["wallets"] = []fakeRow{{"id": int64(7), "user_id": int64(1), "currency": "CNY", "balance": balance}}
+			}
+			fake.rows["audit_chain_head"] = []fakeRow{{"id": int64(1), "last_hash": auditGenesisHash}}
+
+			wallet := NewWalletService(db, NewAuditService(db))
+			record, err := wallet.PostTx(db, LedgerEntry{
+				UserID:    1,
+				Currency:  "CNY",
+				Amount:    decimal.RequireFromString(tt.amount),
+				Type:      model.WalletTxBet,
+				AllowDebt: tt.allowDebt,
+			}, SystemAuditMeta(), AuditEvent{Action: model.AuditActionBetPlace})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+
+			updates := fake.executed("UPDATE", "wallets")
+			if tt.wantErr != nil {
+				if len(updates) != 0 || len(fake.executed("INSERT", "wallet_transactions")) != 0 {
+					t.Fatalf("被拒绝的变更不应修改余额或写入流水")
+				}
+				return
+			}
+			if len(updates) != 1 || !record.BalanceAfter.Equal(decimal.RequireFromString(tt.wantAfter)) {
+				t.Fatalf("balance_after = %s, want %s（余额更新 %d 次）", record.BalanceAfter, tt.wantAfter, len(updates))
+			}
+		})
+	}
+}
+
+func TestWalletDebtBlocksOtherCurrencies(t *testing.T) {
+	setupCurrencies(t)
+	previous := config.AppConfig
+	config.AppConfig = &config.Config{
+		Payment:     config.PaymentConfig{MinWithdrawal: 1, MaxWithdrawal: 100000, DailyWithdrawal: 100000},
+		Responsible: config.ResponsibleConfig{SessionBreak: 30},
+	}
+	t.Cleanup(func() { config.AppConfig = previous })
+
+	newDB := func(t *testing.T) (*gorm.DB, *fakeDB) {
+		db, fake := newFakeDB(t)
+		// CNY充值被拒付后余额为负，USD钱包余额充足
+		fake.rows["wallets"] = []fakeRow{{"id": int64(7), "user_id": int64(1), "currency": "CNY", "balance": "-20"}}
+		fake.rows["users"] = []fakeRow{{"id": int64(1)}}
+		fake.rows["player_sessions"] = []fakeRow{{"user_id": int64(1), "started_at": time.Now(), "last_active_at": time.Now()}}
+		return db, fake
+	}
+
+	t.Run("其他币种下注", func(t *testing.T) {
+		db, fake := newDB(t)
+		audit := NewAuditService(db)
+		game := NewGameService(db, NewWalletService(db, audit), NewResponsibleService(db, audit), nil)
+		_, err := game.PlaceBet(1, "round_1", 0, "USD", decimal.NewFromInt(10), decimal.Zero, SystemAuditMeta())
+		if !errors.Is(err, ErrWalletInDebt) {
+			t.Fatalf("err = %v, want %v", err, ErrWalletInDebt)
+		}
+		if len(fake.executed("INSERT", "bets")) != 0 || len(fake.executed("UPDATE", "wallets")) != 0 {
+			t.Fatalf("欠款期间不应创建下注或扣款")
+		}
+	})
+
+	t.Run("其他币种提现", func(t *testing.T) {
+		db, fake := newDB(t)
+		audit := NewAuditService(db)
+		wallet := NewWalletService(db, audit)
+		svc := NewPaymentService(db, wallet, audit, NewResponsibleService(db, audit), payment.NewFakeProvider(config.AppConfig.Payment))
+		_, err := svc.RequestWithdrawal(1, "USD", decimal.NewFromInt(10), SystemAuditMeta())
+		if !errors.Is(err, ErrWalletInDebt) {
+			t.Fatalf("err = %v, want %v", err, ErrWalletInDebt)
+		}
+		if len(fake.executed("INSERT", "payment_requests")) != 0 || len(fake.executed("UPDATE", "wallets")) != 0 {
+			t.Fatalf("欠款期间不应创建提现或扣款")
+		}
+	})
+}
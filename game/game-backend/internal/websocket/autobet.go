@@ -0,0 +1,114 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"game-backend/internal/model"
+	"game-backend/internal/service"
+	"game-backend/pkg/logger"
+	"game-backend/pkg/money"
+	"game-backend/proto"
+)
+
+// runAutoBets 为所有进行中的自动下注会话结算上一局并下注本局，在每局崩盘后的下注阶段执行
+// 单局敞口或下注位已满时跳过本局，其余下注失败停止会话
+func (h *Hub) runAutoBets() {
+	h.autoBetMutex.Lock()
+	defer h.autoBetMutex.Unlock()
+
+	ids, err := h.autoBets.Running()
+	if err != nil {
+		h.log.WithError(err).Error("获取自动下注会话失败")
+		return
+	}
+
+	for _, id := range ids {
+		h.runAutoBet(id)
+	}
+}
+
+// runAutoBet 执行单个自动下注会话的本局下注
+func (h *Hub) runAutoBet(sessionID uint64) {
+	log := h.log.WithField("session_id", sessionID)
+
+	session, place, err := h.autoBets.Advance(sessionID)
+	if err != nil {
+		log.WithError(err).Error("结算自动下注失败")
+		return
+	}
+	if !place {
+		if session.Status != model.AutoBetStatusRunning {
+			h.SendAutoBetStatus(session)
+		}
+		return
+	}
+
+	log = log.WithField(logger.FieldUserID, session.UserID)
+	ctx := logger.NewContext(context.Background(), log)
+
+	bet, err := h.placeAutoBet(ctx, session)
+	if err != nil {
+		if errors.Is(err, ErrBettingClosed) || errors.Is(err, ErrRoundExposureExceeded) || errors.Is(err, ErrBetSlotsFull) {
+			log.WithError(err).Info("自动下注跳过本局")
+			return
+		}
+
+		log.WithError(err).Warn("自动下注失败")
+		if session, err = h.autoBets.Fail(sessionID, err.Error()); err != nil {
+			log.WithError(err).Error("停止自动下注失败")
+			return
+		}
+		h.SendAutoBetStatus(session)
+		return
+	}
+
+	if session, err = h.autoBets.RecordBet(sessionID, bet.BetID); err != nil {
+		log.WithError(err).WithField(logger.FieldBetID, bet.BetID).Error("记录自动下注失败")
+		return
+	}
+
+	h.SendAutoBetStatus(session)
+}
+
+// placeAutoBet 按会话当前金额下注，与玩家手动下注经过相同的校验、风控与限额
+func (h *Hub) placeAutoBet(ctx context.Context, session *model.AutoBetSession) (*model.Bet, error) {
+	if err := h.gameService.WithContext(ctx).ValidateBet(session.Currency, session.CurrentAmount, session.AutoCashout); err != nil {
+		return nil, err
+	}
+
+	return h.PlaceBet(ctx, session.UserID, AutoSlot, session.Currency, session.CurrentAmount, session.AutoCashout,
+		service.SystemAuditMeta())
+}
+
+// SendAutoBetStatus 向会话所属玩家的所有连接推送自动下注状态
+func (h *Hub) SendAutoBetStatus(session *model.AutoBetSession) {
+	cur, _ := money.Lookup(session.Currency)
+	status := &proto.AutoBetStatus{
+		SessionId:     int64(session.ID),
+		Status:        session.Status,
+		Currency:      session.Currency,
+		BaseAmount:    cur.Format(session.BaseAmount),
+		CurrentAmount: cur.Format(session.CurrentAmount),
+		AutoCashout:   session.AutoCashout.StringFixed(money.MultiplierScale),
+		Rounds:        int32(session.Rounds),
+		RoundsPlayed:  int32(session.RoundsPlayed),
+		Profit:        cur.Format(session.Profit),
+		StopReason:    session.StopReason,
+		Message:       session.StopMessage,
+		Timestamp:     time.Now().Unix(),
+	}
+
+	message, err := h.encodeMessage(AutoBetStatus, status)
+	if err != nil {
+		h.log.WithError(err).WithFields(logrus.Fields{
+			logger.FieldUserID: session.UserID,
+			"session_id":       session.ID,
+		}).Error("编码自动下注状态失败")
+		return
+	}
+
+	h.sendToUser(session.UserID, message)
+}
@@ -0,0 +1,365 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"game-backend/config"
+	"game-backend/internal/model"
+	"game-backend/internal/service"
+	"game-backend/pkg/logger"
+	"game-backend/pkg/metrics"
+	"game-backend/proto"
+)
+
+// chatRoom 聊天室成员与最近消息
+type chatRoom struct {
+	members map[*Client]bool
+	history []*proto.ChatMessage // 按时间正序，最多history_size条
+	loaded  bool                 // 已从数据库加载重启前的消息
+}
+
+// chatRooms 聊天室与玩家发言限流
+// 持有mutex向成员发送消息，加入时下发的最近消息与此后的广播顺序一致；锁顺序：chatRooms.mutex -> Hub.mutex
+type chatRooms struct {
+	mutex    sync.Mutex
+	rooms    map[string]*chatRoom
+	limiters map[uint]*rate.Limiter // 按玩家限流，同一玩家的多个连接共用
+}
+
+// newChatRooms 创建聊天室
+func newChatRooms() *chatRooms {
+	return &chatRooms{
+		rooms:    make(map[string]*chatRoom),
+		limiters: make(map[uint]*rate.Limiter),
+	}
+}
+
+// roomLocked 获取聊天室，不存在时创建，调用方需持有r.mutex
+func (r *chatRooms) roomLocked(name string) *chatRoom {
+	room, ok := r.rooms[name]
+	if !ok {
+		room = &chatRoom{members: make(map[*Client]bool)}
+		r.rooms[name] = room
+	}
+	return room
+}
+
+// allow 玩家发言限流
+func (r *chatRooms) allow(userID uint) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	limiter, ok := r.limiters[userID]
+	if !ok {
+		cfg := config.AppConfig.Chat
+		limiter = rate.NewLimiter(rate.Limit(cfg.RateLimit), cfg.RateBurst)
+		r.limiters[userID] = limiter
+	}
+	return limiter.Allow()
+}
+
+// chatMessageView 聊天消息的下发格式
+func chatMessageView(message *model.ChatMessage) *proto.ChatMessage {
+	return &proto.ChatMessage{
+		Id:        int64(message.ID),
+		Room:      message.Room,
+		UserId:    int64(message.UserID),
+		Username:  message.Username,
+		Content:   message.Content,
+		Timestamp: message.CreatedAt.Unix(),
+	}
+}
+
+// loadChatHistory 首次加入聊天室时从数据库加载最近消息，加载期间广播的消息保留在其后
+func (h *Hub) loadChatHistory(ctx context.Context, name string) error {
+	h.chatRooms.mutex.Lock()
+	loaded := h.chatRooms.roomLocked(name).loaded
+	h.chatRooms.mutex.Unlock()
+	if loaded {
+		return nil
+	}
+
+	size := config.AppConfig.Chat.HistorySize
+	messages, err := h.chat.WithContext(ctx).History(name, size)
+	if err != nil {
+		return err
+	}
+
+	h.chatRooms.mutex.Lock()
+	defer h.chatRooms.mutex.Unlock()
+
+	room := h.chatRooms.roomLocked(name)
+	if room.loaded {
+		return nil
+	}
+
+	history := make([]*proto.ChatMessage, 0, len(messages)+len(room.history))
+	var lastID int64
+	for i := range messages {
+		view := chatMessageView(&messages[i])
+		history = append(history, view)
+		lastID = view.Id
+	}
+	for _, view := range room.history {
+		if view.Id > lastID {
+			history = append(history, view)
+		}
+	}
+	if len(history) > size {
+		history = history[len(history)-size:]
+	}
+
+	room.history = history
+	room.loaded = true
+	return nil
+}
+
+// joinChat 加入聊天室并下发最近消息
+func (h *Hub) joinChat(client *Client, name string) {
+	h.chatRooms.mutex.Lock()
+	defer h.chatRooms.mutex.Unlock()
+
+	h.mutex.RLock()
+	_, registered := h.clients[client]
+	h.mutex.RUnlock()
+	if !registered {
+		return
+	}
+
+	room := h.chatRooms.roomLocked(name)
+	room.members[client] = true
+
+	message, err := h.encodeMessage(ChatHistory, &proto.ChatHistory{
+		Room:     name,
+		Messages: room.history,
+	})
+	if err != nil {
+		client.log.WithError(err).Error("编码聊天记录失败")
+		return
+	}
+	h.sendToClient(client, message)
+}
+
+// leaveChat 离开聊天室
+func (h *Hub) leaveChat(client *Client, name string) {
+	h.chatRooms.mutex.Lock()
+	defer h.chatRooms.mutex.Unlock()
+
+	if room, ok := h.chatRooms.rooms[name]; ok {
+		delete(room.members, client)
+	}
+}
+
+// leaveAllChats 连接断开时离开所有聊天室，限流器已恢复满额时一并清理
+func (h *Hub) leaveAllChats(client *Client) {
+	h.chatRooms.mutex.Lock()
+	defer h.chatRooms.mutex.Unlock()
+
+	for _, room := range h.chatRooms.rooms {
+		delete(room.members, client)
+	}
+	if limiter, ok := h.chatRooms.limiters[client.userID]; ok && limiter.Tokens() >= float64(limiter.Burst()) {
+		delete(h.chatRooms.limiters, client.userID)
+	}
+}
+
+// broadcastChat 将消息加入最近消息并发送给聊天室成员
+func (h *Hub) broadcastChat(message *model.ChatMessage) {
+	view := chatMessageView(message)
+	encoded, err := h.encodeMessage(ChatMessage, view)
+	if err != nil {
+		h.log.WithError(err).Error("编码聊天消息失败")
+		return
+	}
+
+	h.chatRooms.mutex.Lock()
+	defer h.chatRooms.mutex.Unlock()
+
+	room := h.chatRooms.roomLocked(message.Room)
+	room.history = append(room.history, view)
+	if size := config.AppConfig.Chat.HistorySize; len(room.history) > size {
+		room.history = room.history[len(room.history)-size:]
+	}
+
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	for client := range room.members {
+		if _, ok := h.clients[client]; ok {
+			h.trySend(client, encoded)
+		}
+	}
+}
+
+// ApplyChatSanction 通知被处罚的玩家，封禁时将其所有连接移出相应聊天室
+func (h *Hub) ApplyChatSanction(sanction *model.ChatSanction) {
+	notice := "你已被禁言"
+	if sanction.Type == model.ChatSanctionBan {
+		notice = "你已被禁止进入聊天室"
+		h.kickFromChat(sanction.UserID, sanction.Room)
+	}
+	if sanction.Room != "" {
+		notice += "（" + sanction.Room + "）"
+	}
+	if sanction.Until != nil {
+		notice += "，至" + sanction.Until.Format("2006-01-02 15:04")
+	}
+	if sanction.Reason != "" {
+		notice += "，原因：" + sanction.Reason
+	}
+
+	h.notifyUser(sanction.UserID, "warning", notice)
+}
+
+// LiftChatSanction 通知玩家处罚已解除
+func (h *Hub) LiftChatSanction(sanction *model.ChatSanction) {
+	h.notifyUser(sanction.UserID, "info", "聊天禁言/封禁已解除")
+}
+
+// kickFromChat 将玩家的所有连接移出聊天室，room为空时移出所有聊天室，并向其发送离开消息
+func (h *Hub) kickFromChat(userID uint, name string) {
+	h.chatRooms.mutex.Lock()
+	defer h.chatRooms.mutex.Unlock()
+
+	for roomName, room := range h.chatRooms.rooms {
+		if name != "" && roomName != name {
+			continue
+		}
+
+		var leave []byte
+		for client := range room.members {
+			if client.userID != userID {
+				continue
+			}
+			delete(room.members, client)
+
+			if leave == nil {
+				var err error
+				if leave, err = h.encodeMessage(ChatLeave, &proto.ChatLeave{Room: roomName}); err != nil {
+					h.log.WithError(err).Error("编码离开聊天室消息失败")
+					return
+				}
+			}
+			h.sendToClient(client, leave)
+		}
+	}
+}
+
+// notifyUser 向玩家的所有连接发送系统通知
+func (h *Hub) notifyUser(userID uint, notificationType, message string) {
+	notification, err := h.encodeMessage(SystemNotification, &proto.SystemNotification{
+		Type:      notificationType,
+		Message:   message,
+		Timestamp: time.Now().Unix(),
+	})
+	if err != nil {
+		h.log.WithError(err).Error("编码系统通知失败")
+		return
+	}
+
+	h.sendToUser(userID, notification)
+}
+
+// handleChatJoin 处理加入聊天室
+func (c *Client) handleChatJoin(ctx context.Context, payload []byte, hub *Hub) {
+	log := logger.FromContext(ctx)
+
+	if c.userID == 0 {
+		c.sendErrorMessage("请先完成握手", hub)
+		return
+	}
+
+	var join proto.ChatJoin
+	if err := json.Unmarshal(payload, &join); err != nil {
+		log.WithError(err).Warn("解析加入聊天室请求失败")
+		c.sendErrorMessage("加入聊天室请求格式错误", hub)
+		return
+	}
+
+	if err := hub.chat.WithContext(ctx).CheckJoin(c.userID, join.Room); err != nil {
+		if errors.Is(err, service.ErrChatRoomNotFound) || errors.Is(err, service.ErrChatBanned) {
+			c.sendErrorMessage(err.Error(), hub)
+			return
+		}
+		log.WithError(err).Error("检查聊天处罚失败")
+		c.sendErrorMessage("加入聊天室失败", hub)
+		return
+	}
+
+	if err := hub.loadChatHistory(ctx, join.Room); err != nil {
+		log.WithError(err).WithField("room", join.Room).Error("加载聊天记录失败")
+		c.sendErrorMessage("加入聊天室失败", hub)
+		return
+	}
+
+	hub.joinChat(c, join.Room)
+}
+
+// handleChatLeave 处理离开聊天室
+func (c *Client) handleChatLeave(ctx context.Context, payload []byte, hub *Hub) {
+	var leave proto.ChatLeave
+	if err := json.Unmarshal(payload, &leave); err != nil {
+		logger.FromContext(ctx).WithError(err).Warn("解析离开聊天室请求失败")
+		c.sendErrorMessage("离开聊天室请求格式错误", hub)
+		return
+	}
+
+	hub.leaveChat(c, leave.Room)
+}
+
+// handleChatMessage 处理发言，限流、处罚检查与过滤后持久化并广播给聊天室成员
+func (c *Client) handleChatMessage(ctx context.Context, payload []byte, hub *Hub) {
+	log := logger.FromContext(ctx)
+
+	if c.userID == 0 {
+		c.sendErrorMessage("请先完成握手", hub)
+		return
+	}
+
+	var chatReq proto.ChatMessage
+	if err := json.Unmarshal(payload, &chatReq); err != nil {
+		log.WithError(err).Warn("解析聊天消息失败")
+		c.sendErrorMessage("聊天消息格式错误", hub)
+		return
+	}
+
+	if !hub.inChat(c, chatReq.Room) {
+		c.sendErrorMessage("请先加入聊天室", hub)
+		return
+	}
+
+	if !hub.chatRooms.allow(c.userID) {
+		metrics.RateLimitRejections.WithLabelValues("chat").Inc()
+		c.sendErrorMessage("发言过于频繁，请稍后再试", hub)
+		return
+	}
+
+	message, err := hub.chat.WithContext(ctx).Post(c.userID, c.username, chatReq.Room, chatReq.Content)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrChatRoomNotFound), errors.Is(err, service.ErrChatMessageEmpty),
+			errors.Is(err, service.ErrChatMessageTooLong), errors.Is(err, service.ErrChatMuted),
+			errors.Is(err, service.ErrChatBanned):
+			c.sendErrorMessage(err.Error(), hub)
+		default:
+			log.WithError(err).WithField("room", chatReq.Room).Error("发送聊天消息失败")
+			c.sendErrorMessage("发送聊天消息失败", hub)
+		}
+		return
+	}
+
+	hub.broadcastChat(message)
+}
+
+// inChat 客户端是否已加入聊天室
+func (h *Hub) inChat(client *Client, name string) bool {
+	h.chatRooms.mutex.Lock()
+	defer h.chatRooms.mutex.Unlock()
+
+	room, ok := h.chatRooms.rooms[name]
+	return ok && room.members[client]
+}
@@ -1,12 +1,27 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
-	"log"
+	"errors"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+	"game-backend/config"
+	"game-backend/internal/middleware"
+	"game-backend/internal/model"
+	"game-backend/internal/service"
+	"game-backend/pkg/logger"
+	"game-backend/pkg/tracing"
+	"game-backend/proto"
 )
 
 // Client WebSocket客户端处理
@@ -17,10 +32,39 @@ type Client struct {
 	// 用户信息
 	userID   uint
 	username string
+	role     string
+
+	// 重连会话ID，握手成功后分配
+	sessionID string
+
+	// 建立连接时的请求信息，用于审计
+	requestID string
+	clientIP  string
 
 	// 连接信息
 	connectedAt time.Time
 	lastActive  time.Time
+
+	// 往返时间，用于止盈延迟补偿
+	rtt rttTracker
+
+	// 最近一次崩盘消息写出到连接的时间(UnixNano)，此后收到的止盈不再受理宽限期
+	crashWritten atomic.Int64
+
+	// 滴答频率，发送队列积压时降低
+	ticks tickThrottle
+
+	// 日志条目（携带连接与用户字段）
+	log *logrus.Entry
+
+	// 建立连接的HTTP请求所在的span，消息span通过link关联
+	connSpan trace.SpanContext
+}
+
+// traceEnvelope 客户端消息中可选的W3C trace上下文字段
+type traceEnvelope struct {
+	Traceparent string `json:"traceparent"`
+	Tracestate  string `json:"tracestate"`
 }
 
 // readPump 读取客户端消息
@@ -30,8 +74,8 @@ func (c *Client) readPump(hub *Hub) {
 		c.conn.Close()
 	}()
 
-	// 设置读取超时
-	c.conn.SetReadLimit(512)
+	// 设置读取超时；读取上限按聊天消息最大长度计算（每个字符最多6字节的JSON转义）
+	c.conn.SetReadLimit(int64(512 + config.AppConfig.Chat.MaxLength*6))
 	c.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
 	c.conn.SetPongHandler(func(string) error {
 		c.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
@@ -42,7 +86,7 @@ func (c *Client) readPump(hub *Hub) {
 		_, message, err := c.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("WebSocket错误: %v", err)
+				c.log.WithError(err).Warn("WebSocket连接异常关闭")
 			}
 			break
 		}
@@ -55,11 +99,18 @@ func (c *Client) readPump(hub *Hub) {
 // writePump 向客户端发送消息
 func (c *Client) writePump(hub *Hub) {
 	ticker := time.NewTicker(54 * time.Second)
+	syncTicker := time.NewTicker(time.Duration(config.AppConfig.WebSocket.TimeSyncInterval) * time.Second)
 	defer func() {
 		ticker.Stop()
+		syncTicker.Stop()
 		c.conn.Close()
 	}()
 
+	// 连接建立后立即测量一次RTT
+	if err := c.writeTimeSyncPing(hub); err != nil {
+		return
+	}
+
 	for {
 		select {
 		case message, ok := <-c.send:
@@ -69,134 +120,246 @@ func (c *Client) writePump(hub *Hub) {
 				return
 			}
 
+			// 未协商压缩时无效；小消息压缩后反而更大，批量发送时按整帧压缩
+			c.conn.EnableWriteCompression(len(message) >= config.AppConfig.WebSocket.CompressionThreshold || len(c.send) > 0)
+
 			w, err := c.conn.NextWriter(websocket.TextMessage)
 			if err != nil {
 				return
 			}
 			w.Write(message)
+			crashed := isGameEnd(message)
 
 			// 批量发送队列中的消息
 			n := len(c.send)
 			for i := 0; i < n; i++ {
+				message = <-c.send
 				w.Write([]byte{'\n'})
-				w.Write(<-c.send)
+				w.Write(message)
+				crashed = crashed || isGameEnd(message)
 			}
 
 			if err := w.Close(); err != nil {
 				return
 			}
+			if crashed {
+				c.crashWritten.Store(time.Now().UnixNano())
+			}
 
 		case <-ticker.C:
 			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
+
+		case <-syncTicker.C:
+			if err := c.writeTimeSyncPing(hub); err != nil {
+				return
+			}
 		}
 	}
 }
 
+// isGameEnd 消息帧是否为游戏结束（崩盘）消息
+func isGameEnd(message []byte) bool {
+	return len(message) > 4 && MessageType(message[4]) == GameEnd
+}
+
+// crashSeen 最近一次崩盘消息写出到连接的时间，尚未写出过时为零值
+func (c *Client) crashSeen() time.Time {
+	if at := c.crashWritten.Load(); at != 0 {
+		return time.Unix(0, at)
+	}
+	return time.Time{}
+}
+
 // handleMessage 处理客户端消息
 func (c *Client) handleMessage(data []byte, hub *Hub) {
 	// 解码消息
 	msgType, payload, err := hub.decodeMessage(data)
 	if err != nil {
-		log.Printf("解码消息失败: %v", err)
+		c.log.WithError(err).Warn("解码消息失败")
 		c.sendErrorMessage("消息格式错误", hub)
 		return
 	}
 
+	// 为每条消息创建span，父上下文取自消息中的traceparent字段
+	ctx, span := tracing.Tracer().Start(extractTraceContext(payload), "ws."+msgType.String(),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithLinks(trace.Link{SpanContext: c.connSpan}),
+		trace.WithAttributes(
+			attribute.Int64("user.id", int64(c.userID)),
+			attribute.Int("ws.message_size", len(data)),
+		),
+	)
+	defer span.End()
+	ctx = logger.NewContext(ctx, c.log.WithField(logger.FieldTraceID, span.SpanContext().TraceID().String()))
+
 	switch msgType {
 	case HandshakeRequest:
-		c.handleHandshake(payload, hub)
+		c.handleHandshake(ctx, payload, hub)
 	case PlayerBet:
-		c.handlePlayerBet(payload, hub)
+		c.handlePlayerBet(ctx, payload, hub)
 	case PlayerCashout:
-		c.handlePlayerCashout(payload, hub)
+		c.handlePlayerCashout(ctx, payload, hub)
+	case PlayerBetCancel:
+		c.handlePlayerBetCancel(ctx, payload, hub)
+	case RoundSnapshot:
+		// 客户端发现广播序号不连续时重新订阅快照
+		hub.sendSnapshot(c)
+	case AutoBetStart:
+		c.handleAutoBetStart(ctx, payload, hub)
+	case AutoBetStop:
+		c.handleAutoBetStop(ctx, hub)
+	case TimeSyncPing:
+		c.handleTimeSyncPing(ctx, payload, hub)
+	case TimeSyncPong:
+		c.handleTimeSyncPong(ctx, payload)
+	case ChatJoin:
+		c.handleChatJoin(ctx, payload, hub)
+	case ChatLeave:
+		c.handleChatLeave(ctx, payload, hub)
+	case ChatMessage:
+		c.handleChatMessage(ctx, payload, hub)
+	case OnlineUsers:
+		c.handleOnlineUsers(hub)
 	default:
-		log.Printf("未知消息类型: %d", msgType)
+		c.log.WithField("msg_type", msgType).Warn("未知消息类型")
 		c.sendErrorMessage("未知消息类型", hub)
 	}
 }
 
 // handleHandshake 处理握手请求
-func (c *Client) handleHandshake(payload []byte, hub *Hub) {
+func (c *Client) handleHandshake(ctx context.Context, payload []byte, hub *Hub) {
+	log := logger.FromContext(ctx)
+
 	var handshakeReq struct {
-		Token   string `json:"token"`
-		Version string `json:"version"`
+		Token     string `json:"token"`
+		Version   string `json:"version"`
+		SessionID string `json:"session_id"`
+		LastSeq   int64  `json:"last_seq"`
 	}
 
 	if err := json.Unmarshal(payload, &handshakeReq); err != nil {
-		log.Printf("解析握手请求失败: %v", err)
+		log.WithError(err).Warn("解析握手请求失败")
 		c.sendHandshakeResponse("error", 0, "握手请求格式错误", hub)
 		return
 	}
 
-	// 这里应该验证JWT Token并获取用户信息
-	// 为了演示，我们假设Token验证成功
 	if handshakeReq.Token == "" {
 		c.sendHandshakeResponse("error", 0, "Token不能为空", hub)
 		return
 	}
 
-	// 模拟从Token中解析用户信息
-	c.userID = 12345 // 这里应该从JWT中解析
-	c.username = "player1" // 这里应该从JWT中解析
+	claims, err := middleware.ParseToken(handshakeReq.Token)
+	if err != nil {
+		log.WithError(err).Warn("握手令牌无效")
+		c.sendHandshakeResponse("error", 0, "Token无效", hub)
+		return
+	}
 
-	// 发送握手响应
-	c.sendHandshakeResponse("success", c.userID, "", hub)
-	log.Printf("用户握手成功: %s (ID: %d)", c.username, c.userID)
+	// 登出、在其他设备登录或开启冷静期/自我排除后令牌不再接受
+	active, err := hub.authSessions(ctx, handshakeReq.Token)
+	if err != nil {
+		log.WithError(err).Error("校验登录会话失败")
+		c.sendHandshakeResponse("error", 0, "校验登录会话失败", hub)
+		return
+	}
+	if !active {
+		c.sendHandshakeResponse("error", 0, "登录会话已失效，请重新登录", hub)
+		return
+	}
+
+	c.userID = claims.UserID
+	c.username = claims.Username
+	c.role = claims.Role
+	userFields := logrus.Fields{
+		logger.FieldUserID: c.userID,
+		"username":         c.username,
+	}
+	c.log = c.log.WithFields(userFields)
+	log = log.WithFields(userFields)
+	hub.bindPresence(c)
+
+	// 发送握手响应，随后补发错过的广播或发送完整快照
+	hub.completeHandshake(c, handshakeReq.SessionID, handshakeReq.LastSeq)
+	log.WithField("version", handshakeReq.Version).Info("用户握手成功")
+
+	// 重连后恢复进行中的自动下注状态
+	session, err := hub.autoBets.WithContext(ctx).Current(c.userID)
+	if err == nil && session.Status == model.AutoBetStatusRunning {
+		hub.SendAutoBetStatus(session)
+	}
 }
 
 // handlePlayerBet 处理玩家下注
-func (c *Client) handlePlayerBet(payload []byte, hub *Hub) {
+func (c *Client) handlePlayerBet(ctx context.Context, payload []byte, hub *Hub) {
+	log := logger.FromContext(ctx)
+
+	if c.userID == 0 {
+		c.sendErrorMessage("请先完成握手", hub)
+		return
+	}
+
 	var betReq struct {
-		Amount      float64 `json:"amount"`
-		AutoCashout float64 `json:"auto_cashout"`
+		Slot        *int            `json:"slot"` // 为空时自动分配
+		Currency    string          `json:"currency"`
+		Amount      decimal.Decimal `json:"amount"`
+		AutoCashout decimal.Decimal `json:"auto_cashout"`
 	}
 
 	if err := json.Unmarshal(payload, &betReq); err != nil {
-		log.Printf("解析下注请求失败: %v", err)
+		log.WithError(err).Warn("解析下注请求失败")
 		c.sendErrorMessage("下注请求格式错误", hub)
 		return
 	}
 
-	// 验证下注金额
-	if betReq.Amount <= 0 {
-		c.sendErrorMessage("下注金额必须大于0", hub)
+	gameService := hub.gameService.WithContext(ctx)
+	if err := gameService.ValidateBet(betReq.Currency, betReq.Amount, betReq.AutoCashout); err != nil {
+		c.sendErrorMessage(err.Error(), hub)
 		return
 	}
 
-	// 这里应该调用业务逻辑处理下注
-	betID := fmt.Sprintf("bet_%d_%d", c.userID, time.Now().Unix())
-	
-	playerBet := &proto.PlayerBet{
-		BetId:       betID,
-		UserId:      int64(c.userID),
-		Amount:      betReq.Amount,
-		AutoCashout: betReq.AutoCashout,
-		Timestamp:   time.Now().Unix(),
+	slot := AutoSlot
+	if betReq.Slot != nil {
+		slot = *betReq.Slot
 	}
 
-	// 广播下注消息
-	message, err := hub.encodeMessage(PlayerBet, playerBet)
+	_, err := hub.PlaceBet(ctx, c.userID, slot, betReq.Currency, betReq.Amount, betReq.AutoCashout, c.auditMeta())
 	if err != nil {
-		log.Printf("编码下注消息失败: %v", err)
+		if errors.Is(err, service.ErrInsufficientBalance) {
+			c.sendErrorMessage("余额不足", hub)
+			return
+		}
+		if errors.Is(err, ErrBettingClosed) || errors.Is(err, ErrRoundExposureExceeded) || errors.Is(err, ErrBetSlotsFull) || errors.Is(err, ErrInvalidBetSlot) ||
+			errors.Is(err, ErrBetSlotTaken) || errors.Is(err, service.ErrWalletInDebt) || errors.Is(err, service.ErrWagerLimitExceeded) || errors.Is(err, service.ErrLossLimitExceeded) ||
+			errors.Is(err, service.ErrSelfExcluded) || errors.Is(err, service.ErrCoolOffActive) ||
+			errors.Is(err, service.ErrSessionLimitReached) {
+			c.sendErrorMessage(err.Error(), hub)
+			return
+		}
+		log.WithError(err).Error("下注失败")
 		c.sendErrorMessage("下注失败", hub)
 		return
 	}
-
-	hub.broadcastMessage(message)
-	log.Printf("用户 %s 下注: %.2f, 自动止盈: %.2f", c.username, betReq.Amount, betReq.AutoCashout)
 }
 
 // handlePlayerCashout 处理玩家止盈
-func (c *Client) handlePlayerCashout(payload []byte, hub *Hub) {
+func (c *Client) handlePlayerCashout(ctx context.Context, payload []byte, hub *Hub) {
+	log := logger.FromContext(ctx)
+
+	if c.userID == 0 {
+		c.sendErrorMessage("请先完成握手", hub)
+		return
+	}
+
 	var cashoutReq struct {
-		BetID string `json:"bet_id"`
+		BetID    string           `json:"bet_id"`
+		Fraction *decimal.Decimal `json:"fraction"` // 剩余本金的止盈比例，为空时全部止盈
 	}
 
 	if err := json.Unmarshal(payload, &cashoutReq); err != nil {
-		log.Printf("解析止盈请求失败: %v", err)
+		log.WithError(err).Warn("解析止盈请求失败")
 		c.sendErrorMessage("止盈请求格式错误", hub)
 		return
 	}
@@ -206,29 +369,151 @@ func (c *Client) handlePlayerCashout(payload []byte, hub *Hub) {
 		return
 	}
 
-	// 这里应该调用业务逻辑处理止盈
-	// 模拟止盈成功
-	multiplier := 2.5 // 这里应该从游戏状态获取当前倍数
-	payout := 25.0    // 这里应该计算实际赔付
+	fraction := decimal.NewFromInt(1)
+	if cashoutReq.Fraction != nil {
+		fraction = *cashoutReq.Fraction
+	}
+	if err := hub.gameService.ValidateCashoutFraction(fraction); err != nil {
+		c.sendErrorMessage(err.Error(), hub)
+		return
+	}
 
-	playerCashout := &proto.PlayerCashout{
-		BetId:     cashoutReq.BetID,
-		UserId:    int64(c.userID),
-		Multiplier: multiplier,
-		Payout:    payout,
-		Timestamp: time.Now().Unix(),
+	_, _, err := hub.Cashout(ctx, c.userID, cashoutReq.BetID, fraction, c.oneWayLatency(), c.crashSeen(), c.auditMeta())
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.sendErrorMessage("下注记录不存在", hub)
+		case errors.Is(err, ErrGameNotRunning), errors.Is(err, service.ErrBetNotOwned), errors.Is(err, service.ErrBetNotActive),
+			errors.Is(err, service.ErrCashoutTooSmall):
+			c.sendErrorMessage(err.Error(), hub)
+		default:
+			log.WithError(err).WithField(logger.FieldBetID, cashoutReq.BetID).Error("止盈失败")
+			c.sendErrorMessage("止盈失败", hub)
+		}
+		return
+	}
+}
+
+// handlePlayerBetCancel 处理撤销下注，成功后由Hub广播撤销消息
+func (c *Client) handlePlayerBetCancel(ctx context.Context, payload []byte, hub *Hub) {
+	log := logger.FromContext(ctx)
+
+	if c.userID == 0 {
+		c.sendErrorMessage("请先完成握手", hub)
+		return
+	}
+
+	var cancelReq struct {
+		BetID string `json:"bet_id"`
+	}
+
+	if err := json.Unmarshal(payload, &cancelReq); err != nil {
+		log.WithError(err).Warn("解析撤销下注请求失败")
+		c.sendErrorMessage("撤销下注请求格式错误", hub)
+		return
+	}
+
+	if cancelReq.BetID == "" {
+		c.sendErrorMessage("下注ID不能为空", hub)
+		return
+	}
+
+	if _, err := hub.CancelBet(ctx, c.userID, cancelReq.BetID, c.auditMeta()); err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.sendErrorMessage("下注记录不存在", hub)
+		case errors.Is(err, ErrBettingClosed), errors.Is(err, service.ErrBetNotOwned), errors.Is(err, service.ErrBetNotActive):
+			c.sendErrorMessage(err.Error(), hub)
+		default:
+			log.WithError(err).WithField(logger.FieldBetID, cancelReq.BetID).Error("撤销下注失败")
+			c.sendErrorMessage("撤销下注失败", hub)
+		}
+	}
+}
+
+// handleAutoBetStart 处理开启自动下注
+func (c *Client) handleAutoBetStart(ctx context.Context, payload []byte, hub *Hub) {
+	log := logger.FromContext(ctx)
+
+	if c.userID == 0 {
+		c.sendErrorMessage("请先完成握手", hub)
+		return
+	}
+
+	var params service.AutoBetParams
+	if err := json.Unmarshal(payload, &params); err != nil {
+		log.WithError(err).Warn("解析自动下注请求失败")
+		c.sendErrorMessage("自动下注请求格式错误", hub)
+		return
+	}
+
+	autoBets := hub.autoBets.WithContext(ctx)
+	if err := autoBets.Validate(params); err != nil {
+		c.sendErrorMessage(err.Error(), hub)
+		return
 	}
 
-	// 广播止盈消息
-	message, err := hub.encodeMessage(PlayerCashout, playerCashout)
+	session, err := autoBets.Start(c.userID, params, c.auditMeta())
 	if err != nil {
-		log.Printf("编码止盈消息失败: %v", err)
-		c.sendErrorMessage("止盈失败", hub)
+		if errors.Is(err, service.ErrAutoBetRunning) {
+			c.sendErrorMessage(err.Error(), hub)
+			return
+		}
+		log.WithError(err).Error("开启自动下注失败")
+		c.sendErrorMessage("开启自动下注失败", hub)
+		return
+	}
+
+	hub.SendAutoBetStatus(session)
+}
+
+// handleAutoBetStop 处理停止自动下注
+func (c *Client) handleAutoBetStop(ctx context.Context, hub *Hub) {
+	if c.userID == 0 {
+		c.sendErrorMessage("请先完成握手", hub)
+		return
+	}
+
+	session, err := hub.autoBets.WithContext(ctx).Stop(c.userID, c.auditMeta())
+	if err != nil {
+		if errors.Is(err, service.ErrAutoBetNotRunning) {
+			c.sendErrorMessage(err.Error(), hub)
+			return
+		}
+		logger.FromContext(ctx).WithError(err).Error("停止自动下注失败")
+		c.sendErrorMessage("停止自动下注失败", hub)
 		return
 	}
 
-	hub.broadcastMessage(message)
-	log.Printf("用户 %s 止盈: 倍数 %.2f, 赔付 %.2f", c.username, multiplier, payout)
+	hub.SendAutoBetStatus(session)
+}
+
+// auditMeta 构建当前连接用户的审计上下文
+func (c *Client) auditMeta() service.AuditMeta {
+	actorType := model.AuditActorUser
+	if c.role == model.RoleAdmin || c.role == model.RoleSupport {
+		actorType = model.AuditActorAdmin
+	}
+
+	return service.AuditMeta{
+		ActorID:   c.userID,
+		ActorType: actorType,
+		RequestID: c.requestID,
+		ClientIP:  c.clientIP,
+	}
+}
+
+// extractTraceContext 从消息负载中提取trace上下文，未携带时返回空上下文
+func extractTraceContext(payload []byte) context.Context {
+	var env traceEnvelope
+	if err := json.Unmarshal(payload, &env); err != nil || env.Traceparent == "" {
+		return context.Background()
+	}
+
+	return otel.GetTextMapPropagator().Extract(context.Background(), propagation.MapCarrier{
+		"traceparent": env.Traceparent,
+		"tracestate":  env.Tracestate,
+	})
 }
 
 // sendHandshakeResponse 发送握手响应
@@ -242,15 +527,11 @@ func (c *Client) sendHandshakeResponse(status string, userID uint, message strin
 
 	msg, err := hub.encodeMessage(HandshakeResponse, response)
 	if err != nil {
-		log.Printf("编码握手响应失败: %v", err)
+		c.log.WithError(err).Error("编码握手响应失败")
 		return
 	}
 
-	select {
-	case c.send <- msg:
-	default:
-		close(c.send)
-	}
+	hub.sendToClient(c, msg)
 }
 
 // sendErrorMessage 发送错误消息
@@ -263,15 +544,11 @@ func (c *Client) sendErrorMessage(message string, hub *Hub) {
 
 	msg, err := hub.encodeMessage(SystemNotification, notification)
 	if err != nil {
-		log.Printf("编码错误消息失败: %v", err)
+		c.log.WithError(err).Error("编码错误消息失败")
 		return
 	}
 
-	select {
-	case c.send <- msg:
-	default:
-		close(c.send)
-	}
+	hub.sendToClient(c, msg)
 }
 
 // sendInfoMessage 发送信息消息
@@ -284,13 +561,9 @@ func (c *Client) sendInfoMessage(message string, hub *Hub) {
 
 	msg, err := hub.encodeMessage(SystemNotification, notification)
 	if err != nil {
-		log.Printf("编码信息消息失败: %v", err)
+		c.log.WithError(err).Error("编码信息消息失败")
 		return
 	}
 
-	select {
-	case c.send <- msg:
-	default:
-		close(c.send)
-	}
+	hub.sendToClient(c, msg)
 }
@@ -0,0 +1,206 @@
+package websocket
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"game-backend/config"
+	"game-backend/pkg/money"
+	"game-backend/proto"
+)
+
+// eventLog 广播序号与最近广播事件的环形缓冲区，用于断线重连补发
+// 锁顺序：gameState.mutex -> roster.mutex -> eventLog.mutex -> Hub.mutex
+type eventLog struct {
+	mutex  sync.Mutex
+	seq    int64
+	events [][]byte // 下标为 seq % len(events)
+}
+
+// newEventLog 创建广播事件缓冲区
+func newEventLog(size int) *eventLog {
+	return &eventLog{events: make([][]byte, size)}
+}
+
+// rangeLocked 返回序号大于after的已缓冲事件，超出缓冲区或序号无效时返回false，调用方需持有l.mutex
+func (l *eventLog) rangeLocked(after int64) ([][]byte, bool) {
+	oldest := l.seq - int64(len(l.events)) + 1
+	if oldest < 1 {
+		oldest = 1
+	}
+	if after < oldest-1 || after > l.seq {
+		return nil, false
+	}
+
+	missed := make([][]byte, 0, l.seq-after)
+	for seq := after + 1; seq <= l.seq; seq++ {
+		missed = append(missed, l.events[seq%int64(len(l.events))])
+	}
+	return missed, true
+}
+
+// resumeSession 可恢复的连接会话，断线后保留resume_window秒
+type resumeSession struct {
+	userID  uint
+	client  *Client   // 当前连接，断开后为nil
+	expires time.Time // 断开后的过期时间
+}
+
+// publish 分配下一个广播序号写入seq后编码并广播，同时写入缓冲区
+// 持有eventLog.mutex广播，客户端收到的广播顺序与序号一致
+func (h *Hub) publish(msgType MessageType, data interface{}, seq *int64) error {
+	h.events.mutex.Lock()
+	defer h.events.mutex.Unlock()
+
+	next := h.events.seq + 1
+	*seq = next
+	message, err := h.encodeMessage(msgType, data)
+	if err != nil {
+		return err
+	}
+
+	h.events.seq = next
+	h.events.events[next%int64(len(h.events.events))] = message
+	h.broadcastMessage(message)
+	return nil
+}
+
+// sendSnapshot 向客户端发送当前游戏状态与本局下注列表快照，seq为当前广播序号
+func (h *Hub) sendSnapshot(client *Client) {
+	h.gameState.mutex.RLock()
+	defer h.gameState.mutex.RUnlock()
+	h.roster.mutex.Lock()
+	defer h.roster.mutex.Unlock()
+	h.events.mutex.Lock()
+	defer h.events.mutex.Unlock()
+
+	h.sendSnapshotLocked(client)
+}
+
+// sendSnapshotLocked 同sendSnapshot，调用方需按顺序持有gameState.mutex(读)、roster.mutex与eventLog.mutex
+func (h *Hub) sendSnapshotLocked(client *Client) {
+	statusUpdate := &proto.GameStatusUpdate{
+		GameId:            h.gameState.GameID,
+		State:             proto.GameState(h.gameState.Status),
+		CurrentMultiplier: h.gameState.CurrentMultiplier.StringFixed(money.MultiplierScale),
+		PlayersCount:      h.gameState.PlayersCount,
+		NextRoundIn:       h.gameState.NextRoundIn,
+		ServerTime:        h.gameState.LastUpdate,
+		ServerTimeMs:      h.gameState.LastUpdateMs,
+		OnlineCount:       h.gameState.OnlineCount,
+		SpectatorsCount:   h.gameState.SpectatorsCount,
+		Seq:               h.events.seq,
+	}
+
+	status, err := h.encodeMessage(GameStatusUpdate, statusUpdate)
+	if err != nil {
+		client.log.WithError(err).Error("编码游戏状态消息失败")
+		return
+	}
+	snapshot, err := h.encodeMessage(RoundSnapshot, h.roster.snapshotLocked(h.events.seq))
+	if err != nil {
+		client.log.WithError(err).Error("编码下注列表快照失败")
+		return
+	}
+
+	h.sendToClient(client, status)
+	h.sendToClient(client, snapshot)
+}
+
+// completeHandshake 发送握手成功响应；客户端请求重连、会话有效且错过的广播仍在缓冲区内时依次补发，否则随后发送完整快照
+// 响应、补发与快照在事件锁内发送，此后的广播序号均大于响应中的seq
+func (h *Hub) completeHandshake(client *Client, sessionID string, lastSeq int64) {
+	requested := sessionID != ""
+	sessionID, valid := h.claimSession(client, sessionID)
+
+	h.gameState.mutex.RLock()
+	defer h.gameState.mutex.RUnlock()
+	h.roster.mutex.Lock()
+	defer h.roster.mutex.Unlock()
+	h.events.mutex.Lock()
+	defer h.events.mutex.Unlock()
+
+	var missed [][]byte
+	resumed := false
+	if valid {
+		missed, resumed = h.events.rangeLocked(lastSeq)
+	}
+
+	response := &proto.HandshakeResponse{
+		Status:     "success",
+		UserId:     int64(client.userID),
+		ServerTime: time.Now().Unix(),
+		SessionId:  sessionID,
+		Seq:        h.events.seq,
+		Resumed:    resumed,
+	}
+
+	message, err := h.encodeMessage(HandshakeResponse, response)
+	if err != nil {
+		client.log.WithError(err).Error("编码握手响应失败")
+		return
+	}
+	h.sendToClient(client, message)
+
+	if !resumed {
+		h.sendSnapshotLocked(client)
+		if requested {
+			client.log.WithField("last_seq", lastSeq).Info("会话无法恢复，已发送完整快照")
+		}
+		return
+	}
+
+	for _, event := range missed {
+		h.sendToClient(client, event)
+	}
+	client.log.WithFields(logrus.Fields{
+		"last_seq": lastSeq,
+		"replayed": len(missed),
+	}).Info("会话已恢复")
+}
+
+// claimSession 将会话绑定到客户端，会话不存在、已过期或不属于该用户时创建新会话，返回的bool表示原会话有效
+func (h *Hub) claimSession(client *Client, sessionID string) (string, bool) {
+	h.sessionMutex.Lock()
+	defer h.sessionMutex.Unlock()
+
+	now := time.Now()
+	for id, session := range h.sessions {
+		if session.client == nil && now.After(session.expires) {
+			delete(h.sessions, id)
+		}
+	}
+
+	valid := false
+	if session, ok := h.sessions[sessionID]; ok && session.userID == client.userID {
+		valid = true
+	} else {
+		// 新连接、会话已过期或服务已重启（序号不再连续）
+		sessionID = uuid.NewString()
+	}
+
+	// 同一连接重复握手时丢弃之前的会话
+	if client.sessionID != "" && client.sessionID != sessionID {
+		delete(h.sessions, client.sessionID)
+	}
+	h.sessions[sessionID] = &resumeSession{userID: client.userID, client: client}
+	client.sessionID = sessionID
+	return sessionID, valid
+}
+
+// releaseSession 连接断开后保留会话resume_window秒，会话已被新连接接管时忽略
+func (h *Hub) releaseSession(client *Client) {
+	if client.sessionID == "" {
+		return
+	}
+
+	h.sessionMutex.Lock()
+	defer h.sessionMutex.Unlock()
+
+	if session, ok := h.sessions[client.sessionID]; ok && session.client == client {
+		session.client = nil
+		session.expires = time.Now().Add(time.Duration(config.AppConfig.WebSocket.ResumeWindow) * time.Second)
+	}
+}
@@ -6,20 +6,30 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+	"game-backend/config"
+	"game-backend/internal/middleware"
+	"game-backend/pkg/logger"
 )
 
-// WebSocket升级器
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		// 在生产环境中应该检查Origin
-		return true
-	},
+// newUpgrader 创建WebSocket升级器，启用压缩时与客户端协商permessage-deflate
+func newUpgrader() *websocket.Upgrader {
+	return &websocket.Upgrader{
+		ReadBufferSize:    1024,
+		WriteBufferSize:   1024,
+		EnableCompression: config.AppConfig.WebSocket.Compression,
+		CheckOrigin: func(r *http.Request) bool {
+			// 在生产环境中应该检查Origin
+			return true
+		},
+	}
 }
 
 // ServeWS WebSocket处理器
 func ServeWS(hub *Hub) gin.HandlerFunc {
+	upgrader := newUpgrader()
+
 	return func(c *gin.Context) {
 		// 升级HTTP连接为WebSocket连接
 		conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
@@ -30,6 +40,9 @@ func ServeWS(hub *Hub) gin.HandlerFunc {
 			})
 			return
 		}
+		if err := conn.SetCompressionLevel(config.AppConfig.WebSocket.CompressionLevel); err != nil {
+			logger.FromContext(c.Request.Context()).WithError(err).Warn("设置WebSocket压缩级别失败")
+		}
 
 		// 创建客户端
 		client := &Client{
@@ -37,6 +50,12 @@ func ServeWS(hub *Hub) gin.HandlerFunc {
 			send:        make(chan []byte, 256),
 			connectedAt: time.Now(),
 			lastActive:  time.Now(),
+			log: logger.FromContext(c.Request.Context()).WithFields(logrus.Fields{
+				"remote_addr": conn.RemoteAddr().String(),
+			}),
+			connSpan:  trace.SpanContextFromContext(c.Request.Context()),
+			requestID: middleware.GetRequestID(c),
+			clientIP:  c.ClientIP(),
 		}
 
 		// 注册客户端
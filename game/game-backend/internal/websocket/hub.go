@@ -4,14 +4,26 @@ import (
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
-	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/gorilla/websocket"
+	"github.com/go-redis/redis/v8"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+
+	"game-backend/config"
+	"game-backend/internal/middleware"
+	"game-backend/internal/service"
+	"game-backend/pkg/logger"
+	"game-backend/pkg/metrics"
+	"game-backend/pkg/money"
 	"game-backend/proto"
 )
 
+// multiplierStep 每次滴答倍数的增量，使用定点数避免浮点累加误差
+var multiplierStep = decimal.New(1, -2)
+
 // Hub WebSocket连接中心
 type Hub struct {
 	// 注册的客户端连接
@@ -31,50 +43,183 @@ type Hub struct {
 
 	// 游戏状态
 	gameState *GameState
+
+	// 游戏循环最近一次滴答时间（UnixNano）
+	lastTick atomic.Int64
+
+	// 下注与止盈业务服务
+	gameService *service.GameService
+
+	// 自动下注服务
+	autoBets *service.AutoBetService
+
+	// 串行化每局的自动下注执行
+	autoBetMutex sync.Mutex
+
+	// 单局风控账本
+	round *roundBook
+
+	// 本局下注列表
+	roster *roundRoster
+
+	// 广播序号与最近广播事件
+	events *eventLog
+
+	// 可恢复的连接会话
+	sessions     map[string]*resumeSession
+	sessionMutex sync.Mutex
+
+	// 聊天服务与聊天室
+	chat      *service.ChatService
+	chatRooms *chatRooms
+
+	// 在线用户，通过Redis汇总所有实例
+	presence *presence
+	redis    *redis.Client
+
+	// 排行榜，变化时广播
+	leaderboard *service.LeaderboardService
+
+	// 握手时检查登录会话是否仍有效
+	authSessions middleware.SessionChecker
+
+	// 日志条目
+	log *logrus.Entry
 }
 
 // GameState 游戏状态
 type GameState struct {
-	GameID           string  `json:"game_id"`
-	Status           int     `json:"status"` // 0:等待 1:进行中 2:已结束
-	CurrentMultiplier float64 `json:"current_multiplier"`
-	PlayersCount     int32   `json:"players_count"`
-	NextRoundIn      int32   `json:"next_round_in"`
-	LastUpdate       int64   `json:"last_update"`
-	mutex            sync.RWMutex
+	GameID            string          `json:"game_id"`
+	RoundID           string          `json:"round_id"`
+	Status            int             `json:"status"` // 0:等待 1:进行中 2:已结束
+	CurrentMultiplier decimal.Decimal `json:"current_multiplier"`
+	PlayersCount      int32           `json:"players_count"`    // 本局有下注的玩家数
+	OnlineCount       int32           `json:"online_count"`     // 所有实例的在线用户数
+	SpectatorsCount   int32           `json:"spectators_count"` // 在线但本局未下注的用户数
+	NextRoundIn       int32           `json:"next_round_in"`
+	LastUpdate        int64           `json:"last_update"`
+	LastUpdateMs      int64           `json:"last_update_ms"` // 毫秒
+	startedAt         time.Time       // 本局开始时间
+	sinceKeyframe     int             // 距上次GameStatusUpdate关键帧的滴答数
+	mutex             sync.RWMutex
 }
 
-
 // MessageType 消息类型
 type MessageType byte
 
 const (
-	GameStatusUpdate MessageType = 0x01
-	PlayerBet        MessageType = 0x02
-	GameStart        MessageType = 0x03
-	GameEnd          MessageType = 0x04
-	PlayerCashout    MessageType = 0x05
-	LeaderboardUpdate MessageType = 0x06
+	GameStatusUpdate   MessageType = 0x01
+	PlayerBet          MessageType = 0x02
+	GameStart          MessageType = 0x03
+	GameEnd            MessageType = 0x04
+	PlayerCashout      MessageType = 0x05
+	LeaderboardUpdate  MessageType = 0x06
 	SystemNotification MessageType = 0x07
-	HandshakeRequest MessageType = 0x08
-	HandshakeResponse MessageType = 0x09
+	HandshakeRequest   MessageType = 0x08
+	HandshakeResponse  MessageType = 0x09
+	AutoBetStart       MessageType = 0x0A
+	AutoBetStop        MessageType = 0x0B
+	AutoBetStatus      MessageType = 0x0C
+	PlayerBetCancel    MessageType = 0x0D
+	RoundSnapshot      MessageType = 0x0E
+	TimeSyncPing       MessageType = 0x0F
+	TimeSyncPong       MessageType = 0x10
+	GameTick           MessageType = 0x11
+	ChatJoin           MessageType = 0x12
+	ChatLeave          MessageType = 0x13
+	ChatHistory        MessageType = 0x14
+	ChatMessage        MessageType = 0x15
+	OnlineUsers        MessageType = 0x16
 )
 
+// String 消息类型名称
+func (t MessageType) String() string {
+	switch t {
+	case GameStatusUpdate:
+		return "game_status_update"
+	case PlayerBet:
+		return "player_bet"
+	case GameStart:
+		return "game_start"
+	case GameEnd:
+		return "game_end"
+	case PlayerCashout:
+		return "player_cashout"
+	case LeaderboardUpdate:
+		return "leaderboard_update"
+	case SystemNotification:
+		return "system_notification"
+	case HandshakeRequest:
+		return "handshake_request"
+	case HandshakeResponse:
+		return "handshake_response"
+	case AutoBetStart:
+		return "auto_bet_start"
+	case AutoBetStop:
+		return "auto_bet_stop"
+	case AutoBetStatus:
+		return "auto_bet_status"
+	case PlayerBetCancel:
+		return "player_bet_cancel"
+	case RoundSnapshot:
+		return "round_snapshot"
+	case TimeSyncPing:
+		return "time_sync_ping"
+	case TimeSyncPong:
+		return "time_sync_pong"
+	case GameTick:
+		return "game_tick"
+	case ChatJoin:
+		return "chat_join"
+	case ChatLeave:
+		return "chat_leave"
+	case ChatHistory:
+		return "chat_history"
+	case ChatMessage:
+		return "chat_message"
+	case OnlineUsers:
+		return "online_users"
+	default:
+		return fmt.Sprintf("unknown_0x%02x", byte(t))
+	}
+}
+
+// newRoundID 生成轮次ID，上一局崩盘时即生成下一局的ID，等待阶段的下注归属下一局
+func newRoundID() string {
+	return fmt.Sprintf("round_%d", time.Now().Unix())
+}
+
 // NewHub 创建新的WebSocket中心
-func NewHub() *Hub {
+func NewHub(gameService *service.GameService, autoBets *service.AutoBetService, chat *service.ChatService, leaderboard *service.LeaderboardService, redisClient *redis.Client, authSessions middleware.SessionChecker) *Hub {
+	roundID := newRoundID()
 	return &Hub{
 		clients:    make(map[*Client]bool),
 		broadcast:  make(chan []byte),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
 		gameState: &GameState{
-			GameID:           "crash_001",
-			Status:           0,
-			CurrentMultiplier: 1.0,
-			PlayersCount:     0,
-			NextRoundIn:      10,
-			LastUpdate:       time.Now().Unix(),
+			GameID:            "crash_001",
+			RoundID:           roundID,
+			Status:            0,
+			CurrentMultiplier: decimal.NewFromInt(1),
+			PlayersCount:      0,
+			NextRoundIn:       10,
+			LastUpdate:        time.Now().Unix(),
+			LastUpdateMs:      time.Now().UnixMilli(),
 		},
+		gameService:  gameService,
+		autoBets:     autoBets,
+		round:        newRoundBook(roundID),
+		roster:       newRoundRoster(roundID),
+		events:       newEventLog(config.AppConfig.WebSocket.EventBuffer),
+		sessions:     make(map[string]*resumeSession),
+		chat:         chat,
+		chatRooms:    newChatRooms(),
+		presence:     newPresence(),
+		leaderboard:  leaderboard,
+		redis:        redisClient,
+		authSessions: authSessions,
+		log:          logrus.WithField("component", "hub"),
 	}
 }
 
@@ -83,6 +228,16 @@ func (h *Hub) Run() {
 	// 启动游戏状态更新定时器
 	go h.gameLoop()
 
+	// 重启前进行中的自动下注从第一局开始继续执行
+	go h.runAutoBets()
+
+	// 同步所有实例的在线用户
+	go h.runPresence()
+
+	// 广播排行榜变化并定期写入快照
+	go h.runLeaderboard()
+	go h.runLeaderboardSnapshots()
+
 	for {
 		select {
 		case client := <-h.register:
@@ -100,35 +255,39 @@ func (h *Hub) Run() {
 // registerClient 注册客户端
 func (h *Hub) registerClient(client *Client) {
 	h.mutex.Lock()
-	defer h.mutex.Unlock()
-
 	h.clients[client] = true
-	h.gameState.mutex.Lock()
-	h.gameState.PlayersCount++
-	h.gameState.mutex.Unlock()
+	clients := len(h.clients)
+	h.mutex.Unlock()
 
-	log.Printf("客户端已连接: %s (用户ID: %d), 当前连接数: %d", 
-		client.username, client.userID, len(h.clients))
+	client.log.WithField("clients", clients).Info("客户端已连接")
 
-	// 发送当前游戏状态给新连接的客户端
-	h.sendGameStatusToClient(client)
+	// 发送当前游戏状态与下注列表快照给新连接的客户端
+	h.sendSnapshot(client)
 }
 
 // unregisterClient 注销客户端
 func (h *Hub) unregisterClient(client *Client) {
 	h.mutex.Lock()
-	defer h.mutex.Unlock()
-
-	if _, ok := h.clients[client]; ok {
+	_, ok := h.clients[client]
+	if ok {
 		delete(h.clients, client)
 		close(client.send)
-		h.gameState.mutex.Lock()
-		h.gameState.PlayersCount--
-		h.gameState.mutex.Unlock()
+	}
+	clients := len(h.clients)
+	h.mutex.Unlock()
 
-		log.Printf("客户端已断开: %s (用户ID: %d), 当前连接数: %d", 
-			client.username, client.userID, len(h.clients))
+	if !ok {
+		return
 	}
+
+	h.unbindPresence(client)
+	h.releaseSession(client)
+	h.leaveAllChats(client)
+
+	client.log.WithFields(logrus.Fields{
+		"clients":     clients,
+		"duration_ms": time.Since(client.connectedAt).Milliseconds(),
+	}).Info("客户端已断开")
 }
 
 // broadcastMessage 广播消息
@@ -137,45 +296,70 @@ func (h *Hub) broadcastMessage(message []byte) {
 	defer h.mutex.RUnlock()
 
 	for client := range h.clients {
-		select {
-		case client.send <- message:
-		default:
-			close(client.send)
-			delete(h.clients, client)
+		h.trySend(client, message)
+	}
+}
+
+// sendToUser 向指定用户的所有连接发送消息
+func (h *Hub) sendToUser(userID uint, message []byte) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	for client := range h.clients {
+		if client.userID == userID {
+			h.trySend(client, message)
 		}
 	}
 }
 
-// sendGameStatusToClient 发送游戏状态给指定客户端
-func (h *Hub) sendGameStatusToClient(client *Client) {
-	h.gameState.mutex.RLock()
-	defer h.gameState.mutex.RUnlock()
+// DisconnectUser 向玩家在本实例的所有连接发送通知后断开，由unregisterClient统一关闭通道，通知先于关闭帧写出
+func (h *Hub) DisconnectUser(userID uint, message string) {
+	h.notifyUser(userID, "warning", message)
 
-	statusUpdate := &proto.GameStatusUpdate{
-		GameId:            h.gameState.GameID,
-		State:             proto.GameState(h.gameState.Status),
-		CurrentMultiplier: h.gameState.CurrentMultiplier,
-		PlayersCount:      h.gameState.PlayersCount,
-		NextRoundIn:       h.gameState.NextRoundIn,
-		ServerTime:        h.gameState.LastUpdate,
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	for client := range h.clients {
+		if client.userID == userID {
+			go func(client *Client) {
+				h.unregister <- client
+			}(client)
+		}
 	}
+}
 
-	message, err := h.encodeMessage(GameStatusUpdate, statusUpdate)
-	if err != nil {
-		log.Printf("编码游戏状态消息失败: %v", err)
+// sendToClient 向指定客户端发送消息（客户端已注销时忽略）
+func (h *Hub) sendToClient(client *Client, message []byte) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	if _, ok := h.clients[client]; !ok {
 		return
 	}
+	h.trySend(client, message)
+}
 
+// trySend 非阻塞写入发送队列，调用方需持有h.mutex
+// 队列已满时丢弃消息并异步注销客户端，由unregisterClient统一关闭通道
+func (h *Hub) trySend(client *Client, message []byte) {
 	select {
 	case client.send <- message:
 	default:
-		close(client.send)
-		delete(h.clients, client)
+		metrics.WSMessagesDropped.Inc()
+		client.log.WithField("queue_len", len(client.send)).Warn("发送队列已满，断开客户端")
+		go func() {
+			h.unregister <- client
+		}()
 	}
 }
 
 // encodeMessage 编码消息
 func (h *Hub) encodeMessage(msgType MessageType, data interface{}) ([]byte, error) {
+	return EncodeMessage(msgType, data)
+}
+
+// EncodeMessage 按消息帧格式编码消息，供带宽测试等工具使用
+func EncodeMessage(msgType MessageType, data interface{}) ([]byte, error) {
 	// 序列化数据
 	protoData, err := json.Marshal(data)
 	if err != nil {
@@ -184,13 +368,13 @@ func (h *Hub) encodeMessage(msgType MessageType, data interface{}) ([]byte, erro
 
 	// 创建消息帧: [4字节长度][1字节类型][protobuf数据]
 	message := make([]byte, 5+len(protoData))
-	
+
 	// 设置长度（大端序）
 	binary.BigEndian.PutUint32(message[0:4], uint32(len(protoData)+1))
-	
+
 	// 设置消息类型
 	message[4] = byte(msgType)
-	
+
 	// 设置数据
 	copy(message[5:], protoData)
 
@@ -205,10 +389,10 @@ func (h *Hub) decodeMessage(data []byte) (MessageType, []byte, error) {
 
 	// 读取长度
 	length := binary.BigEndian.Uint32(data[0:4])
-	
+
 	// 读取消息类型
 	msgType := MessageType(data[4])
-	
+
 	// 读取数据
 	payload := data[5:]
 
@@ -221,10 +405,22 @@ func (h *Hub) decodeMessage(data []byte) (MessageType, []byte, error) {
 
 // gameLoop 游戏循环
 func (h *Hub) gameLoop() {
-	ticker := time.NewTicker(100 * time.Millisecond) // 100ms更新一次
+	const interval = 100 * time.Millisecond
+	ticker := time.NewTicker(interval) // 100ms更新一次
 	defer ticker.Stop()
 
+	prev := time.Now()
 	for range ticker.C {
+		// 记录实际间隔超出预期的部分
+		now := time.Now()
+		lag := now.Sub(prev) - interval
+		if lag < 0 {
+			lag = 0
+		}
+		metrics.GameTickLag.Observe(lag.Seconds())
+		prev = now
+		h.lastTick.Store(now.UnixNano())
+
 		h.updateGameState()
 	}
 }
@@ -234,8 +430,10 @@ func (h *Hub) updateGameState() {
 	h.gameState.mutex.Lock()
 	defer h.gameState.mutex.Unlock()
 
-	now := time.Now().Unix()
-	h.gameState.LastUpdate = now
+	now := time.Now()
+	h.gameState.LastUpdate = now.Unix()
+	h.gameState.LastUpdateMs = now.UnixMilli()
+	prevStatus := h.gameState.Status
 
 	// 模拟游戏逻辑
 	switch h.gameState.Status {
@@ -243,25 +441,32 @@ func (h *Hub) updateGameState() {
 		h.gameState.NextRoundIn--
 		if h.gameState.NextRoundIn <= 0 {
 			h.gameState.Status = 1 // 开始游戏
-			h.gameState.CurrentMultiplier = 1.0
+			h.round.lock(now)
+			h.gameState.startedAt = now
+			h.gameState.CurrentMultiplier = decimal.NewFromInt(1)
 			h.gameState.NextRoundIn = 30 // 30秒游戏时间
+			h.refreshPresenceCounts()
 			h.broadcastGameStart()
 		}
 	case 1: // 游戏进行中
-		h.gameState.CurrentMultiplier += 0.01 // 每秒增加0.01倍
+		h.gameState.CurrentMultiplier = h.gameState.CurrentMultiplier.Add(multiplierStep) // 每秒增加0.01倍
+		h.round.tick(now, h.gameState.CurrentMultiplier)
+		h.processRoundRisk()
 		h.gameState.NextRoundIn--
 		if h.gameState.NextRoundIn <= 0 {
 			h.gameState.Status = 2 // 游戏结束
+			h.crashRound(now)
 			h.broadcastGameEnd()
 		}
 	case 2: // 游戏结束
 		h.gameState.Status = 0 // 重置为等待状态
-		h.gameState.CurrentMultiplier = 1.0
+		h.gameState.RoundID = h.round.id()
+		h.gameState.CurrentMultiplier = decimal.NewFromInt(1)
 		h.gameState.NextRoundIn = 10 // 10秒等待时间
 	}
 
-	// 广播游戏状态更新
-	h.broadcastGameStatusUpdate()
+	// 状态变化时广播关键帧，否则按间隔广播关键帧或滴答
+	h.broadcastStatus(h.gameState.Status != prevStatus)
 }
 
 // broadcastGameStatusUpdate 广播游戏状态更新
@@ -269,56 +474,61 @@ func (h *Hub) broadcastGameStatusUpdate() {
 	statusUpdate := &proto.GameStatusUpdate{
 		GameId:            h.gameState.GameID,
 		State:             proto.GameState(h.gameState.Status),
-		CurrentMultiplier: h.gameState.CurrentMultiplier,
+		CurrentMultiplier: h.gameState.CurrentMultiplier.StringFixed(money.MultiplierScale),
 		PlayersCount:      h.gameState.PlayersCount,
 		NextRoundIn:       h.gameState.NextRoundIn,
 		ServerTime:        h.gameState.LastUpdate,
+		ServerTimeMs:      h.gameState.LastUpdateMs,
+		OnlineCount:       h.gameState.OnlineCount,
+		SpectatorsCount:   h.gameState.SpectatorsCount,
 	}
 
-	message, err := h.encodeMessage(GameStatusUpdate, statusUpdate)
-	if err != nil {
-		log.Printf("编码游戏状态更新消息失败: %v", err)
-		return
+	if err := h.publish(GameStatusUpdate, statusUpdate, &statusUpdate.Seq); err != nil {
+		h.log.WithError(err).Error("编码游戏状态更新消息失败")
 	}
-
-	h.broadcastMessage(message)
 }
 
 // broadcastGameStart 广播游戏开始
 func (h *Hub) broadcastGameStart() {
 	gameStart := &proto.GameStart{
-		RoundId:        fmt.Sprintf("round_%d", time.Now().Unix()),
+		RoundId:        h.gameState.RoundID,
 		PlayersCount:   h.gameState.PlayersCount,
-		TotalBetAmount: 0, // 这里应该从数据库获取
+		TotalBetAmount: money.Base().Format(money.Zero), // 这里应该从数据库获取
 		StartTime:      h.gameState.LastUpdate,
+		StartTimeMs:    h.gameState.LastUpdateMs,
 	}
 
-	message, err := h.encodeMessage(GameStart, gameStart)
-	if err != nil {
-		log.Printf("编码游戏开始消息失败: %v", err)
+	if err := h.publish(GameStart, gameStart, &gameStart.Seq); err != nil {
+		h.log.WithError(err).WithField(logger.FieldRoundID, h.gameState.RoundID).Error("编码游戏开始消息失败")
 		return
 	}
 
-	h.broadcastMessage(message)
+	h.log.WithFields(logrus.Fields{
+		logger.FieldRoundID: h.gameState.RoundID,
+		"players_count":     h.gameState.PlayersCount,
+	}).Info("游戏开始")
 }
 
 // broadcastGameEnd 广播游戏结束
 func (h *Hub) broadcastGameEnd() {
 	gameEnd := &proto.GameEnd{
-		RoundId:         fmt.Sprintf("round_%d", time.Now().Unix()),
-		FinalMultiplier: h.gameState.CurrentMultiplier,
-		WinnersCount:    0, // 这里应该从数据库获取
-		TotalPayout:     0, // 这里应该从数据库获取
+		RoundId:         h.gameState.RoundID,
+		FinalMultiplier: h.gameState.CurrentMultiplier.StringFixed(money.MultiplierScale),
+		WinnersCount:    0,                               // 这里应该从数据库获取
+		TotalPayout:     money.Base().Format(money.Zero), // 这里应该从数据库获取
 		EndTime:         h.gameState.LastUpdate,
 	}
 
-	message, err := h.encodeMessage(GameEnd, gameEnd)
-	if err != nil {
-		log.Printf("编码游戏结束消息失败: %v", err)
+	if err := h.publish(GameEnd, gameEnd, &gameEnd.Seq); err != nil {
+		h.log.WithError(err).WithField(logger.FieldRoundID, h.gameState.RoundID).Error("编码游戏结束消息失败")
 		return
 	}
 
-	h.broadcastMessage(message)
+	metrics.ObserveRoundEnd()
+	h.log.WithFields(logrus.Fields{
+		logger.FieldRoundID: h.gameState.RoundID,
+		"final_multiplier":  h.gameState.CurrentMultiplier.String(),
+	}).Info("游戏结束")
 }
 
 // GetGameState 获取当前游戏状态
@@ -329,11 +539,15 @@ func (h *Hub) GetGameState() *GameState {
 	// 返回副本以避免竞态条件
 	return &GameState{
 		GameID:            h.gameState.GameID,
+		RoundID:           h.gameState.RoundID,
 		Status:            h.gameState.Status,
 		CurrentMultiplier: h.gameState.CurrentMultiplier,
 		PlayersCount:      h.gameState.PlayersCount,
+		OnlineCount:       h.gameState.OnlineCount,
+		SpectatorsCount:   h.gameState.SpectatorsCount,
 		NextRoundIn:       h.gameState.NextRoundIn,
 		LastUpdate:        h.gameState.LastUpdate,
+		LastUpdateMs:      h.gameState.LastUpdateMs,
 	}
 }
 
@@ -343,3 +557,24 @@ func (h *Hub) GetClientsCount() int {
 	defer h.mutex.RUnlock()
 	return len(h.clients)
 }
+
+// LastTick 获取游戏循环最近一次滴答时间，循环未启动时返回零值
+func (h *Hub) LastTick() time.Time {
+	nano := h.lastTick.Load()
+	if nano == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nano)
+}
+
+// SendQueueDepth 获取所有客户端发送队列中待发送的消息总数
+func (h *Hub) SendQueueDepth() int {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	depth := 0
+	for client := range h.clients {
+		depth += len(client.send)
+	}
+	return depth
+}
@@ -0,0 +1,103 @@
+package websocket
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"game-backend/config"
+	"game-backend/internal/model"
+	"game-backend/internal/service"
+	"game-backend/pkg/money"
+	"game-backend/proto"
+)
+
+// runLeaderboard 按broadcast_interval检查各排行榜前broadcast_size名，有变化时广播
+func (h *Hub) runLeaderboard() {
+	ticker := time.NewTicker(time.Duration(config.AppConfig.Leaderboard.BroadcastInterval) * time.Second)
+	defer ticker.Stop()
+
+	last := make(map[string]string) // 排行榜 -> 上次广播的内容摘要
+	for range ticker.C {
+		for _, period := range service.LeaderboardPeriods {
+			for _, metric := range service.LeaderboardMetrics {
+				h.broadcastLeaderboard(period, metric, last)
+			}
+		}
+	}
+}
+
+// broadcastLeaderboard 排行榜与上次广播不同时广播，跨过周期边界时广播新周期的空榜
+func (h *Hub) broadcastLeaderboard(period, metric string, last map[string]string) {
+	board, err := h.leaderboard.Top(period, metric, config.AppConfig.Leaderboard.BroadcastSize)
+	if err != nil {
+		h.log.WithError(err).WithField("period", period).WithField("metric", metric).Warn("获取排行榜失败")
+		return
+	}
+
+	key := metric + ":" + period
+	digest := leaderboardDigest(board)
+	if digest == last[key] {
+		return
+	}
+
+	update := leaderboardView(board)
+	if err := h.publish(LeaderboardUpdate, update, &update.Seq); err != nil {
+		h.log.WithError(err).Error("编码排行榜更新失败")
+		return
+	}
+	last[key] = digest
+}
+
+// leaderboardDigest 排行榜内容摘要，用于判断是否有变化
+func leaderboardDigest(board *service.LeaderboardBoard) string {
+	var b strings.Builder
+	b.WriteString(board.Bucket)
+	for _, entry := range board.Entries {
+		fmt.Fprintf(&b, "|%d:%s:%s", entry.UserID, entry.Username, entry.Score.String())
+	}
+	return b.String()
+}
+
+// leaderboardView 排行榜的下发格式
+func leaderboardView(board *service.LeaderboardBoard) *proto.LeaderboardUpdate {
+	update := &proto.LeaderboardUpdate{
+		Period:     board.Period,
+		Metric:     board.Metric,
+		Bucket:     board.Bucket,
+		UpdateTime: time.Now().Unix(),
+	}
+	places := int32(money.MultiplierScale)
+	if board.Metric == model.LeaderboardProfit {
+		places = money.Base().Precision
+	}
+	for _, entry := range board.Entries {
+		view := &proto.LeaderboardEntry{
+			UserId:   int64(entry.UserID),
+			Username: entry.Username,
+			Rank:     entry.Rank,
+			Score:    entry.Score.StringFixed(places),
+		}
+		if board.Metric == model.LeaderboardProfit {
+			view.TotalWinnings = view.Score
+		} else {
+			view.BiggestMultiplier = view.Score
+		}
+		update.Entries = append(update.Entries, view)
+	}
+	return update
+}
+
+// runLeaderboardSnapshots 按snapshot_interval将排行榜写入MySQL，各实例写入的快照相同，按名次覆盖
+func (h *Hub) runLeaderboardSnapshots() {
+	cfg := config.AppConfig.Leaderboard
+	interval := time.Duration(cfg.SnapshotInterval) * time.Second
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		if err := h.leaderboard.Snapshot(cfg.Size, now, now.Add(-interval)); err != nil {
+			h.log.WithError(err).Warn("写入排行榜快照失败")
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package websocket
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+
+	"game-backend/config"
+	"game-backend/proto"
+)
+
+// Redis中的在线状态：每个实例定期覆盖写入自己的在线用户哈希（用户ID -> 用户名），
+// 实例有序集合记录各实例最近一次同步时间，3个同步间隔未更新的实例视为下线
+const (
+	presenceInstancesKey = "presence:instances"
+	presenceUsersKey     = "presence:instance:%s"
+)
+
+// userPresence 本实例一个在线用户
+type userPresence struct {
+	username string
+	conns    int // 该用户在本实例的连接数
+}
+
+// presence 在线用户，按用户ID合并同一用户的多个连接，并通过Redis汇总所有实例
+// 锁顺序：presence.mutex -> Hub.mutex，与游戏状态相关的锁在其之前
+type presence struct {
+	mutex      sync.Mutex
+	instanceID string
+	clients    map[*Client]uint       // 已握手的连接及其用户
+	local      map[uint]*userPresence // 本实例在线用户
+	remote     map[uint]string        // 最近一次同步得到的其他实例在线用户
+	online     map[uint]string        // local与remote的并集
+	changed    chan struct{}          // 本实例在线用户变化，提前同步
+}
+
+// newPresence 创建在线用户表
+func newPresence() *presence {
+	return &presence{
+		instanceID: uuid.NewString(),
+		clients:    make(map[*Client]uint),
+		local:      make(map[uint]*userPresence),
+		remote:     make(map[uint]string),
+		online:     make(map[uint]string),
+		changed:    make(chan struct{}, 1),
+	}
+}
+
+// mergeLocked 重新计算在线用户并集，调用方需持有p.mutex
+func (p *presence) mergeLocked() {
+	online := make(map[uint]string, len(p.local)+len(p.remote))
+	for userID, username := range p.remote {
+		online[userID] = username
+	}
+	for userID, user := range p.local {
+		online[userID] = user.username
+	}
+	p.online = online
+}
+
+// notify 通知同步协程本实例在线用户已变化
+func (p *presence) notify() {
+	select {
+	case p.changed <- struct{}{}:
+	default:
+	}
+}
+
+// bindPresence 握手成功后将连接计入用户在线，同一连接重复握手时先移出原用户；连接已注销时忽略
+func (h *Hub) bindPresence(client *Client) {
+	p := h.presence
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	h.mutex.RLock()
+	_, registered := h.clients[client]
+	h.mutex.RUnlock()
+	if !registered {
+		return
+	}
+
+	if userID, ok := p.clients[client]; ok {
+		if userID == client.userID {
+			return
+		}
+		p.removeLocked(client, userID)
+	}
+
+	p.clients[client] = client.userID
+	user, ok := p.local[client.userID]
+	if !ok {
+		user = &userPresence{}
+		p.local[client.userID] = user
+	}
+	user.username = client.username
+	user.conns++
+	if !ok {
+		p.mergeLocked()
+		p.notify()
+	}
+}
+
+// unbindPresence 连接注销后移出用户在线，该用户在本实例的最后一个连接断开时用户离线
+func (h *Hub) unbindPresence(client *Client) {
+	p := h.presence
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if userID, ok := p.clients[client]; ok {
+		p.removeLocked(client, userID)
+	}
+}
+
+// removeLocked 移除连接，调用方需持有p.mutex
+func (p *presence) removeLocked(client *Client, userID uint) {
+	delete(p.clients, client)
+
+	user, ok := p.local[userID]
+	if !ok {
+		return
+	}
+	user.conns--
+	if user.conns > 0 {
+		return
+	}
+	delete(p.local, userID)
+	p.mergeLocked()
+	p.notify()
+}
+
+// runPresence 按presence_interval或本实例在线用户变化时同步Redis
+func (h *Hub) runPresence() {
+	interval := time.Duration(config.AppConfig.WebSocket.PresenceInterval) * time.Second
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		h.syncPresence(interval)
+
+		select {
+		case <-ticker.C:
+		case <-h.presence.changed:
+		}
+	}
+}
+
+// syncPresence 覆盖写入本实例在线用户并读取其他存活实例的在线用户，Redis不可用时只保留本实例
+func (h *Hub) syncPresence(interval time.Duration) {
+	if h.redis == nil {
+		return
+	}
+
+	p := h.presence
+	p.mutex.Lock()
+	fields := make([]interface{}, 0, len(p.local)*2)
+	for userID, user := range p.local {
+		fields = append(fields, strconv.FormatUint(uint64(userID), 10), user.username)
+	}
+	p.mutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), interval)
+	defer cancel()
+
+	now := time.Now()
+	ttl := 3 * interval
+	key := fmt.Sprintf(presenceUsersKey, p.instanceID)
+	_, err := h.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		if len(fields) > 0 {
+			pipe.HSet(ctx, key, fields...)
+			pipe.Expire(ctx, key, ttl)
+		}
+		pipe.ZAdd(ctx, presenceInstancesKey, &redis.Z{Score: float64(now.UnixMilli()), Member: p.instanceID})
+		pipe.ZRemRangeByScore(ctx, presenceInstancesKey, "-inf", strconv.FormatInt(now.Add(-ttl).UnixMilli(), 10))
+		return nil
+	})
+	if err != nil {
+		h.log.WithError(err).Warn("同步在线状态失败")
+		return
+	}
+
+	instances, err := h.redis.ZRange(ctx, presenceInstancesKey, 0, -1).Result()
+	if err != nil {
+		h.log.WithError(err).Warn("获取在线实例失败")
+		return
+	}
+
+	remote := make(map[uint]string)
+	for _, instanceID := range instances {
+		if instanceID == p.instanceID {
+			continue
+		}
+		users, err := h.redis.HGetAll(ctx, fmt.Sprintf(presenceUsersKey, instanceID)).Result()
+		if err != nil {
+			h.log.WithError(err).WithField("instance_id", instanceID).Warn("获取实例在线用户失败")
+			continue
+		}
+		for id, username := range users {
+			if userID, err := strconv.ParseUint(id, 10, 64); err == nil {
+				remote[uint(userID)] = username
+			}
+		}
+	}
+
+	p.mutex.Lock()
+	p.remote = remote
+	p.mergeLocked()
+	p.mutex.Unlock()
+}
+
+// LeavePresence 服务关闭时删除本实例的在线用户，其他实例下次同步后不再计入
+func (h *Hub) LeavePresence(ctx context.Context) error {
+	if h.redis == nil {
+		return nil
+	}
+
+	_, err := h.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, fmt.Sprintf(presenceUsersKey, h.presence.instanceID))
+		pipe.ZRem(ctx, presenceInstancesKey, h.presence.instanceID)
+		return nil
+	})
+	return err
+}
+
+// refreshPresenceCounts 更新游戏状态中的玩家、在线与观战人数，调用方需持有gameState.mutex
+func (h *Hub) refreshPresenceCounts() {
+	h.roster.mutex.Lock()
+	players := h.roster.playersLocked()
+	h.roster.mutex.Unlock()
+
+	h.presence.mutex.Lock()
+	online := len(h.presence.online)
+	playing := 0
+	for userID := range players {
+		if _, ok := h.presence.online[userID]; ok {
+			playing++
+		}
+	}
+	h.presence.mutex.Unlock()
+
+	h.gameState.PlayersCount = int32(len(players))
+	h.gameState.OnlineCount = int32(online)
+	h.gameState.SpectatorsCount = int32(online - playing)
+}
+
+// OnlineUsers 获取所有实例的在线用户，本局有下注的玩家在前，按用户ID排序，最多online_list_limit个
+func (h *Hub) OnlineUsers() *proto.OnlineUsers {
+	h.roster.mutex.Lock()
+	players := h.roster.playersLocked()
+	h.roster.mutex.Unlock()
+
+	h.presence.mutex.Lock()
+	users := make([]*proto.OnlineUser, 0, len(h.presence.online))
+	for userID, username := range h.presence.online {
+		users = append(users, &proto.OnlineUser{
+			UserId:   int64(userID),
+			Username: username,
+			Playing:  players[userID],
+		})
+	}
+	h.presence.mutex.Unlock()
+
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].Playing != users[j].Playing {
+			return users[i].Playing
+		}
+		return users[i].UserId < users[j].UserId
+	})
+
+	playing := 0
+	for _, user := range users {
+		if user.Playing {
+			playing++
+		}
+	}
+
+	result := &proto.OnlineUsers{
+		OnlineCount:     int32(len(users)),
+		PlayersCount:    int32(len(players)),
+		SpectatorsCount: int32(len(users) - playing),
+		Connections:     int32(h.GetClientsCount()),
+		Timestamp:       time.Now().Unix(),
+	}
+	if limit := config.AppConfig.WebSocket.OnlineListLimit; len(users) > limit {
+		users = users[:limit]
+		result.Truncated = true
+	}
+	result.Users = users
+	return result
+}
+
+// handleOnlineUsers 回应在线用户列表请求
+func (c *Client) handleOnlineUsers(hub *Hub) {
+	message, err := hub.encodeMessage(OnlineUsers, hub.OnlineUsers())
+	if err != nil {
+		c.log.WithError(err).Error("编码在线用户列表失败")
+		return
+	}
+
+	hub.sendToClient(c, message)
+}
@@ -0,0 +1,646 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"game-backend/config"
+	"game-backend/internal/model"
+	"game-backend/internal/service"
+	"game-backend/pkg/logger"
+	"game-backend/pkg/metrics"
+	"game-backend/pkg/money"
+)
+
+var (
+	// ErrRoundExposureExceeded 本局潜在赔付已达上限
+	ErrRoundExposureExceeded = errors.New("本局下注额度已满，请下一局再试")
+	// ErrGameNotRunning 游戏未进行中
+	ErrGameNotRunning = errors.New("游戏未进行中")
+	// ErrBetSlotsFull 本局下注位已用完
+	ErrBetSlotsFull = errors.New("本局下注数已达上限")
+	// ErrInvalidBetSlot 下注位超出范围
+	ErrInvalidBetSlot = errors.New("下注位无效")
+	// ErrBetSlotTaken 下注位本局已下注
+	ErrBetSlotTaken = errors.New("该下注位本局已下注")
+	// ErrBettingClosed 本局已开始，不能再下注或撤销下注
+	ErrBettingClosed = errors.New("本局已开始，请等待下一局")
+)
+
+// AutoSlot 由服务端分配最小的空闲下注位
+const AutoSlot = -1
+
+// recentTicks 账本保留的最近滴答数，用于按止盈发出时间查找倍数
+const recentTicks = 64
+
+// roundBet 本局进行中的下注，金额字段均已折算为基准币种
+type roundBet struct {
+	betID      string
+	userID     uint
+	slot       int
+	currency   money.Currency
+	amount     decimal.Decimal // 剩余本金
+	amountBase decimal.Decimal
+	ceiling    decimal.Decimal // 自动止盈倍数与最大倍数中的较小值
+	won        decimal.Decimal // 部分止盈已实现的盈利
+	trigger    decimal.Decimal // 系统结算倍数：ceiling与单注盈利上限倍数中的较小值
+	capped     bool            // trigger由单注盈利上限决定
+	potential  decimal.Decimal // 按trigger计算的潜在赔付
+	gen        uint64
+	settling   *sync.WaitGroup // 取出待止盈时所属局的结算计数，结算完成后Done
+}
+
+// roundTick 一次滴答时的倍数
+type roundTick struct {
+	at         time.Time
+	multiplier decimal.Decimal
+}
+
+// crashedRound 刚崩盘的一局，宽限期内仍受理崩盘前发出的止盈
+type crashedRound struct {
+	at       time.Time // 崩盘时间
+	bets     map[string]*roundBet
+	ticks    []roundTick
+	settling *sync.WaitGroup // 本局仍在结算中的止盈
+}
+
+// roundBook 单局风控账本，风险敞口 = 已赔付 + 进行中下注的潜在赔付
+type roundBook struct {
+	mutex    sync.Mutex
+	gen      uint64
+	roundID  string // 接受下注的轮次
+	locked   bool   // 本局已开始，下注不能撤销
+	bets     map[string]*roundBet
+	slots    map[uint]map[int]bool // 各玩家本局已占用的下注位，结算后仍占用
+	exposure decimal.Decimal       // 进行中下注的潜在赔付
+	paid     decimal.Decimal       // 本局已赔付
+	ticks    []roundTick           // 本局最近的滴答
+	settling *sync.WaitGroup       // 本局已取出、尚未结算完成的止盈
+	crashed  *crashedRound         // 上一局，宽限期结束后为nil
+}
+
+// dueCashout 需要系统结算的下注
+type dueCashout struct {
+	bet        *roundBet
+	multiplier decimal.Decimal
+	action     string
+	settling   *sync.WaitGroup // 所属局的结算计数，结算完成后Done
+}
+
+// newRoundBook 创建单局风控账本
+func newRoundBook(roundID string) *roundBook {
+	return &roundBook{
+		roundID:  roundID,
+		bets:     make(map[string]*roundBet),
+		slots:    make(map[uint]map[int]bool),
+		settling: &sync.WaitGroup{},
+	}
+}
+
+// id 返回当前接受下注的轮次ID
+func (b *roundBook) id() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.roundID
+}
+
+// newRoundBet 计算下注的系统结算倍数与潜在赔付
+func newRoundBet(userID uint, cur money.Currency, amount, autoCashout, maxMultiplier decimal.Decimal) *roundBet {
+	bet := &roundBet{
+		userID:   userID,
+		currency: cur,
+		amount:   amount,
+		ceiling:  maxMultiplier,
+	}
+
+	if autoCashout.IsPositive() && autoCashout.LessThan(bet.ceiling) {
+		bet.ceiling = autoCashout
+	}
+
+	bet.reprice()
+	return bet
+}
+
+// reprice 按剩余本金重新计算系统结算倍数与潜在赔付
+func (bet *roundBet) reprice() {
+	bet.amountBase = bet.currency.ToBase(bet.amount)
+	bet.trigger = bet.ceiling
+	bet.capped = false
+
+	// 单注盈利上限：won + amountBase × (m - 1) <= maxWin
+	if maxWin := config.AppConfig.Game.MaxWinPerBet; maxWin > 0 && bet.amountBase.IsPositive() {
+		left := decimal.NewFromFloat(maxWin).Sub(bet.won)
+		limit := money.TruncMultiplier(decimal.NewFromInt(1).Add(left.Div(bet.amountBase)))
+		if limit.LessThan(bet.trigger) {
+			bet.trigger = limit
+			bet.capped = true
+		}
+	}
+
+	bet.potential = bet.amountBase.Mul(bet.trigger)
+}
+
+// reserve 分配下注位并预占风险敞口，slot为AutoSlot时分配最小的空闲下注位；本局已开始或超出单局上限时拒绝
+func (b *roundBook) reserve(bet *roundBet, slot int) (string, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.locked {
+		return "", ErrBettingClosed
+	}
+
+	taken := b.slots[bet.userID]
+	maxSlots := config.AppConfig.Game.MaxBetsPerRound
+	switch {
+	case slot == AutoSlot:
+		for slot = 0; slot < maxSlots && taken[slot]; slot++ {
+		}
+		if slot == maxSlots {
+			return "", ErrBetSlotsFull
+		}
+	case slot < 0 || slot >= maxSlots:
+		return "", ErrInvalidBetSlot
+	case taken[slot]:
+		return "", ErrBetSlotTaken
+	}
+
+	if max := config.AppConfig.Game.MaxRoundExposure; max > 0 &&
+		b.paid.Add(b.exposure).Add(bet.potential).GreaterThan(decimal.NewFromFloat(max)) {
+		return "", ErrRoundExposureExceeded
+	}
+
+	bet.gen = b.gen
+	bet.slot = slot
+	b.claimSlotLocked(bet)
+	b.exposure = b.exposure.Add(bet.potential)
+	metrics.RoundExposure.Set(b.paid.Add(b.exposure).InexactFloat64())
+	return b.roundID, nil
+}
+
+// claimSlotLocked 占用下注位，调用方需持有b.mutex
+func (b *roundBook) claimSlotLocked(bet *roundBet) {
+	taken := b.slots[bet.userID]
+	if taken == nil {
+		taken = make(map[int]bool)
+		b.slots[bet.userID] = taken
+	}
+	taken[bet.slot] = true
+}
+
+// release 下注失败时释放预占的下注位与敞口
+func (b *roundBook) release(bet *roundBet) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if bet.gen == b.gen {
+		delete(b.slots[bet.userID], bet.slot)
+		b.exposure = b.exposure.Sub(bet.potential)
+		metrics.RoundExposure.Set(b.paid.Add(b.exposure).InexactFloat64())
+	}
+}
+
+// confirm 下注成功后加入账本；预占后已换局时返回false，下注记在已结束的一局，不能计入新一局
+// 预占的下注位与敞口已随换局清空，无需释放
+func (b *roundBook) confirm(bet *roundBet, betID string) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	bet.betID = betID
+	if bet.gen != b.gen {
+		return false
+	}
+	b.bets[betID] = bet
+	return true
+}
+
+// lock 本局开始，此后下注不能撤销
+func (b *roundBook) lock(at time.Time) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.locked = true
+	b.ticks = append(b.ticks, roundTick{at: at, multiplier: decimal.NewFromInt(1)})
+}
+
+// tick 记录本局滴答时的倍数
+func (b *roundBook) tick(at time.Time, multiplier decimal.Decimal) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.ticks = append(b.ticks, roundTick{at: at, multiplier: multiplier})
+	if len(b.ticks) > recentTicks {
+		b.ticks = b.ticks[len(b.ticks)-recentTicks:]
+	}
+}
+
+// multiplierAt 返回at时刻的倍数，即不晚于at的最后一次滴答的倍数，早于保留的滴答时取最早一次
+func multiplierAt(ticks []roundTick, at time.Time) decimal.Decimal {
+	for i := len(ticks) - 1; i >= 0; i-- {
+		if !ticks[i].at.After(at) {
+			return ticks[i].multiplier
+		}
+	}
+	if len(ticks) > 0 {
+		return ticks[0].multiplier
+	}
+	return decimal.NewFromInt(1)
+}
+
+// cancel 本局开始前取出待撤销的下注并释放其敞口，下注位本局仍被占用；不在账本中时返回nil
+func (b *roundBook) cancel(betID string, userID uint) (*roundBet, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.locked {
+		return nil, ErrBettingClosed
+	}
+	bet, ok := b.bets[betID]
+	if !ok {
+		return nil, nil
+	}
+	if bet.userID != userID {
+		return nil, service.ErrBetNotOwned
+	}
+
+	delete(b.bets, betID)
+	b.exposure = b.exposure.Sub(bet.potential)
+	metrics.RoundExposure.Set(b.paid.Add(b.exposure).InexactFloat64())
+	return bet, nil
+}
+
+// reinstate 撤销失败时放回账本并恢复敞口，已换局时返回false
+func (b *roundBook) reinstate(bet *roundBet) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if bet.gen != b.gen {
+		return false
+	}
+	b.bets[bet.betID] = bet
+	b.exposure = b.exposure.Add(bet.potential)
+	metrics.RoundExposure.Set(b.paid.Add(b.exposure).InexactFloat64())
+	return true
+}
+
+// take 取出待手动止盈的下注及止盈发出时刻pressedAt的倍数，结算完成前敞口仍然计入，调用方结算完成后需调用bet.settling.Done
+// 本局未开始时仅受理上一局崩盘前发出、宽限期内到达，且到达前崩盘消息尚未写出到该连接（crashSeen早于崩盘时间）的止盈
+func (b *roundBook) take(betID string, userID uint, pressedAt, crashSeen time.Time) (*roundBet, decimal.Decimal, bool, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	bets, ticks, settling := b.bets, b.ticks, b.settling
+	if !b.locked {
+		if b.crashed == nil || !pressedAt.Before(b.crashed.at) || !crashSeen.Before(b.crashed.at) {
+			return nil, decimal.Zero, false, ErrGameNotRunning
+		}
+		bets, ticks, settling = b.crashed.bets, b.crashed.ticks, b.crashed.settling
+	}
+
+	bet, ok := bets[betID]
+	if !ok {
+		return nil, decimal.Zero, false, nil
+	}
+	if bet.userID != userID {
+		return nil, decimal.Zero, true, service.ErrBetNotOwned
+	}
+	delete(bets, betID)
+	settling.Add(1)
+	bet.settling = settling
+	return bet, multiplierAt(ticks, pressedAt), true, nil
+}
+
+// restore 止盈失败时放回账本，已换局时返回false
+func (b *roundBook) restore(bet *roundBet) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if bet.gen != b.gen {
+		return false
+	}
+	b.bets[bet.betID] = bet
+	return true
+}
+
+// partial 部分止盈后以剩余本金重新计算敞口并放回账本，已换局时返回false
+func (b *roundBook) partial(bet *roundBet, stake, payout decimal.Decimal) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if bet.gen != b.gen {
+		return false
+	}
+
+	payoutBase := bet.currency.ToBase(payout)
+	b.exposure = b.exposure.Sub(bet.potential)
+	b.paid = b.paid.Add(payoutBase)
+
+	bet.won = bet.won.Add(payoutBase.Sub(bet.currency.ToBase(stake)))
+	bet.amount = bet.amount.Sub(stake)
+	bet.reprice()
+
+	b.exposure = b.exposure.Add(bet.potential)
+	b.bets[bet.betID] = bet
+	metrics.RoundExposure.Set(b.paid.Add(b.exposure).InexactFloat64())
+	return true
+}
+
+// settle 下注剩余本金结算后以实际赔付替换潜在赔付
+func (b *roundBook) settle(bet *roundBet, payout decimal.Decimal) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.settleLocked(bet, payout)
+}
+
+// settleLocked 同settle，调用方需持有b.mutex
+func (b *roundBook) settleLocked(bet *roundBet, payout decimal.Decimal) {
+	if bet.gen != b.gen {
+		return
+	}
+	b.exposure = b.exposure.Sub(bet.potential)
+	b.paid = b.paid.Add(bet.currency.ToBase(payout))
+	metrics.RoundExposure.Set(b.paid.Add(b.exposure).InexactFloat64())
+}
+
+// collectDue 取出当前倍数下需要系统结算的下注：
+// 达到自动止盈或单注盈利上限的按其结算倍数止盈；
+// 已赔付与进行中下注按当前倍数计算的赔付之和达到单局赔付上限时，所有进行中下注按当前倍数强制止盈
+func (b *roundBook) collectDue(current decimal.Decimal) []dueCashout {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	var due []dueCashout
+	for betID, bet := range b.bets {
+		if current.LessThan(bet.trigger) {
+			continue
+		}
+		action := model.AuditActionBetAutoCashout
+		if bet.capped {
+			action = model.AuditActionBetForced
+		}
+		b.settling.Add(1)
+		due = append(due, dueCashout{bet: bet, multiplier: bet.trigger, action: action, settling: b.settling})
+		delete(b.bets, betID)
+		b.settleLocked(bet, bet.currency.Payout(bet.amount, bet.trigger))
+	}
+
+	if max := config.AppConfig.Game.MaxRoundPayout; max > 0 && len(b.bets) > 0 {
+		total := b.paid
+		for _, bet := range b.bets {
+			total = total.Add(bet.amountBase.Mul(current))
+		}
+		if total.GreaterThanOrEqual(decimal.NewFromFloat(max)) {
+			for betID, bet := range b.bets {
+				b.settling.Add(1)
+				due = append(due, dueCashout{bet: bet, multiplier: current, action: model.AuditActionBetForced, settling: b.settling})
+				delete(b.bets, betID)
+				b.settleLocked(bet, bet.currency.Payout(bet.amount, current))
+			}
+		}
+	}
+
+	return due
+}
+
+// reset 本局于crashedAt崩盘，仍在进行中的下注转入宽限期，开始接受下一局nextRoundID的下注
+func (b *roundBook) reset(nextRoundID string, crashedAt time.Time) *crashedRound {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.crashed = &crashedRound{at: crashedAt, bets: b.bets, ticks: b.ticks, settling: b.settling}
+
+	b.gen++
+	b.roundID = nextRoundID
+	b.locked = false
+	b.bets = make(map[string]*roundBet)
+	b.slots = make(map[uint]map[int]bool)
+	b.exposure = decimal.Zero
+	b.paid = decimal.Zero
+	b.ticks = nil
+	b.settling = &sync.WaitGroup{}
+	metrics.RoundExposure.Set(0)
+	return b.crashed
+}
+
+// expire 宽限期结束，返回崩盘局中仍未止盈的下注ID；此后崩盘局不再取出止盈，crashed.settling不再增加
+func (b *roundBook) expire(crashed *crashedRound) []string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.crashed == crashed {
+		b.crashed = nil
+	}
+
+	betIDs := make([]string, 0, len(crashed.bets))
+	for betID := range crashed.bets {
+		betIDs = append(betIDs, betID)
+	}
+	crashed.bets = nil
+	return betIDs
+}
+
+// PlaceBet 分配下注位并经单局风控预占敞口后下注并广播，REST、WebSocket与自动下注共用，slot为AutoSlot时自动分配
+func (h *Hub) PlaceBet(ctx context.Context, userID uint, slot int, currency string, amount, autoCashout decimal.Decimal, meta service.AuditMeta) (*model.Bet, error) {
+	cur, err := money.Lookup(currency)
+	if err != nil {
+		return nil, err
+	}
+
+	gameService := h.gameService.WithContext(ctx)
+	entry := newRoundBet(userID, cur, amount, autoCashout, gameService.GetMaxMultiplier())
+	roundID, err := h.round.reserve(entry, slot)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrRoundExposureExceeded):
+			metrics.BetsRejected.WithLabelValues("round_exposure").Inc()
+		case errors.Is(err, ErrBettingClosed):
+			metrics.BetsRejected.WithLabelValues("betting_closed").Inc()
+		default:
+			metrics.BetsRejected.WithLabelValues("bet_slot").Inc()
+		}
+		return nil, err
+	}
+
+	bet, err := gameService.PlaceBet(userID, roundID, entry.slot, currency, amount, autoCashout, meta)
+	if err != nil {
+		h.round.release(entry)
+		return nil, err
+	}
+
+	if !h.round.confirm(entry, bet.BetID) {
+		// 下注写入期间本局已开始并崩盘，崩盘结算时该下注尚不在账本中，撤销并退回本金
+		if _, err := gameService.CancelBet(userID, bet.BetID, service.SystemAuditMeta()); err != nil {
+			h.log.WithError(err).WithFields(logrus.Fields{
+				logger.FieldBetID:   bet.BetID,
+				logger.FieldRoundID: bet.RoundID,
+				logger.FieldUserID:  userID,
+			}).Error("撤销已结束轮次的下注失败")
+		}
+		metrics.BetsRejected.WithLabelValues("betting_closed").Inc()
+		return nil, ErrBettingClosed
+	}
+	h.broadcastPlayerBet(bet)
+	return bet, nil
+}
+
+// Cashout 按玩家发出止盈时的倍数手动止盈剩余本金的fraction比例，倍数不超过该下注的系统结算倍数
+// latency为客户端单程延迟，发出时间 = 收到时间 - latency，latency不超过cashout_latency_cap；崩盘前发出的止盈在宽限期内到达仍可结算，
+// 但crashSeen（收到止盈时崩盘消息最近一次写出到该连接的时间）不早于崩盘时间时说明玩家已看到崩盘，不再受理
+// fraction小于1时为部分止盈，其余本金留在账本中继续参与本局；结算后广播，REST与WebSocket止盈共用
+func (h *Hub) Cashout(ctx context.Context, userID uint, betID string, fraction decimal.Decimal, latency time.Duration, crashSeen time.Time, meta service.AuditMeta) (*model.Bet, *model.BetSettlement, error) {
+	if limit := cashoutLatencyCap(); latency > limit {
+		latency = limit
+	}
+
+	gameService := h.gameService.WithContext(ctx)
+	entry, multiplier, ok, err := h.round.take(betID, userID, time.Now().Add(-latency), crashSeen)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		// 不在本局账本中：区分不存在、不属于当前用户与已结算
+		bet, err := gameService.GetBetByID(betID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if bet.UserID != userID {
+			return nil, nil, service.ErrBetNotOwned
+		}
+		return nil, nil, service.ErrBetNotActive
+	}
+	defer entry.settling.Done()
+
+	if multiplier.GreaterThan(entry.trigger) {
+		multiplier = entry.trigger
+	}
+
+	bet, settlement, err := gameService.CashoutBet(userID, betID, multiplier, fraction, meta)
+	if err != nil {
+		if !errors.Is(err, service.ErrBetNotActive) && !errors.Is(err, gorm.ErrRecordNotFound) && !h.round.restore(entry) {
+			h.crashStale(betID)
+		}
+		return nil, nil, err
+	}
+
+	if bet.Status != 0 {
+		h.round.settle(entry, settlement.Payout)
+	} else if !h.round.partial(entry, settlement.Stake, settlement.Payout) {
+		h.crashStale(betID)
+	}
+	h.broadcastPlayerCashout(bet, settlement)
+	return bet, settlement, nil
+}
+
+// CancelBet 本局开始前撤销下注并全额退回本金，成功后广播撤销消息，REST与WebSocket撤销共用
+// 账本中取出即视为撤销生效，之后开始的本局不再包含该下注
+func (h *Hub) CancelBet(ctx context.Context, userID uint, betID string, meta service.AuditMeta) (*model.Bet, error) {
+	gameService := h.gameService.WithContext(ctx)
+	entry, err := h.round.cancel(betID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		// 不在本局账本中：区分不存在、不属于当前用户与已结算
+		bet, err := gameService.GetBetByID(betID)
+		if err != nil {
+			return nil, err
+		}
+		if bet.UserID != userID {
+			return nil, service.ErrBetNotOwned
+		}
+		return nil, service.ErrBetNotActive
+	}
+
+	bet, err := gameService.CancelBet(userID, betID, meta)
+	if err != nil {
+		if !errors.Is(err, service.ErrBetNotActive) && !errors.Is(err, gorm.ErrRecordNotFound) && !h.round.reinstate(entry) {
+			h.crashStale(betID)
+		}
+		return nil, err
+	}
+
+	h.broadcastPlayerBetCancel(bet)
+	return bet, nil
+}
+
+// crashStale 止盈处理期间本局已崩盘，剩余本金已不在账本中，按崩盘处理
+func (h *Hub) crashStale(betID string) {
+	if err := h.gameService.CrashBets([]string{betID}); err != nil {
+		h.log.WithError(err).WithField(logger.FieldBetID, betID).Error("标记崩盘下注失败")
+	}
+}
+
+// processRoundRisk 每次滴答检查自动止盈、单注盈利上限与单局赔付上限，调用方需持有gameState.mutex
+func (h *Hub) processRoundRisk() {
+	due := h.round.collectDue(h.gameState.CurrentMultiplier)
+	if len(due) > 0 {
+		go h.settleDue(h.gameState.RoundID, due)
+	}
+}
+
+// settleDue 结算系统止盈的下注并广播
+func (h *Hub) settleDue(roundID string, due []dueCashout) {
+	log := h.log.WithField(logger.FieldRoundID, roundID)
+
+	for _, d := range due {
+		bet, settlement, err := h.gameService.SystemCashoutBet(d.bet.betID, d.multiplier, d.action)
+		d.settling.Done()
+		if err != nil {
+			log.WithError(err).WithFields(logrus.Fields{
+				logger.FieldBetID:  d.bet.betID,
+				logger.FieldUserID: d.bet.userID,
+			}).Error("系统止盈失败")
+			continue
+		}
+
+		if d.action == model.AuditActionBetForced {
+			log.WithFields(logrus.Fields{
+				logger.FieldBetID:  bet.BetID,
+				logger.FieldUserID: bet.UserID,
+				"multiplier":       bet.Multiplier.String(),
+			}).Warn("触发单局风控，强制止盈")
+		}
+		h.broadcastPlayerCashout(bet, settlement)
+	}
+}
+
+// crashRound 本局崩盘，重置风控账本开始接受下一局下注，宽限期结束后未止盈的下注标记为崩盘，
+// 等待本局已取出的止盈结算完成后记录游戏历史，调用方需持有gameState.mutex
+// 崩盘下注标记完成后执行自动下注，使其能结算本局结果
+func (h *Hub) crashRound(crashedAt time.Time) {
+	nextRoundID := newRoundID()
+	crashed := h.round.reset(nextRoundID, crashedAt)
+	h.resetRoster(nextRoundID)
+
+	roundID := h.gameState.RoundID
+	gameID := h.gameState.GameID
+	crashPoint := h.gameState.CurrentMultiplier
+	startedAt := h.gameState.startedAt
+	go func() {
+		time.Sleep(cashoutGrace())
+		betIDs := h.round.expire(crashed)
+		crashed.settling.Wait()
+		if err := h.gameService.CrashBets(betIDs); err != nil {
+			h.log.WithError(err).WithField(logger.FieldRoundID, roundID).Error("标记崩盘下注失败")
+		} else if _, err := h.gameService.RecordRound(roundID, gameID, crashPoint, startedAt, crashedAt); err != nil {
+			h.log.WithError(err).WithField(logger.FieldRoundID, roundID).Error("记录游戏历史失败")
+		}
+		h.runAutoBets()
+	}()
+}
+
+// cashoutGrace 止盈宽限期，崩盘前发出的止盈在此时间内到达仍可结算
+func cashoutGrace() time.Duration {
+	return time.Duration(config.AppConfig.Game.CashoutGrace) * time.Millisecond
+}
+
+// cashoutLatencyCap 止盈延迟补偿的单程延迟上限
+func cashoutLatencyCap() time.Duration {
+	return time.Duration(config.AppConfig.Game.CashoutLatencyCap) * time.Millisecond
+}
@@ -0,0 +1,341 @@
+package websocket
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"game-backend/config"
+	"game-backend/internal/service"
+	"game-backend/pkg/money"
+)
+
+// setupRiskConfig 加载单局风控测试所需的配置与币种表
+func setupRiskConfig(t *testing.T, game config.GameConfig) money.Currency {
+	t.Helper()
+
+	previous := config.AppConfig
+	config.AppConfig = &config.Config{Game: game}
+	t.Cleanup(func() { config.AppConfig = previous })
+
+	err := money.Init(config.WalletConfig{
+		BaseCurrency: "CNY",
+		Currencies: []config.CurrencyConfig{
+			{Code: "CNY", Precision: 2, MinBet: 1, MaxBet: 10000, Rate: 1},
+		},
+	})
+	if err != nil {
+		t.Fatalf("初始化币种失败: %v", err)
+	}
+
+	cur, _ := money.Lookup("CNY")
+	return cur
+}
+
+func TestReserveRejectsLockedBook(t *testing.T) {
+	cur := setupRiskConfig(t, config.GameConfig{MaxBetsPerRound: 2, CashoutGrace: 200})
+
+	book := newRoundBook("round_1")
+	start := time.Now()
+	book.lock(start)
+	for i := 1; i <= 20; i++ {
+		book.tick(start.Add(time.Duration(i)*100*time.Millisecond), decimal.NewFromInt(int64(i)))
+	}
+
+	bet := newRoundBet(1, cur, decimal.NewFromInt(10), decimal.Zero, decimal.NewFromInt(1000))
+	if _, err := book.reserve(bet, AutoSlot); !errors.Is(err, ErrBettingClosed) {
+		t.Fatalf("本局已开始时下注应被拒绝，实际 err = %v", err)
+	}
+	if len(book.slots[1]) != 0 || !book.exposure.IsZero() {
+		t.Fatalf("被拒绝的下注不应占用下注位或敞口: slots=%v exposure=%s", book.slots[1], book.exposure)
+	}
+
+	// 崩盘后重新接受下一局的下注
+	book.reset("round_2", start.Add(3*time.Second))
+	roundID, err := book.reserve(bet, AutoSlot)
+	if err != nil || roundID != "round_2" {
+		t.Fatalf("崩盘后应接受下一局下注: roundID=%s err=%v", roundID, err)
+	}
+}
+
+func TestConfirmAfterRolloverRejectsBet(t *testing.T) {
+	cur := setupRiskConfig(t, config.GameConfig{MaxBetsPerRound: 1, CashoutGrace: 200})
+
+	book := newRoundBook("round_1")
+	bet := newRoundBet(1, cur, decimal.NewFromInt(10), decimal.Zero, decimal.NewFromInt(1000))
+	roundID, err := book.reserve(bet, AutoSlot)
+	if err != nil || roundID != "round_1" {
+		t.Fatalf("reserve: roundID=%s err=%v", roundID, err)
+	}
+
+	// 下注写入期间本局开始并崩盘
+	start := time.Now()
+	book.lock(start)
+	crashed := book.reset("round_2", start.Add(time.Second))
+
+	if book.confirm(bet, "bet_1") {
+		t.Fatalf("换局后确认的下注属于已结束的一局，不应计入新一局")
+	}
+	if len(book.bets) != 0 || len(book.slots[1]) != 0 || !book.exposure.IsZero() {
+		t.Fatalf("新一局账本不应包含旧局下注: bets=%d slots=%v exposure=%s", len(book.bets), book.slots[1], book.exposure)
+	}
+	if _, ok := crashed.bets["bet_1"]; ok {
+		t.Fatalf("旧局宽限期账本不应包含该下注")
+	}
+
+	// 玩家在新一局仍可使用同一下注位
+	next := newRoundBet(1, cur, decimal.NewFromInt(10), decimal.Zero, decimal.NewFromInt(1000))
+	if _, err := book.reserve(next, AutoSlot); err != nil {
+		t.Fatalf("新一局下注应不受旧局影响: %v", err)
+	}
+}
+
+func TestTakeAfterCrashRequiresUnseenCrash(t *testing.T) {
+	cur := setupRiskConfig(t, config.GameConfig{MaxBetsPerRound: 2, CashoutGrace: 250, CashoutLatencyCap: 100})
+
+	start := time.Now()
+	crashedAt := start.Add(2 * time.Second)
+
+	tests := []struct {
+		name      string
+		pressedAt time.Time
+		crashSeen time.Time
+		wantErr   error
+	}{
+		{"崩盘前发出且尚未看到崩盘", crashedAt.Add(-50 * time.Millisecond), time.Time{}, nil},
+		{"看到上一局崩盘不影响本局", crashedAt.Add(-50 * time.Millisecond), start.Add(-time.Second), nil},
+		{"发出时已崩盘", crashedAt, time.Time{}, ErrGameNotRunning},
+		{"崩盘消息已写出", crashedAt.Add(-50 * time.Millisecond), crashedAt.Add(30 * time.Millisecond), ErrGameNotRunning},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			book := newRoundBook("round_1")
+			bet := newRoundBet(1, cur, decimal.NewFromInt(10), decimal.Zero, decimal.NewFromInt(1000))
+			if _, err := book.reserve(bet, AutoSlot); err != nil {
+				t.Fatalf("reserve: %v", err)
+			}
+			book.confirm(bet, "bet_1")
+			book.lock(start)
+			book.tick(start.Add(time.Second), decimal.RequireFromString("1.50"))
+			book.tick(crashedAt.Add(-100*time.Millisecond), decimal.RequireFromString("1.90"))
+			book.reset("round_2", crashedAt)
+
+			got, multiplier, ok, err := book.take("bet_1", 1, tt.pressedAt, tt.crashSeen)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if !ok || got != bet || !multiplier.Equal(decimal.RequireFromString("1.90")) {
+				t.Fatalf("take() = %v, %s, %v，应按崩盘前最后一次滴答的倍数结算", got, multiplier, ok)
+			}
+		})
+	}
+}
+
+func TestCrashedRoundWaitsForGraceSettlements(t *testing.T) {
+	cur := setupRiskConfig(t, config.GameConfig{MaxBetsPerRound: 1, CashoutGrace: 250})
+
+	book := newRoundBook("round_1")
+	bet := newRoundBet(1, cur, decimal.NewFromInt(10), decimal.Zero, decimal.NewFromInt(1000))
+	if _, err := book.reserve(bet, AutoSlot); err != nil {
+		t.Fatalf("reserve: %v", err)
+	}
+	book.confirm(bet, "bet_1")
+	start := time.Now()
+	book.lock(start)
+	crashedAt := start.Add(time.Second)
+	crashed := book.reset("round_2", crashedAt)
+
+	// 宽限期内取出崩盘前发出的止盈，结算完成前记录历史需等待
+	got, _, ok, err := book.take("bet_1", 1, crashedAt.Add(-50*time.Millisecond), time.Time{})
+	if err != nil || !ok {
+		t.Fatalf("take: ok=%v err=%v", ok, err)
+	}
+	if betIDs := book.expire(crashed); len(betIDs) != 0 {
+		t.Fatalf("已取出的下注不应按崩盘处理: %v", betIDs)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		crashed.settling.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatalf("止盈结算完成前不应结束等待")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	got.settling.Done()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("止盈结算完成后应结束等待")
+	}
+}
+
+func TestReserve(t *testing.T) {
+	cur := setupRiskConfig(t, config.GameConfig{MaxBetsPerRound: 2, MaxRoundExposure: 50})
+
+	// 下注10、自动止盈2倍，潜在赔付20
+	newBet := func(userID uint) *roundBet {
+		return newRoundBet(userID, cur, decimal.NewFromInt(10), decimal.NewFromInt(2), decimal.NewFromInt(1000))
+	}
+
+	tests := []struct {
+		name     string
+		setup    func(b *roundBook)
+		slot     int
+		wantSlot int
+		wantErr  error
+	}{
+		{"分配最小空闲下注位", func(b *roundBook) { b.reserve(newBet(1), 0) }, AutoSlot, 1, nil},
+		{"指定下注位", nil, 1, 1, nil},
+		{"其他玩家的下注位不影响", func(b *roundBook) { b.reserve(newBet(2), 0) }, AutoSlot, 0, nil},
+		{"下注位超出范围", nil, 2, 0, ErrInvalidBetSlot},
+		{"下注位已占用", func(b *roundBook) { b.reserve(newBet(1), 0) }, 0, 0, ErrBetSlotTaken},
+		{"下注位已满", func(b *roundBook) { b.reserve(newBet(1), 0); b.reserve(newBet(1), 1) }, AutoSlot, 0, ErrBetSlotsFull},
+		{"超出单局敞口", func(b *roundBook) { b.reserve(newBet(2), 0); b.reserve(newBet(2), 1) }, AutoSlot, 0, ErrRoundExposureExceeded},
+		{"本局已开始", func(b *roundBook) { b.lock(time.Now()) }, AutoSlot, 0, ErrBettingClosed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			book := newRoundBook("round_1")
+			if tt.setup != nil {
+				tt.setup(book)
+			}
+			exposure := book.exposure
+
+			bet := newBet(1)
+			roundID, err := book.reserve(bet, tt.slot)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if !book.exposure.Equal(exposure) {
+					t.Fatalf("被拒绝的下注不应占用敞口: exposure %s -> %s", exposure, book.exposure)
+				}
+				return
+			}
+			if roundID != "round_1" || bet.slot != tt.wantSlot || !book.slots[1][tt.wantSlot] {
+				t.Fatalf("reserve() = %s, slot %d, want round_1, slot %d", roundID, bet.slot, tt.wantSlot)
+			}
+			if want := exposure.Add(decimal.NewFromInt(20)); !book.exposure.Equal(want) {
+				t.Fatalf("exposure = %s, want %s", book.exposure, want)
+			}
+		})
+	}
+}
+
+func TestTake(t *testing.T) {
+	cur := setupRiskConfig(t, config.GameConfig{MaxBetsPerRound: 2, CashoutGrace: 250})
+
+	start := time.Now()
+	crashedAt := start.Add(2500 * time.Millisecond)
+
+	tests := []struct {
+		name      string
+		setup     func(b *roundBook)
+		betID     string
+		userID    uint
+		pressedAt time.Time
+		want      string // 结算倍数，为空表示不应取出下注
+		wantOK    bool
+		wantErr   error
+	}{
+		{"按发出时刻的倍数", nil, "bet_1", 1, start.Add(1500 * time.Millisecond), "1.50", true, nil},
+		{"发出后的滴答不计入", nil, "bet_1", 1, start.Add(1999 * time.Millisecond), "1.50", true, nil},
+		{"最近一次滴答", nil, "bet_1", 1, start.Add(2100 * time.Millisecond), "1.90", true, nil},
+		{"不在账本中", nil, "bet_2", 1, start.Add(time.Second), "", false, nil},
+		{"不是本人的下注", nil, "bet_1", 2, start.Add(time.Second), "", true, service.ErrBetNotOwned},
+		{"已取出的下注", func(b *roundBook) { b.take("bet_1", 1, start.Add(time.Second), time.Time{}) },
+			"bet_1", 1, start.Add(time.Second), "", false, nil},
+		{"崩盘宽限期内", func(b *roundBook) { b.reset("round_2", crashedAt) },
+			"bet_1", 1, crashedAt.Add(-100 * time.Millisecond), "1.90", true, nil},
+		{"宽限期已结束", func(b *roundBook) { b.expire(b.reset("round_2", crashedAt)) },
+			"bet_1", 1, crashedAt.Add(-100 * time.Millisecond), "", false, ErrGameNotRunning},
+		{"下一局尚未开始", func(b *roundBook) { b.reset("round_2", crashedAt) },
+			"bet_1", 1, crashedAt.Add(100 * time.Millisecond), "", false, ErrGameNotRunning},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			book := newRoundBook("round_1")
+			bet := newRoundBet(1, cur, decimal.NewFromInt(10), decimal.Zero, decimal.NewFromInt(1000))
+			if _, err := book.reserve(bet, AutoSlot); err != nil {
+				t.Fatalf("reserve: %v", err)
+			}
+			book.confirm(bet, "bet_1")
+			book.lock(start)
+			book.tick(start.Add(time.Second), decimal.RequireFromString("1.50"))
+			book.tick(start.Add(2*time.Second), decimal.RequireFromString("1.90"))
+			if tt.setup != nil {
+				tt.setup(book)
+			}
+
+			got, multiplier, ok, err := book.take(tt.betID, tt.userID, tt.pressedAt, time.Time{})
+			if !errors.Is(err, tt.wantErr) || ok != tt.wantOK {
+				t.Fatalf("take() ok = %v, err = %v, want ok = %v, err = %v", ok, err, tt.wantOK, tt.wantErr)
+			}
+			if tt.want == "" {
+				if got != nil {
+					t.Fatalf("take() 不应取出下注")
+				}
+				return
+			}
+			if got != bet || !multiplier.Equal(decimal.RequireFromString(tt.want)) {
+				t.Fatalf("take() = %v, %s, want bet_1, %s", got, multiplier, tt.want)
+			}
+		})
+	}
+}
+
+func TestCancel(t *testing.T) {
+	cur := setupRiskConfig(t, config.GameConfig{MaxBetsPerRound: 2})
+
+	tests := []struct {
+		name         string
+		locked       bool
+		betID        string
+		userID       uint
+		wantBet      bool
+		wantErr      error
+		wantExposure int64
+	}{
+		{"撤销并释放敞口", false, "bet_1", 1, true, nil, 0},
+		{"本局已开始", true, "bet_1", 1, false, ErrBettingClosed, 20},
+		{"不是本人的下注", false, "bet_1", 2, false, service.ErrBetNotOwned, 20},
+		{"不在账本中", false, "bet_2", 1, false, nil, 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			book := newRoundBook("round_1")
+			bet := newRoundBet(1, cur, decimal.NewFromInt(10), decimal.NewFromInt(2), decimal.NewFromInt(1000))
+			if _, err := book.reserve(bet, AutoSlot); err != nil {
+				t.Fatalf("reserve: %v", err)
+			}
+			book.confirm(bet, "bet_1")
+			if tt.locked {
+				book.lock(time.Now())
+			}
+
+			got, err := book.cancel(tt.betID, tt.userID)
+			if !errors.Is(err, tt.wantErr) || (got != nil) != tt.wantBet {
+				t.Fatalf("cancel() = %v, %v, want bet = %v, err = %v", got, err, tt.wantBet, tt.wantErr)
+			}
+			if !book.exposure.Equal(decimal.NewFromInt(tt.wantExposure)) {
+				t.Fatalf("exposure = %s, want %d", book.exposure, tt.wantExposure)
+			}
+			// 撤销后下注位本局仍被占用
+			if !book.slots[1][0] {
+				t.Fatalf("撤销不应释放下注位")
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package websocket
+
+import (
+	"sync"
+	"time"
+
+	"game-backend/internal/model"
+	"game-backend/pkg/logger"
+	"game-backend/pkg/money"
+	"game-backend/proto"
+)
+
+// roundRoster 本局下注列表，下注、止盈与撤销时更新并广播增量
+// 增量广播与快照发送都在持有mutex时进行，客户端收到的快照与增量顺序与广播序号一致
+type roundRoster struct {
+	mutex   sync.Mutex
+	roundID string
+	bets    map[string]*proto.RoundBet
+	order   []string // 下注顺序
+}
+
+// newRoundRoster 创建本局下注列表
+func newRoundRoster(roundID string) *roundRoster {
+	return &roundRoster{
+		roundID: roundID,
+		bets:    make(map[string]*proto.RoundBet),
+	}
+}
+
+// snapshotLocked 生成下注列表快照，seq为当前广播序号，调用方需持有r.mutex
+func (r *roundRoster) snapshotLocked(seq int64) *proto.RoundSnapshot {
+	bets := make([]*proto.RoundBet, 0, len(r.order))
+	for _, betID := range r.order {
+		view := *r.bets[betID]
+		bets = append(bets, &view)
+	}
+
+	return &proto.RoundSnapshot{
+		RoundId:   r.roundID,
+		Seq:       seq,
+		Bets:      bets,
+		Timestamp: time.Now().Unix(),
+	}
+}
+
+// playersLocked 本局有下注的玩家，调用方需持有r.mutex
+func (r *roundRoster) playersLocked() map[uint]bool {
+	players := make(map[uint]bool)
+	for _, bet := range r.bets {
+		players[uint(bet.UserId)] = true
+	}
+	return players
+}
+
+// removeLocked 从下注列表中移除，调用方需持有r.mutex
+func (r *roundRoster) removeLocked(betID string) {
+	if _, ok := r.bets[betID]; !ok {
+		return
+	}
+	delete(r.bets, betID)
+	for i, id := range r.order {
+		if id == betID {
+			r.order = append(r.order[:i], r.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// RoundSnapshot 获取本局下注列表快照
+func (h *Hub) RoundSnapshot() *proto.RoundSnapshot {
+	h.roster.mutex.Lock()
+	defer h.roster.mutex.Unlock()
+	h.events.mutex.Lock()
+	defer h.events.mutex.Unlock()
+
+	return h.roster.snapshotLocked(h.events.seq)
+}
+
+// resetRoster 本局结束，清空下注列表并广播下一局的空快照
+func (h *Hub) resetRoster(nextRoundID string) {
+	h.roster.mutex.Lock()
+	defer h.roster.mutex.Unlock()
+
+	h.roster.roundID = nextRoundID
+	h.roster.bets = make(map[string]*proto.RoundBet)
+	h.roster.order = nil
+
+	snapshot := h.roster.snapshotLocked(0)
+	if err := h.publish(RoundSnapshot, snapshot, &snapshot.Seq); err != nil {
+		h.log.WithError(err).WithField(logger.FieldRoundID, nextRoundID).Error("编码下注列表快照失败")
+	}
+}
+
+// username 获取下注列表展示的用户名，优先取在线连接中的用户名
+func (h *Hub) username(userID uint) string {
+	h.mutex.RLock()
+	for client := range h.clients {
+		if client.userID == userID && client.username != "" {
+			h.mutex.RUnlock()
+			return client.username
+		}
+	}
+	h.mutex.RUnlock()
+
+	user, err := h.gameService.GetUserByID(userID)
+	if err != nil {
+		h.log.WithError(err).WithField(logger.FieldUserID, userID).Warn("获取用户名失败")
+		return ""
+	}
+	return user.Username
+}
+
+// broadcastPlayerBet 将下注加入本局下注列表并广播下注消息
+func (h *Hub) broadcastPlayerBet(bet *model.Bet) {
+	cur, _ := money.Lookup(bet.Currency)
+	view := &proto.RoundBet{
+		BetId:       bet.BetID,
+		UserId:      int64(bet.UserID),
+		Username:    h.username(bet.UserID),
+		Slot:        int32(bet.Slot),
+		Currency:    bet.Currency,
+		Amount:      cur.Format(bet.Amount),
+		AutoCashout: bet.AutoCashout.StringFixed(money.MultiplierScale),
+		Remaining:   cur.Format(bet.RemainingAmount),
+		Payout:      cur.Format(bet.Payout),
+	}
+
+	h.roster.mutex.Lock()
+	defer h.roster.mutex.Unlock()
+
+	if _, ok := h.roster.bets[bet.BetID]; !ok {
+		h.roster.bets[bet.BetID] = view
+		h.roster.order = append(h.roster.order, bet.BetID)
+	}
+
+	playerBet := &proto.PlayerBet{
+		BetId:       bet.BetID,
+		UserId:      int64(bet.UserID),
+		Slot:        int32(bet.Slot),
+		Amount:      view.Amount,
+		AutoCashout: view.AutoCashout,
+		Timestamp:   time.Now().Unix(),
+		Currency:    bet.Currency,
+	}
+
+	if err := h.publish(PlayerBet, playerBet, &playerBet.Seq); err != nil {
+		h.log.WithError(err).WithField(logger.FieldBetID, bet.BetID).Error("编码下注消息失败")
+	}
+}
+
+// broadcastPlayerBetCancel 将下注移出本局下注列表并广播撤销下注消息
+func (h *Hub) broadcastPlayerBetCancel(bet *model.Bet) {
+	cur, _ := money.Lookup(bet.Currency)
+
+	h.roster.mutex.Lock()
+	defer h.roster.mutex.Unlock()
+
+	h.roster.removeLocked(bet.BetID)
+
+	cancel := &proto.PlayerBetCancel{
+		BetId:     bet.BetID,
+		UserId:    int64(bet.UserID),
+		Slot:      int32(bet.Slot),
+		Amount:    cur.Format(bet.Amount),
+		Currency:  bet.Currency,
+		Timestamp: time.Now().Unix(),
+	}
+
+	if err := h.publish(PlayerBetCancel, cancel, &cancel.Seq); err != nil {
+		h.log.WithError(err).WithField(logger.FieldBetID, bet.BetID).Error("编码撤销下注消息失败")
+	}
+}
+
+// broadcastPlayerCashout 更新本局下注列表中的止盈状态并广播一次止盈结算，部分止盈时remaining大于0
+func (h *Hub) broadcastPlayerCashout(bet *model.Bet, settlement *model.BetSettlement) {
+	cur, _ := money.Lookup(bet.Currency)
+	multiplier := settlement.Multiplier.StringFixed(money.MultiplierScale)
+
+	h.roster.mutex.Lock()
+	defer h.roster.mutex.Unlock()
+
+	if view, ok := h.roster.bets[bet.BetID]; ok {
+		view.Remaining = cur.Format(bet.RemainingAmount)
+		view.Payout = cur.Format(bet.Payout)
+		view.Multiplier = multiplier
+		view.Status = int32(bet.Status)
+	}
+
+	playerCashout := &proto.PlayerCashout{
+		BetId:      bet.BetID,
+		UserId:     int64(bet.UserID),
+		Slot:       int32(bet.Slot),
+		Multiplier: multiplier,
+		Payout:     cur.Format(settlement.Payout),
+		Timestamp:  time.Now().Unix(),
+		Currency:   bet.Currency,
+		Stake:      cur.Format(settlement.Stake),
+		Remaining:  cur.Format(bet.RemainingAmount),
+	}
+
+	if err := h.publish(PlayerCashout, playerCashout, &playerCashout.Seq); err != nil {
+		h.log.WithError(err).WithField(logger.FieldBetID, bet.BetID).Error("编码止盈消息失败")
+	}
+}
@@ -0,0 +1,76 @@
+package websocket
+
+import (
+	"game-backend/config"
+	"game-backend/pkg/money"
+	"game-backend/proto"
+)
+
+// maxTickEvery 发送队列积压时滴答发送间隔的上限（每maxTickEvery次滴答发送一次）
+const maxTickEvery = 8
+
+// tickThrottle 按发送队列积压调整客户端的滴答频率，仅由游戏循环访问
+type tickThrottle struct {
+	every int // 每every次滴答发送一次，0视为1
+	count int
+}
+
+// allow 判断本次滴答是否发送给该客户端：队列积压超过容量1/4时发送间隔加倍，队列清空后逐步恢复
+func (t *tickThrottle) allow(queued, capacity int) bool {
+	if t.every < 1 {
+		t.every = 1
+	}
+	switch {
+	case queued*4 > capacity && t.every < maxTickEvery:
+		t.every *= 2
+	case queued == 0 && t.every > 1:
+		t.every /= 2
+	}
+
+	t.count++
+	if t.count < t.every {
+		return false
+	}
+	t.count = 0
+	return true
+}
+
+// broadcastStatus 广播本次滴答的游戏状态，调用方需持有gameState.mutex
+// 状态变化或距上次关键帧已满keyframe_interval次滴答时广播完整的GameStatusUpdate，其余进行中的滴答只广播GameTick
+func (h *Hub) broadcastStatus(changed bool) {
+	h.gameState.sinceKeyframe++
+	if changed || h.gameState.sinceKeyframe >= config.AppConfig.WebSocket.KeyframeInterval {
+		h.gameState.sinceKeyframe = 0
+		h.refreshPresenceCounts()
+		h.broadcastGameStatusUpdate()
+		return
+	}
+
+	// 等待阶段倍数不变，只按关键帧间隔更新倒计时
+	if h.gameState.Status == 1 {
+		h.broadcastTick()
+	}
+}
+
+// broadcastTick 向各客户端发送GameTick，发送队列积压的客户端降低滴答频率；滴答不写入事件缓冲区，断线补发以关键帧为准
+func (h *Hub) broadcastTick() {
+	tick := &proto.GameTick{
+		Multiplier: h.gameState.CurrentMultiplier.StringFixed(money.MultiplierScale),
+		ElapsedMs:  h.gameState.LastUpdateMs - h.gameState.startedAt.UnixMilli(),
+	}
+
+	message, err := h.encodeMessage(GameTick, tick)
+	if err != nil {
+		h.log.WithError(err).Error("编码滴答消息失败")
+		return
+	}
+
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	for client := range h.clients {
+		if client.ticks.allow(len(client.send), cap(client.send)) {
+			h.trySend(client, message)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"game-backend/pkg/logger"
+	"game-backend/pkg/metrics"
+	"game-backend/proto"
+)
+
+// rttTracker 连接往返时间，由服务端发出的时间同步ping及客户端的pong测得
+type rttTracker struct {
+	mutex    sync.Mutex
+	pingID   int64         // 最近一次ping的序号
+	pingSent time.Time     // 最近一次ping的发送时间，收到对应pong后清零
+	smoothed time.Duration // 平滑RTT，0表示尚未测得
+	min      time.Duration // 最小RTT样本，客户端延迟回应pong只能抬高样本，不能压低
+}
+
+// begin 分配下一次ping的序号并记录发送时间
+func (t *rttTracker) begin(now time.Time) int64 {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.pingID++
+	t.pingSent = now
+	return t.pingID
+}
+
+// observe 收到pong时计算本次RTT并更新平滑RTT（旧值7/8 + 新样本1/8），序号不是最近一次ping或已收到过时忽略
+func (t *rttTracker) observe(id int64, now time.Time) (time.Duration, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if id != t.pingID || t.pingSent.IsZero() {
+		return 0, false
+	}
+
+	sample := now.Sub(t.pingSent)
+	t.pingSent = time.Time{}
+	if t.min == 0 || sample < t.min {
+		t.min = sample
+	}
+	if t.smoothed == 0 {
+		t.smoothed = sample
+	} else {
+		t.smoothed = (t.smoothed*7 + sample) / 8
+	}
+	return sample, true
+}
+
+// current 返回平滑RTT
+func (t *rttTracker) current() time.Duration {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.smoothed
+}
+
+// minimum 返回最小RTT样本
+func (t *rttTracker) minimum() time.Duration {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.min
+}
+
+// oneWayLatency 止盈延迟补偿使用的单程延迟：最小RTT样本的一半，不超过cashout_latency_cap
+func (c *Client) oneWayLatency() time.Duration {
+	latency := c.rtt.minimum() / 2
+	if limit := cashoutLatencyCap(); latency > limit {
+		latency = limit
+	}
+	return latency
+}
+
+// writeTimeSyncPing 在writePump中直接写入连接，避免发送队列的排队时间计入RTT
+func (c *Client) writeTimeSyncPing(hub *Hub) error {
+	now := time.Now()
+	ping := &proto.TimeSyncPing{
+		Id:         c.rtt.begin(now),
+		ServerTime: now.UnixMilli(),
+	}
+
+	message, err := hub.encodeMessage(TimeSyncPing, ping)
+	if err != nil {
+		c.log.WithError(err).Error("编码时间同步消息失败")
+		return nil
+	}
+
+	c.conn.SetWriteDeadline(now.Add(10 * time.Second))
+	c.conn.EnableWriteCompression(false)
+	return c.conn.WriteMessage(websocket.TextMessage, message)
+}
+
+// handleTimeSyncPing 回应客户端的时间同步ping，客户端据此计算RTT与时钟偏移
+func (c *Client) handleTimeSyncPing(ctx context.Context, payload []byte, hub *Hub) {
+	log := logger.FromContext(ctx)
+	received := time.Now()
+
+	var ping proto.TimeSyncPing
+	if err := json.Unmarshal(payload, &ping); err != nil {
+		log.WithError(err).Warn("解析时间同步请求失败")
+		c.sendErrorMessage("时间同步请求格式错误", hub)
+		return
+	}
+
+	pong := &proto.TimeSyncPong{
+		Id:         ping.Id,
+		ClientTime: ping.ClientTime,
+		ServerTime: received.UnixMilli(),
+		RttMs:      c.rtt.current().Milliseconds(),
+	}
+
+	message, err := hub.encodeMessage(TimeSyncPong, pong)
+	if err != nil {
+		log.WithError(err).Error("编码时间同步消息失败")
+		return
+	}
+
+	hub.sendToClient(c, message)
+}
+
+// handleTimeSyncPong 处理客户端对服务端ping的回应，更新连接RTT
+func (c *Client) handleTimeSyncPong(ctx context.Context, payload []byte) {
+	received := time.Now()
+
+	var pong proto.TimeSyncPong
+	if err := json.Unmarshal(payload, &pong); err != nil {
+		logger.FromContext(ctx).WithError(err).Warn("解析时间同步回应失败")
+		return
+	}
+
+	if sample, ok := c.rtt.observe(pong.Id, received); ok {
+		metrics.WSRoundTrip.Observe(sample.Seconds())
+	}
+}
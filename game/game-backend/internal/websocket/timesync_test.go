@@ -0,0 +1,38 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+
+	"game-backend/config"
+)
+
+func TestOneWayLatencyIgnoresDelayedPongs(t *testing.T) {
+	setupRiskConfig(t, config.GameConfig{CashoutGrace: 250, CashoutLatencyCap: 100})
+
+	tests := []struct {
+		name    string
+		samples []time.Duration
+		want    time.Duration
+	}{
+		{"尚未测得", nil, 0},
+		{"正常样本", []time.Duration{60 * time.Millisecond, 80 * time.Millisecond}, 30 * time.Millisecond},
+		{"延迟回应pong不抬高补偿", []time.Duration{40 * time.Millisecond, 480 * time.Millisecond, 490 * time.Millisecond, 500 * time.Millisecond}, 20 * time.Millisecond},
+		{"补偿不超过上限", []time.Duration{480 * time.Millisecond, 500 * time.Millisecond}, 100 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{}
+			now := time.Now()
+			for _, sample := range tt.samples {
+				id := c.rtt.begin(now)
+				now = now.Add(sample)
+				c.rtt.observe(id, now)
+			}
+			if got := c.oneWayLatency(); got != tt.want {
+				t.Fatalf("oneWayLatency() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+	"game-backend/pkg/logger"
+)
+
+// slowQueryThreshold 慢查询阈值
+const slowQueryThreshold = 200 * time.Millisecond
+
+// gormLogger 将GORM日志输出到logrus，并携带上下文中的请求字段
+type gormLogger struct {
+	level gormlogger.LogLevel
+}
+
+// newGormLogger 创建GORM日志适配器
+func newGormLogger() gormlogger.Interface {
+	level := gormlogger.Warn
+	if logrus.IsLevelEnabled(logrus.DebugLevel) {
+		level = gormlogger.Info
+	}
+	return &gormLogger{level: level}
+}
+
+// LogMode 设置日志级别
+func (l *gormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	clone := *l
+	clone.level = level
+	return &clone
+}
+
+// Info 输出信息日志
+func (l *gormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Info {
+		logger.FromContext(ctx).Infof(msg, args...)
+	}
+}
+
+// Warn 输出警告日志
+func (l *gormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Warn {
+		logger.FromContext(ctx).Warnf(msg, args...)
+	}
+}
+
+// Error 输出错误日志
+func (l *gormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Error {
+		logger.FromContext(ctx).Errorf(msg, args...)
+	}
+}
+
+// Trace 输出SQL执行日志
+func (l *gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.level <= gormlogger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	sql, rows := fc()
+	entry := logger.FromContext(ctx).WithFields(logrus.Fields{
+		"sql":        sql,
+		"rows":       rows,
+		"elapsed_ms": float64(elapsed.Microseconds()) / 1000,
+	})
+
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && l.level >= gormlogger.Error:
+		entry.WithError(err).Error("SQL执行失败")
+	case elapsed > slowQueryThreshold && l.level >= gormlogger.Warn:
+		entry.Warn("慢查询")
+	case l.level >= gormlogger.Info:
+		entry.Debug("SQL")
+	}
+}
@@ -0,0 +1,458 @@
+package database
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+//go:embed migrations/*.sql
+var migrationFS embed.FS
+
+const (
+	// migrationLockName 迁移advisory锁名称，保证多实例同时启动时只有一个执行迁移
+	migrationLockName = "crash_game.schema_migrations"
+	// migrationLockTimeout 等待迁移锁的最长时间（秒）
+	migrationLockTimeout = 60
+)
+
+// migrationFileRe 迁移文件名格式：0001_init.up.sql / 0001_init.down.sql
+var migrationFileRe = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// ErrDirtyMigration 上次迁移中途失败，需要人工修复后执行 migrate force
+var ErrDirtyMigration = errors.New("数据库迁移处于dirty状态，请修复后执行 migrate force <version>")
+
+// Migration 单个版本迁移
+type Migration struct {
+	Version uint
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus 迁移状态
+type MigrationStatus struct {
+	Version   uint       `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	Dirty     bool       `json:"dirty"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// schemaMigration schema_migrations表记录
+type schemaMigration struct {
+	Version   uint      `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"size:100;not null"`
+	Dirty     bool      `gorm:"not null;default:false"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Migrator 版本化迁移执行器
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+	log        *logrus.Entry
+}
+
+// NewMigrator 创建迁移执行器，加载内嵌的迁移文件
+func NewMigrator(db *gorm.DB) (*Migrator, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		db:         db,
+		migrations: migrations,
+		log:        logrus.WithField("component", "migrator"),
+	}, nil
+}
+
+// embeddedLatest 内嵌迁移的最新版本，进程内只解析一次
+var embeddedLatest struct {
+	once    sync.Once
+	version uint
+	err     error
+}
+
+// LatestMigrationVersion 返回内嵌迁移的最新版本，首次调用时解析迁移文件
+func LatestMigrationVersion() (uint, error) {
+	embeddedLatest.once.Do(func() {
+		migrations, err := loadMigrations()
+		if err != nil {
+			embeddedLatest.err = err
+			return
+		}
+		if len(migrations) > 0 {
+			embeddedLatest.version = migrations[len(migrations)-1].Version
+		}
+	})
+	return embeddedLatest.version, embeddedLatest.err
+}
+
+// LatestVersion 内嵌迁移的最新版本
+func (m *Migrator) LatestVersion() uint {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up 执行所有未应用的迁移
+func (m *Migrator) Up() error {
+	return m.To(m.LatestVersion())
+}
+
+// Down 回滚最近的steps个迁移
+func (m *Migrator) Down(steps int) error {
+	return m.withLock(func(conn *gorm.DB) error {
+		applied, err := m.applied(conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && steps > 0; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+			if err := m.runDown(conn, mig); err != nil {
+				return err
+			}
+			steps--
+		}
+		return nil
+	})
+}
+
+// To 迁移到指定版本：高于当前版本时执行up，低于时执行down
+func (m *Migrator) To(version uint) error {
+	if version != 0 && m.find(version) == nil {
+		return fmt.Errorf("迁移版本不存在: %d", version)
+	}
+
+	return m.withLock(func(conn *gorm.DB) error {
+		applied, err := m.applied(conn)
+		if err != nil {
+			return err
+		}
+
+		// 回滚高于目标版本的迁移
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mig := m.migrations[i]
+			if mig.Version <= version {
+				break
+			}
+			if _, ok := applied[mig.Version]; ok {
+				if err := m.runDown(conn, mig); err != nil {
+					return err
+				}
+			}
+		}
+
+		// 应用不高于目标版本的未执行迁移
+		for _, mig := range m.migrations {
+			if mig.Version > version {
+				break
+			}
+			if _, ok := applied[mig.Version]; !ok {
+				if err := m.runUp(conn, mig); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Force 将指定版本标记为已成功应用并清除dirty状态，不执行SQL
+// 用于迁移中途失败并人工修复表结构之后
+func (m *Migrator) Force(version uint) error {
+	mig := m.find(version)
+	if mig == nil {
+		return fmt.Errorf("迁移版本不存在: %d", version)
+	}
+
+	if err := m.ensureTable(m.db); err != nil {
+		return err
+	}
+
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("dirty = ?", true).Delete(&schemaMigration{}).Error; err != nil {
+			return err
+		}
+		return tx.Save(&schemaMigration{
+			Version:   mig.Version,
+			Name:      mig.Name,
+			AppliedAt: time.Now(),
+		}).Error
+	})
+}
+
+// Status 获取所有迁移的应用状态
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	if err := m.ensureTable(m.db); err != nil {
+		return nil, err
+	}
+
+	var records []schemaMigration
+	if err := m.db.Find(&records).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[uint]schemaMigration, len(records))
+	for _, r := range records {
+		applied[r.Version] = r
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		status := MigrationStatus{Version: mig.Version, Name: mig.Name}
+		if r, ok := applied[mig.Version]; ok {
+			appliedAt := r.AppliedAt
+			status.Applied = !r.Dirty
+			status.Dirty = r.Dirty
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// Check 检查数据库是否已迁移到最新版本
+func (m *Migrator) Check(ctx context.Context) error {
+	return checkSchemaVersion(ctx, m.db, m.LatestVersion())
+}
+
+// checkSchemaVersion 只查询schema_migrations中的当前版本与dirty标记，与latest比较
+func checkSchemaVersion(ctx context.Context, db *gorm.DB, latest uint) error {
+	var state struct {
+		Current      uint
+		DirtyVersion uint
+	}
+	err := db.WithContext(ctx).Model(&schemaMigration{}).
+		Select("COALESCE(MAX(version), 0) AS current, COALESCE(MAX(CASE WHEN dirty THEN version END), 0) AS dirty_version").
+		Scan(&state).Error
+	if err != nil {
+		return fmt.Errorf("读取迁移记录失败: %v", err)
+	}
+
+	if state.DirtyVersion > 0 {
+		return fmt.Errorf("迁移版本 %d 处于dirty状态", state.DirtyVersion)
+	}
+	if state.Current < latest {
+		return fmt.Errorf("数据库版本 %d 落后于最新版本 %d", state.Current, latest)
+	}
+	return nil
+}
+
+// withLock 在同一连接上持有advisory锁执行迁移
+func (m *Migrator) withLock(fn func(conn *gorm.DB) error) error {
+	return m.db.Connection(func(conn *gorm.DB) error {
+		var locked int
+		if err := conn.Raw("SELECT GET_LOCK(?, ?)", migrationLockName, migrationLockTimeout).Scan(&locked).Error; err != nil {
+			return fmt.Errorf("获取迁移锁失败: %v", err)
+		}
+		if locked != 1 {
+			return fmt.Errorf("获取迁移锁超时")
+		}
+		defer conn.Exec("SELECT RELEASE_LOCK(?)", migrationLockName)
+
+		if err := m.ensureTable(conn); err != nil {
+			return err
+		}
+		return fn(conn)
+	})
+}
+
+// ensureTable 创建schema_migrations表
+func (m *Migrator) ensureTable(conn *gorm.DB) error {
+	return conn.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+    version BIGINT UNSIGNED PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    dirty TINYINT(1) NOT NULL DEFAULT 0,
+    applied_at DATETIME NOT NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`).Error
+}
+
+// applied 获取已应用的迁移版本，存在dirty记录时返回ErrDirtyMigration
+func (m *Migrator) applied(conn *gorm.DB) (map[uint]struct{}, error) {
+	var records []schemaMigration
+	if err := conn.Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	applied := make(map[uint]struct{}, len(records))
+	for _, r := range records {
+		if r.Dirty {
+			return nil, fmt.Errorf("%w (version=%d)", ErrDirtyMigration, r.Version)
+		}
+		applied[r.Version] = struct{}{}
+	}
+	return applied, nil
+}
+
+// runUp 执行单个up迁移
+// MySQL的DDL会隐式提交，无法放入事务，因此先写入dirty记录，全部语句成功后再清除
+func (m *Migrator) runUp(conn *gorm.DB, mig Migration) error {
+	start := time.Now()
+
+	record := &schemaMigration{Version: mig.Version, Name: mig.Name, Dirty: true, AppliedAt: start}
+	if err := conn.Create(record).Error; err != nil {
+		return fmt.Errorf("写入迁移记录失败: %v", err)
+	}
+
+	if err := execStatements(conn, mig.Up); err != nil {
+		return fmt.Errorf("执行迁移 %04d_%s 失败: %v", mig.Version, mig.Name, err)
+	}
+
+	if err := conn.Model(record).Updates(map[string]interface{}{
+		"dirty":      false,
+		"applied_at": time.Now(),
+	}).Error; err != nil {
+		return fmt.Errorf("更新迁移记录失败: %v", err)
+	}
+
+	m.log.WithFields(logrus.Fields{
+		"version":     mig.Version,
+		"name":        mig.Name,
+		"duration_ms": time.Since(start).Milliseconds(),
+	}).Info("迁移已应用")
+	return nil
+}
+
+// runDown 执行单个down迁移
+func (m *Migrator) runDown(conn *gorm.DB, mig Migration) error {
+	start := time.Now()
+
+	if err := conn.Model(&schemaMigration{Version: mig.Version}).Update("dirty", true).Error; err != nil {
+		return fmt.Errorf("更新迁移记录失败: %v", err)
+	}
+
+	if err := execStatements(conn, mig.Down); err != nil {
+		return fmt.Errorf("回滚迁移 %04d_%s 失败: %v", mig.Version, mig.Name, err)
+	}
+
+	if err := conn.Delete(&schemaMigration{Version: mig.Version}).Error; err != nil {
+		return fmt.Errorf("删除迁移记录失败: %v", err)
+	}
+
+	m.log.WithFields(logrus.Fields{
+		"version":     mig.Version,
+		"name":        mig.Name,
+		"duration_ms": time.Since(start).Milliseconds(),
+	}).Info("迁移已回滚")
+	return nil
+}
+
+// find 按版本查找迁移
+func (m *Migrator) find(version uint) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+// loadMigrations 加载内嵌的迁移文件，每个版本必须同时有up和down
+func loadMigrations() ([]Migration, error) {
+	entries, err := migrationFS.ReadDir("migrations")
+	if err != nil {
+		return nil, fmt.Errorf("读取迁移文件失败: %v", err)
+	}
+
+	byVersion := make(map[uint]*Migration)
+	for _, entry := range entries {
+		matches := migrationFileRe.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			return nil, fmt.Errorf("迁移文件名格式错误: %s", entry.Name())
+		}
+
+		version, _ := strconv.ParseUint(matches[1], 10, 64)
+		content, err := migrationFS.ReadFile(path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("读取迁移文件失败: %v", err)
+		}
+
+		mig, ok := byVersion[uint(version)]
+		if !ok {
+			mig = &Migration{Version: uint(version), Name: matches[2]}
+			byVersion[uint(version)] = mig
+		} else if mig.Name != matches[2] {
+			return nil, fmt.Errorf("迁移版本 %d 名称不一致: %s / %s", version, mig.Name, matches[2])
+		}
+
+		if matches[3] == "up" {
+			mig.Up = string(content)
+		} else {
+			mig.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" || mig.Down == "" {
+			return nil, fmt.Errorf("迁移版本 %d 缺少up或down文件", mig.Version)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// execStatements 逐条执行迁移SQL
+// 语句以行尾分号分隔，不支持存储过程等含BEGIN...END复合语句的定义
+func execStatements(conn *gorm.DB, script string) error {
+	for _, stmt := range splitStatements(script) {
+		if err := conn.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitStatements 按行尾分号拆分SQL脚本，忽略注释行
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		current.WriteString(line)
+		current.WriteString("\n")
+
+		if strings.HasSuffix(trimmed, ";") {
+			stmt := strings.TrimSuffix(strings.TrimSpace(current.String()), ";")
+			statements = append(statements, stmt)
+			current.Reset()
+		}
+	}
+
+	if stmt := strings.TrimSpace(current.String()); stmt != "" {
+		statements = append(statements, stmt)
+	}
+	return statements
+}
@@ -0,0 +1,9 @@
+-- 回滚初始表结构
+
+DROP TABLE IF EXISTS leaderboard;
+DROP TABLE IF EXISTS game_history;
+DROP TABLE IF EXISTS bets;
+DROP TABLE IF EXISTS games;
+DROP TABLE IF EXISTS user_sessions;
+DROP TABLE IF EXISTS user_stats;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,6 @@
+-- 回滚审计日志与哈希链
+
+DROP TRIGGER IF EXISTS audit_logs_no_delete;
+DROP TRIGGER IF EXISTS audit_logs_no_update;
+DROP TABLE IF EXISTS audit_chain_head;
+DROP TABLE IF EXISTS audit_logs;
@@ -0,0 +1,39 @@
+-- 审计日志与哈希链
+
+-- 创建审计日志表（只追加）
+CREATE TABLE IF NOT EXISTS audit_logs (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    actor_id BIGINT UNSIGNED DEFAULT 0,
+    actor_type VARCHAR(20) NOT NULL COMMENT 'user, admin, system',
+    action VARCHAR(50) NOT NULL,
+    target_type VARCHAR(50),
+    target_id VARCHAR(100),
+    reason VARCHAR(255),
+    before_value TEXT,
+    after_value TEXT,
+    request_id VARCHAR(64),
+    client_ip VARCHAR(45),
+    prev_hash VARCHAR(64) NOT NULL,
+    hash VARCHAR(64) NOT NULL UNIQUE,
+    created_at DATETIME(3) NOT NULL,
+    INDEX idx_actor_id (actor_id),
+    INDEX idx_action (action),
+    INDEX idx_target_id (target_id),
+    INDEX idx_request_id (request_id),
+    INDEX idx_created_at (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- 创建审计哈希链头表
+CREATE TABLE IF NOT EXISTS audit_chain_head (
+    id INT UNSIGNED PRIMARY KEY,
+    last_id BIGINT UNSIGNED DEFAULT 0,
+    last_hash VARCHAR(64) NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- 禁止修改和删除审计日志
+CREATE TRIGGER audit_logs_no_update BEFORE UPDATE ON audit_logs
+FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_logs is append-only';
+
+CREATE TRIGGER audit_logs_no_delete BEFORE DELETE ON audit_logs
+FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_logs is append-only';
@@ -0,0 +1,24 @@
+-- 回滚多币种钱包，仅保留 CNY 余额
+
+ALTER TABLE user_stats
+    DROP INDEX idx_user_stats_user_currency;
+
+DELETE FROM user_stats WHERE currency <> 'CNY';
+
+ALTER TABLE user_stats
+    DROP COLUMN currency,
+    MODIFY total_winnings DECIMAL(15,2) DEFAULT 0.00,
+    ADD UNIQUE KEY uk_user_id (user_id);
+
+ALTER TABLE bets
+    DROP COLUMN currency,
+    MODIFY amount DECIMAL(15,2) NOT NULL,
+    MODIFY payout DECIMAL(15,2) DEFAULT 0.00;
+
+ALTER TABLE users ADD COLUMN balance DECIMAL(15,2) DEFAULT 0.00 AFTER email;
+
+UPDATE users u JOIN wallets w ON w.user_id = u.id AND w.currency = 'CNY'
+SET u.balance = w.balance;
+
+DROP TABLE IF EXISTS wallet_transactions;
+DROP TABLE IF EXISTS wallets;
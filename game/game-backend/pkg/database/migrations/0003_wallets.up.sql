@@ -0,0 +1,53 @@
+-- 多币种钱包与钱包流水
+-- 原 users.balance 没有币种，按默认基准币种 CNY 迁入钱包
+
+-- 创建钱包表
+CREATE TABLE IF NOT EXISTS wallets (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT UNSIGNED NOT NULL,
+    currency VARCHAR(10) NOT NULL,
+    balance DECIMAL(30,8) DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY idx_wallet_user_currency (user_id, currency)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- 创建钱包流水表（只追加）
+CREATE TABLE IF NOT EXISTS wallet_transactions (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    wallet_id BIGINT UNSIGNED NOT NULL,
+    user_id BIGINT UNSIGNED NOT NULL,
+    currency VARCHAR(10) NOT NULL,
+    type VARCHAR(30) NOT NULL,
+    amount DECIMAL(30,8) NOT NULL COMMENT '正数入账，负数出账',
+    balance_after DECIMAL(30,8) NOT NULL,
+    ref_type VARCHAR(30),
+    ref_id VARCHAR(100),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_wallet_id (wallet_id),
+    INDEX idx_wallet_tx_user_currency (user_id, currency),
+    INDEX idx_ref_id (ref_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- 迁移原有余额
+INSERT INTO wallets (user_id, currency, balance)
+SELECT id, 'CNY', balance FROM users;
+
+INSERT INTO wallet_transactions (wallet_id, user_id, currency, type, amount, balance_after, ref_type, ref_id)
+SELECT w.id, w.user_id, w.currency, 'migration', w.balance, w.balance, 'user', w.user_id
+FROM wallets w WHERE w.balance <> 0;
+
+ALTER TABLE users DROP COLUMN balance;
+
+-- 下注记录增加币种，金额支持最多8位小数
+ALTER TABLE bets
+    ADD COLUMN currency VARCHAR(10) NOT NULL DEFAULT 'CNY' AFTER round_id,
+    MODIFY amount DECIMAL(30,8) NOT NULL,
+    MODIFY payout DECIMAL(30,8) DEFAULT 0;
+
+-- 用户统计按币种拆分
+ALTER TABLE user_stats
+    ADD COLUMN currency VARCHAR(10) NOT NULL DEFAULT 'CNY' AFTER user_id,
+    MODIFY total_winnings DECIMAL(30,8) DEFAULT 0,
+    DROP INDEX uk_user_id,
+    ADD UNIQUE KEY idx_user_stats_user_currency (user_id, currency);
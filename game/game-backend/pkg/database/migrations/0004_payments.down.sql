@@ -0,0 +1,3 @@
+-- 回滚充值提现请求
+
+DROP TABLE IF EXISTS payment_requests;
@@ -0,0 +1,24 @@
+-- 充值提现请求
+
+CREATE TABLE IF NOT EXISTS payment_requests (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    request_id VARCHAR(50) NOT NULL,
+    user_id BIGINT UNSIGNED NOT NULL,
+    type VARCHAR(20) NOT NULL COMMENT 'deposit, withdrawal',
+    currency VARCHAR(10) NOT NULL,
+    amount DECIMAL(30,8) NOT NULL,
+    status VARCHAR(20) NOT NULL COMMENT 'pending, approved, completed, failed, reversed',
+    provider VARCHAR(30) NOT NULL,
+    provider_ref VARCHAR(100),
+    payment_url VARCHAR(500),
+    failure_reason VARCHAR(255),
+    reviewed_by BIGINT UNSIGNED NULL,
+    reviewed_at TIMESTAMP NULL,
+    completed_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY idx_payment_requests_request_id (request_id),
+    INDEX idx_payment_user_type (user_id, type),
+    INDEX idx_payment_requests_status (status),
+    INDEX idx_payment_requests_provider_ref (provider_ref)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,4 @@
+-- 回滚负责任博彩
+
+DROP TABLE IF EXISTS player_exclusions;
+DROP TABLE IF EXISTS player_limits;
@@ -0,0 +1,23 @@
+-- 负责任博彩：玩家限额与冷静期/自我排除
+
+CREATE TABLE IF NOT EXISTS player_limits (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT UNSIGNED NOT NULL,
+    type VARCHAR(20) NOT NULL COMMENT 'deposit, loss, wager, session',
+    period VARCHAR(20) NOT NULL COMMENT 'daily, weekly, monthly, session',
+    amount DECIMAL(30,8) NOT NULL COMMENT '基准币种金额或会话分钟数，0表示不限',
+    pending_amount DECIMAL(30,8) NULL COMMENT '待生效的放宽值',
+    pending_effective_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY idx_player_limit (user_id, type, period)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS player_exclusions (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT UNSIGNED NOT NULL,
+    type VARCHAR(20) NOT NULL COMMENT 'cool_off, self_exclusion',
+    until TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_player_exclusion_user_until (user_id, until)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,5 @@
+-- 回滚下注位
+
+ALTER TABLE bets
+    DROP INDEX idx_bet_round_slot,
+    DROP COLUMN slot;
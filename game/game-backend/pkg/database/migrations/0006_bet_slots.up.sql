@@ -0,0 +1,8 @@
+-- 同一局内多个下注位：下注记录增加下注位，同一玩家同一局的下注位唯一
+-- 历史下注未记录轮次，置为NULL以免与唯一索引冲突
+
+UPDATE bets SET round_id = NULL WHERE round_id = '';
+
+ALTER TABLE bets
+    ADD COLUMN slot INT NOT NULL DEFAULT 0 COMMENT '本局内的下注位，从0开始' AFTER round_id,
+    ADD UNIQUE KEY idx_bet_round_slot (user_id, round_id, slot);
@@ -0,0 +1,5 @@
+-- 回滚部分止盈
+
+DROP TABLE IF EXISTS bet_settlements;
+
+ALTER TABLE bets DROP COLUMN remaining_amount;
@@ -0,0 +1,17 @@
+-- 部分止盈：下注记录增加剩余本金，结算明细单独成表
+
+ALTER TABLE bets
+    ADD COLUMN remaining_amount DECIMAL(30,8) NOT NULL DEFAULT 0 COMMENT '尚未结算的本金' AFTER payout;
+
+UPDATE bets SET remaining_amount = amount WHERE status = 0;
+
+CREATE TABLE IF NOT EXISTS bet_settlements (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    bet_id VARCHAR(50) NOT NULL,
+    type VARCHAR(20) NOT NULL COMMENT 'manual, auto, forced',
+    stake DECIMAL(30,8) NOT NULL COMMENT '本次结算的本金',
+    multiplier DECIMAL(10,2) NOT NULL,
+    payout DECIMAL(30,8) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_bet_settlements_bet_id (bet_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,3 @@
+-- 回滚自动下注
+
+DROP TABLE IF EXISTS auto_bet_sessions;
@@ -0,0 +1,27 @@
+-- 服务端自动下注会话
+
+CREATE TABLE IF NOT EXISTS auto_bet_sessions (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT UNSIGNED NOT NULL,
+    status VARCHAR(20) NOT NULL COMMENT 'running, completed, stopped',
+    currency VARCHAR(10) NOT NULL,
+    base_amount DECIMAL(30,8) NOT NULL,
+    current_amount DECIMAL(30,8) NOT NULL COMMENT '下一局的下注金额',
+    auto_cashout DECIMAL(10,2) NOT NULL,
+    rounds INT NOT NULL,
+    rounds_played INT DEFAULT 0,
+    on_win VARCHAR(20) NOT NULL COMMENT 'reset, multiply',
+    on_win_multiplier DECIMAL(10,2) DEFAULT 0,
+    on_loss VARCHAR(20) NOT NULL COMMENT 'reset, multiply',
+    on_loss_multiplier DECIMAL(10,2) DEFAULT 0,
+    stop_on_profit DECIMAL(30,8) DEFAULT 0 COMMENT '0表示不限',
+    stop_on_loss DECIMAL(30,8) DEFAULT 0 COMMENT '0表示不限',
+    profit DECIMAL(30,8) DEFAULT 0 COMMENT '已结束下注的净盈亏',
+    last_bet_id VARCHAR(50) COMMENT '等待结果的下注',
+    stop_reason VARCHAR(30),
+    stop_message VARCHAR(255),
+    ended_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_auto_bet_user_status (user_id, status)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
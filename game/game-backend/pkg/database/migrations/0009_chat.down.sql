@@ -0,0 +1,4 @@
+-- 回滚聊天
+
+DROP TABLE IF EXISTS chat_sanctions;
+DROP TABLE IF EXISTS chat_messages;
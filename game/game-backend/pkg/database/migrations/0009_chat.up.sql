@@ -0,0 +1,30 @@
+-- 聊天消息与禁言/封禁
+
+CREATE TABLE IF NOT EXISTS chat_messages (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    room VARCHAR(50) NOT NULL,
+    user_id BIGINT UNSIGNED NOT NULL,
+    username VARCHAR(50) NOT NULL,
+    content VARCHAR(1000) NOT NULL COMMENT '过滤后下发的内容',
+    original VARCHAR(1000) COMMENT '命中屏蔽词时的原始内容',
+    flagged BOOLEAN DEFAULT FALSE COMMENT '命中屏蔽词，待审核',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_chat_message_room (room, id),
+    INDEX idx_chat_messages_user_id (user_id),
+    INDEX idx_chat_messages_flagged (flagged),
+    INDEX idx_chat_messages_created_at (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS chat_sanctions (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT UNSIGNED NOT NULL,
+    type VARCHAR(20) NOT NULL COMMENT 'mute, ban',
+    room VARCHAR(50) COMMENT '为空表示所有聊天室',
+    reason VARCHAR(255),
+    moderator_id BIGINT UNSIGNED NOT NULL,
+    until TIMESTAMP NULL COMMENT '为空表示永久',
+    lifted_at TIMESTAMP NULL,
+    lifted_by BIGINT UNSIGNED,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_chat_sanction_user (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,16 @@
+-- 回滚排行榜快照
+
+DROP TABLE IF EXISTS leaderboard_snapshots;
+
+CREATE TABLE IF NOT EXISTS leaderboard (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT UNSIGNED NOT NULL,
+    username VARCHAR(50) NOT NULL,
+    total_winnings DECIMAL(15,2) DEFAULT 0.00,
+    biggest_multiplier DECIMAL(10,2) DEFAULT 0.00,
+    `rank` INT DEFAULT 0,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_user_id (user_id),
+    INDEX idx_total_winnings (total_winnings),
+    INDEX idx_rank (`rank`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,17 @@
+-- 排行榜改为Redis有序集合实时维护，MySQL只保存快照
+
+DROP TABLE IF EXISTS leaderboard;
+
+CREATE TABLE IF NOT EXISTS leaderboard_snapshots (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    period VARCHAR(10) NOT NULL COMMENT 'daily, weekly, all',
+    metric VARCHAR(20) NOT NULL COMMENT 'profit, multiplier',
+    bucket VARCHAR(20) NOT NULL COMMENT '日榜20240101，周榜2024W01，总榜all',
+    `rank` INT NOT NULL,
+    user_id BIGINT UNSIGNED NOT NULL,
+    username VARCHAR(50) NOT NULL,
+    score DECIMAL(30,8) NOT NULL COMMENT '净盈亏(基准币种)或最大止盈倍数',
+    taken_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY idx_leaderboard_snapshot_rank (period, metric, bucket, `rank`),
+    INDEX idx_leaderboard_snapshots_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,17 @@
+-- 回滚玩家统计
+
+ALTER TABLE game_history
+    DROP COLUMN bets_count;
+
+DROP TABLE IF EXISTS user_daily_stats;
+
+ALTER TABLE user_stats
+    DROP COLUMN wins,
+    DROP COLUMN losses,
+    DROP COLUMN total_wagered,
+    DROP COLUMN cashouts,
+    DROP COLUMN cashout_multiplier_sum,
+    DROP COLUMN current_streak,
+    DROP COLUMN longest_win_streak,
+    DROP COLUMN longest_loss_streak,
+    DROP COLUMN last_round_id;
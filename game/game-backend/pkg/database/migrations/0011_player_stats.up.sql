@@ -0,0 +1,73 @@
+-- 玩家统计：输赢、投注额、止盈倍数、连胜连败与每日统计；每局记录下注数
+
+ALTER TABLE user_stats
+    ADD COLUMN wins BIGINT DEFAULT 0 AFTER total_bets,
+    ADD COLUMN losses BIGINT DEFAULT 0 AFTER wins,
+    ADD COLUMN total_wagered DECIMAL(30,8) DEFAULT 0 AFTER losses,
+    ADD COLUMN cashouts BIGINT DEFAULT 0 AFTER biggest_multiplier,
+    ADD COLUMN cashout_multiplier_sum DECIMAL(20,2) DEFAULT 0 AFTER cashouts,
+    ADD COLUMN current_streak BIGINT DEFAULT 0 COMMENT '大于0为连胜，小于0为连败' AFTER cashout_multiplier_sum,
+    ADD COLUMN longest_win_streak BIGINT DEFAULT 0 AFTER current_streak,
+    ADD COLUMN longest_loss_streak BIGINT DEFAULT 0 AFTER longest_win_streak,
+    ADD COLUMN last_round_id VARCHAR(50) COMMENT '最近一次结束下注的轮次' AFTER games_played;
+
+CREATE TABLE IF NOT EXISTS user_daily_stats (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT UNSIGNED NOT NULL,
+    currency VARCHAR(10) NOT NULL,
+    day DATE NOT NULL COMMENT '结算日期(服务器时区)',
+    bets BIGINT DEFAULT 0,
+    wins BIGINT DEFAULT 0,
+    losses BIGINT DEFAULT 0,
+    wagered DECIMAL(30,8) DEFAULT 0,
+    winnings DECIMAL(30,8) DEFAULT 0,
+    cashouts BIGINT DEFAULT 0,
+    cashout_multiplier_sum DECIMAL(20,2) DEFAULT 0,
+    biggest_multiplier DECIMAL(10,2) DEFAULT 0,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY idx_user_daily_stats_day (user_id, currency, day)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+ALTER TABLE game_history
+    ADD COLUMN bets_count INT DEFAULT 0 AFTER players_count;
+
+-- 按已结束的下注重新计算累计统计，连胜连败从迁移后开始计算
+INSERT INTO user_stats (user_id, currency, total_bets, wins, losses, total_wagered, total_winnings, games_played)
+SELECT user_id, currency, COUNT(*), SUM(payout > amount), SUM(payout <= amount), SUM(amount), SUM(payout), COUNT(DISTINCT round_id)
+FROM bets
+WHERE status IN (1, 2)
+GROUP BY user_id, currency
+ON DUPLICATE KEY UPDATE
+    total_bets = VALUES(total_bets),
+    wins = VALUES(wins),
+    losses = VALUES(losses),
+    total_wagered = VALUES(total_wagered),
+    total_winnings = VALUES(total_winnings),
+    games_played = VALUES(games_played);
+
+UPDATE user_stats us
+JOIN (
+    SELECT b.user_id, b.currency, COUNT(*) AS cashouts, SUM(s.multiplier) AS multiplier_sum, MAX(s.multiplier) AS biggest
+    FROM bet_settlements s
+    JOIN bets b ON b.bet_id = s.bet_id
+    GROUP BY b.user_id, b.currency
+) t ON t.user_id = us.user_id AND t.currency = us.currency
+SET us.cashouts = t.cashouts,
+    us.cashout_multiplier_sum = t.multiplier_sum,
+    us.biggest_multiplier = GREATEST(us.biggest_multiplier, t.biggest);
+
+INSERT INTO user_daily_stats (user_id, currency, day, bets, wins, losses, wagered, winnings)
+SELECT user_id, currency, DATE(COALESCE(cashout_time, created_at)), COUNT(*), SUM(payout > amount), SUM(payout <= amount), SUM(amount), SUM(payout)
+FROM bets
+WHERE status IN (1, 2)
+GROUP BY user_id, currency, DATE(COALESCE(cashout_time, created_at));
+
+INSERT INTO user_daily_stats (user_id, currency, day, cashouts, cashout_multiplier_sum, biggest_multiplier)
+SELECT b.user_id, b.currency, DATE(s.created_at), COUNT(*), SUM(s.multiplier), MAX(s.multiplier)
+FROM bet_settlements s
+JOIN bets b ON b.bet_id = s.bet_id
+GROUP BY b.user_id, b.currency, DATE(s.created_at)
+ON DUPLICATE KEY UPDATE
+    cashouts = VALUES(cashouts),
+    cashout_multiplier_sum = VALUES(cashout_multiplier_sum),
+    biggest_multiplier = VALUES(biggest_multiplier);
@@ -0,0 +1,10 @@
+-- 回滚历史列表排序索引
+
+ALTER TABLE game_history
+    DROP INDEX idx_total_bets,
+    DROP INDEX idx_total_payout;
+
+ALTER TABLE bets
+    DROP INDEX idx_bets_user_amount,
+    DROP INDEX idx_bets_user_payout,
+    DROP INDEX idx_bets_user_multiplier;
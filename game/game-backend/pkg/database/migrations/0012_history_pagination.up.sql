@@ -0,0 +1,10 @@
+-- 历史列表改为游标分页：为可排序字段增加索引，InnoDB二级索引隐含主键，按排序值与ID翻页可直接走索引
+
+ALTER TABLE bets
+    ADD INDEX idx_bets_user_amount (user_id, amount),
+    ADD INDEX idx_bets_user_payout (user_id, payout),
+    ADD INDEX idx_bets_user_multiplier (user_id, multiplier);
+
+ALTER TABLE game_history
+    ADD INDEX idx_total_bets (total_bets),
+    ADD INDEX idx_total_payout (total_payout);
@@ -0,0 +1,3 @@
+-- 回滚游戏会话
+
+DROP TABLE IF EXISTS player_sessions;
@@ -0,0 +1,7 @@
+-- 游戏会话：会话时长限额改为从休息后的第一笔下注起算，登出与重新登录不再重置
+
+CREATE TABLE IF NOT EXISTS player_sessions (
+    user_id BIGINT UNSIGNED PRIMARY KEY,
+    started_at TIMESTAMP NOT NULL COMMENT '当前游戏会话开始时间',
+    last_active_at TIMESTAMP NOT NULL COMMENT '最近一次下注时间'
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,12 @@
+-- 回滚审计分链：只保留公共链的链头，分链之后写入的玩家链记录无法再按单链校验
+
+DELETE FROM audit_chain_head WHERE id <> 0;
+
+UPDATE audit_chain_head SET id = 1 WHERE id = 0;
+
+ALTER TABLE audit_chain_head
+    MODIFY id INT UNSIGNED NOT NULL;
+
+ALTER TABLE audit_logs
+    DROP INDEX idx_chain_id,
+    DROP COLUMN chain_id;
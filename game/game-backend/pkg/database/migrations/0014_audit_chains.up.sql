@@ -0,0 +1,11 @@
+-- 审计哈希链按玩家分链：原先所有追加都锁同一行链头，资金操作之间互相串行
+-- 已有记录与原链头归入公共链（链0），之后玩家相关事件追加到以玩家ID为链ID的链
+
+ALTER TABLE audit_logs
+    ADD COLUMN chain_id BIGINT UNSIGNED NOT NULL DEFAULT 0 AFTER id,
+    ADD INDEX idx_chain_id (chain_id, id);
+
+ALTER TABLE audit_chain_head
+    MODIFY id BIGINT UNSIGNED NOT NULL;
+
+UPDATE audit_chain_head SET id = 0 WHERE id = 1;
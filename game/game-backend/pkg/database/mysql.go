@@ -1,13 +1,13 @@
 package database
 
 import (
+	"context"
 	"fmt"
-	"log"
 	"time"
 
+	"github.com/sirupsen/logrus"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
 	"game-backend/config"
 	"game-backend/internal/model"
 )
@@ -21,7 +21,7 @@ func InitMySQL() error {
 	
 	// 配置GORM
 	gormConfig := &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
+		Logger: newGormLogger(),
 		NowFunc: func() time.Time {
 			return time.Now().Local()
 		},
@@ -33,6 +33,14 @@ func InitMySQL() error {
 		return fmt.Errorf("连接数据库失败: %v", err)
 	}
 
+	// 注册指标与追踪插件
+	if err := db.Use(gormMetricsPlugin{}); err != nil {
+		return fmt.Errorf("注册数据库指标插件失败: %v", err)
+	}
+	if err := db.Use(gormTracingPlugin{}); err != nil {
+		return fmt.Errorf("注册数据库追踪插件失败: %v", err)
+	}
+
 	// 获取底层sql.DB对象
 	sqlDB, err := db.DB()
 	if err != nil {
@@ -50,11 +58,12 @@ func InitMySQL() error {
 	}
 
 	DB = db
-	log.Println("MySQL数据库连接成功")
+	logrus.Info("MySQL数据库连接成功")
 	return nil
 }
 
-// AutoMigrate 自动迁移数据库表结构
+// AutoMigrate 使用GORM根据模型自动同步表结构
+// 仅用于开发环境快速迭代，正式表结构以 migrations 目录下的版本化迁移为准
 func AutoMigrate() error {
 	if DB == nil {
 		return fmt.Errorf("数据库未初始化")
@@ -64,21 +73,60 @@ func AutoMigrate() error {
 	err := DB.AutoMigrate(
 		&model.User{},
 		&model.UserStats{},
+		&model.UserDailyStats{},
 		&model.UserSession{},
 		&model.Game{},
 		&model.Bet{},
+		&model.BetSettlement{},
 		&model.GameHistory{},
-		&model.Leaderboard{},
+		&model.LeaderboardSnapshot{},
+		&model.AuditLog{},
+		&model.AuditChainHead{},
+		&model.Wallet{},
+		&model.WalletTransaction{},
+		&model.PaymentRequest{},
+		&model.PlayerLimit{},
+		&model.PlayerExclusion{},
+		&model.PlayerSession{},
+		&model.AutoBetSession{},
+		&model.ChatMessage{},
+		&model.ChatSanction{},
 	)
 
 	if err != nil {
 		return fmt.Errorf("数据库迁移失败: %v", err)
 	}
 
-	log.Println("数据库表结构迁移完成")
+	logrus.Info("数据库表结构迁移完成")
 	return nil
 }
 
+// RunMigrations 执行所有未应用的版本化迁移
+func RunMigrations() error {
+	if DB == nil {
+		return fmt.Errorf("数据库未初始化")
+	}
+
+	migrator, err := NewMigrator(DB)
+	if err != nil {
+		return err
+	}
+	return migrator.Up()
+}
+
+// CheckMigrations 检查数据库是否已迁移到最新版本，内嵌迁移的最新版本只在首次检查时解析
+func CheckMigrations(ctx context.Context) error {
+	if DB == nil {
+		return fmt.Errorf("数据库未初始化")
+	}
+
+	latest, err := LatestMigrationVersion()
+	if err != nil {
+		return err
+	}
+	return checkSchemaVersion(ctx, DB, latest)
+}
+
 // Close 关闭数据库连接
 func Close() error {
 	if DB == nil {
@@ -3,10 +3,10 @@ package database
 import (
 	"context"
 	"fmt"
-	"log"
 	"time"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
 	"game-backend/config"
 )
 
@@ -26,6 +26,8 @@ func InitRedis() error {
 		ReadTimeout:  3 * time.Second,
 		WriteTimeout: 3 * time.Second,
 	})
+	RedisClient.AddHook(redisMetricsHook{})
+	RedisClient.AddHook(redisTracingHook{})
 
 	// 测试连接
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -35,7 +37,7 @@ func InitRedis() error {
 		return fmt.Errorf("Redis连接失败: %v", err)
 	}
 
-	log.Println("Redis连接成功")
+	logrus.Info("Redis连接成功")
 	return nil
 }
 
@@ -0,0 +1,103 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/natefinch/lumberjack.v2"
+	"game-backend/config"
+)
+
+// 上下文字段名
+const (
+	FieldRequestID = "request_id"
+	FieldUserID    = "user_id"
+	FieldRoundID   = "round_id"
+	FieldBetID     = "bet_id"
+	FieldTraceID   = "trace_id"
+)
+
+type contextKey struct{}
+
+// Init 根据日志配置初始化全局日志
+func Init(cfg config.LogConfig) error {
+	// 日志级别
+	level, err := logrus.ParseLevel(cfg.Level)
+	if err != nil {
+		return fmt.Errorf("日志级别无效: %s", cfg.Level)
+	}
+	logrus.SetLevel(level)
+
+	// 日志格式
+	switch strings.ToLower(cfg.Format) {
+	case "json":
+		logrus.SetFormatter(&logrus.JSONFormatter{
+			TimestampFormat: "2006-01-02T15:04:05.000Z07:00",
+		})
+	case "text":
+		logrus.SetFormatter(&logrus.TextFormatter{
+			FullTimestamp:   true,
+			TimestampFormat: "2006-01-02 15:04:05.000",
+		})
+	default:
+		return fmt.Errorf("日志格式无效: %s", cfg.Format)
+	}
+
+	// 日志输出
+	output, err := newOutput(cfg)
+	if err != nil {
+		return err
+	}
+	logrus.SetOutput(output)
+
+	return nil
+}
+
+// newOutput 创建日志输出，文件输出按大小和保留天数滚动
+func newOutput(cfg config.LogConfig) (io.Writer, error) {
+	switch strings.ToLower(cfg.Output) {
+	case "stdout", "":
+		return os.Stdout, nil
+	case "stderr":
+		return os.Stderr, nil
+	case "file":
+		if err := os.MkdirAll(filepath.Dir(cfg.FilePath), 0755); err != nil {
+			return nil, fmt.Errorf("创建日志目录失败: %v", err)
+		}
+		return &lumberjack.Logger{
+			Filename:   cfg.FilePath,
+			MaxSize:    cfg.MaxSize,
+			MaxBackups: cfg.MaxBackups,
+			MaxAge:     cfg.MaxAge,
+			Compress:   cfg.Compress,
+			LocalTime:  true,
+		}, nil
+	default:
+		return nil, fmt.Errorf("日志输出无效: %s", cfg.Output)
+	}
+}
+
+// NewContext 返回携带日志条目的上下文
+func NewContext(ctx context.Context, entry *logrus.Entry) context.Context {
+	return context.WithValue(ctx, contextKey{}, entry)
+}
+
+// FromContext 获取上下文中的日志条目，不存在时返回全局日志
+func FromContext(ctx context.Context) *logrus.Entry {
+	if ctx != nil {
+		if entry, ok := ctx.Value(contextKey{}).(*logrus.Entry); ok {
+			return entry
+		}
+	}
+	return logrus.NewEntry(logrus.StandardLogger())
+}
+
+// WithFields 在上下文的日志条目上追加字段
+func WithFields(ctx context.Context, fields logrus.Fields) context.Context {
+	return NewContext(ctx, FromContext(ctx).WithFields(fields))
+}
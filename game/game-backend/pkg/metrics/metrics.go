@@ -0,0 +1,224 @@
+package metrics
+
+import (
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "crash"
+
+// HTTP指标
+var (
+	// HTTPRequestDuration HTTP请求耗时（按路由、方法、状态码）
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP请求处理耗时",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// RateLimitRejections 速率限制拒绝次数
+	RateLimitRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "rate_limit_rejections_total",
+		Help:      "被速率限制拒绝的请求数",
+	}, []string{"limiter"})
+)
+
+// WebSocket指标
+var (
+	// WSMessagesDropped 因发送队列已满而丢弃的消息数
+	WSMessagesDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "websocket",
+		Name:      "messages_dropped_total",
+		Help:      "发送队列已满时丢弃的消息数",
+	})
+
+	// WSRoundTrip 时间同步ping测得的连接往返时间
+	WSRoundTrip = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "websocket",
+		Name:      "rtt_seconds",
+		Help:      "时间同步ping测得的WebSocket连接往返时间",
+		Buckets:   []float64{0.01, 0.025, 0.05, 0.1, 0.2, 0.35, 0.5, 1, 2},
+	})
+)
+
+// 游戏指标
+var (
+	// GameTickLag 游戏循环滴答延迟
+	GameTickLag = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "game",
+		Name:      "tick_lag_seconds",
+		Help:      "游戏循环实际滴答间隔超出预期间隔的时间",
+		Buckets:   []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1},
+	})
+
+	// BetsTotal 下注总数
+	BetsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "game",
+		Name:      "bets_total",
+		Help:      "下注总数",
+	})
+
+	// CashoutsTotal 止盈总数（manual/auto/forced）
+	CashoutsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "game",
+		Name:      "cashouts_total",
+		Help:      "止盈总数",
+	}, []string{"type"})
+
+	// BetsCancelled 下注阶段撤销的下注数
+	BetsCancelled = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "game",
+		Name:      "bets_cancelled_total",
+		Help:      "下注阶段撤销的下注数",
+	})
+
+	// BetsRejected 被单局风控或下注位限制拒绝的下注数
+	BetsRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "game",
+		Name:      "bets_rejected_total",
+		Help:      "被单局风控或下注位限制拒绝的下注数，按原因区分",
+	}, []string{"reason"})
+
+	// RoundExposure 当前轮次潜在赔付总额
+	RoundExposure = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "game",
+		Name:      "round_exposure_amount",
+		Help:      "当前轮次进行中下注的潜在赔付总额（折算为基准币种）",
+	})
+
+	// RoundBets 每轮下注数
+	RoundBets = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "game",
+		Name:      "round_bets",
+		Help:      "每轮下注数",
+		Buckets:   []float64{0, 1, 5, 10, 50, 100, 500, 1000, 5000},
+	})
+
+	// RoundCashouts 每轮止盈数
+	RoundCashouts = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "game",
+		Name:      "round_cashouts",
+		Help:      "每轮止盈数",
+		Buckets:   []float64{0, 1, 5, 10, 50, 100, 500, 1000, 5000},
+	})
+
+	// WageredTotal 累计下注金额
+	WageredTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "game",
+		Name:      "wagered_amount_total",
+		Help:      "累计下注金额（折算为基准币种）",
+	})
+
+	// PaidOutTotal 累计赔付金额
+	PaidOutTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "game",
+		Name:      "paid_out_amount_total",
+		Help:      "累计赔付金额（折算为基准币种）",
+	})
+
+	// HouseProfit 平台盈亏（下注金额 - 赔付金额）
+	HouseProfit = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "game",
+		Name:      "house_profit_amount",
+		Help:      "本实例启动以来的平台盈亏（折算为基准币种）",
+	})
+)
+
+// 存储指标
+var (
+	// DBQueryDuration 数据库操作耗时
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "query_duration_seconds",
+		Help:      "数据库操作耗时",
+		Buckets:   []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1},
+	}, []string{"operation", "table", "status"})
+
+	// RedisCommandDuration Redis命令耗时
+	RedisCommandDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "redis",
+		Name:      "command_duration_seconds",
+		Help:      "Redis命令耗时",
+		Buckets:   []float64{0.0001, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25},
+	}, []string{"command", "status"})
+)
+
+// 当前轮次计数
+var (
+	roundBets     int64
+	roundCashouts int64
+)
+
+// ObserveBet 记录一笔下注
+func ObserveBet(amount float64) {
+	BetsTotal.Inc()
+	WageredTotal.Add(amount)
+	HouseProfit.Add(amount)
+	atomic.AddInt64(&roundBets, 1)
+}
+
+// ObserveBetCancel 记录一笔撤销的下注，退回的本金从平台盈亏中扣除
+func ObserveBetCancel(amount float64) {
+	BetsCancelled.Inc()
+	HouseProfit.Sub(amount)
+	atomic.AddInt64(&roundBets, -1)
+}
+
+// ObserveCashout 记录一笔止盈
+func ObserveCashout(cashoutType string, payout float64) {
+	CashoutsTotal.WithLabelValues(cashoutType).Inc()
+	PaidOutTotal.Add(payout)
+	HouseProfit.Sub(payout)
+	atomic.AddInt64(&roundCashouts, 1)
+}
+
+// ObserveRoundEnd 记录本轮下注与止盈数并重置计数
+func ObserveRoundEnd() {
+	RoundBets.Observe(float64(atomic.SwapInt64(&roundBets, 0)))
+	RoundCashouts.Observe(float64(atomic.SwapInt64(&roundCashouts, 0)))
+}
+
+// RegisterWebSocketGauges 注册WebSocket连接数、发送队列深度与在线用户数指标
+func RegisterWebSocketGauges(connections, queueDepth, onlineUsers func() float64) {
+	prometheus.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "websocket",
+			Name:      "active_connections",
+			Help:      "当前WebSocket连接数",
+		}, connections),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "websocket",
+			Name:      "send_queue_depth",
+			Help:      "所有连接发送队列中待发送的消息总数",
+		}, queueDepth),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "websocket",
+			Name:      "online_users",
+			Help:      "所有实例的在线用户数，同一用户多个连接只计一次",
+		}, onlineUsers),
+	)
+}
@@ -0,0 +1,101 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	"game-backend/config"
+)
+
+// ErrUnsupportedCurrency 不支持的币种
+var ErrUnsupportedCurrency = errors.New("不支持的币种")
+
+// Currency 币种定义，金额精度与下注限额均以该币种最小单位计
+type Currency struct {
+	Code      string          `json:"code"`
+	Precision int32           `json:"precision"`
+	MinBet    decimal.Decimal `json:"min_bet"`
+	MaxBet    decimal.Decimal `json:"max_bet"`
+	Rate      decimal.Decimal `json:"rate"` // 1单位折合基准币种数量
+}
+
+var (
+	currencies   []Currency
+	currencyByID map[string]Currency
+	baseCurrency Currency
+)
+
+// Init 根据配置加载币种表，需在启动时调用一次
+func Init(cfg config.WalletConfig) error {
+	list := make([]Currency, 0, len(cfg.Currencies))
+	byID := make(map[string]Currency, len(cfg.Currencies))
+
+	for _, c := range cfg.Currencies {
+		cur := Currency{
+			Code:      strings.ToUpper(c.Code),
+			Precision: c.Precision,
+			MinBet:    decimal.NewFromFloat(c.MinBet).Round(c.Precision),
+			MaxBet:    decimal.NewFromFloat(c.MaxBet).Round(c.Precision),
+			Rate:      decimal.NewFromFloat(c.Rate),
+		}
+		list = append(list, cur)
+		byID[cur.Code] = cur
+	}
+
+	base, ok := byID[strings.ToUpper(cfg.BaseCurrency)]
+	if !ok {
+		return fmt.Errorf("基准币种 %s 未配置", cfg.BaseCurrency)
+	}
+
+	currencies = list
+	currencyByID = byID
+	baseCurrency = base
+	return nil
+}
+
+// Lookup 按代码获取币种，空代码返回基准币种
+func Lookup(code string) (Currency, error) {
+	if code == "" {
+		return baseCurrency, nil
+	}
+	cur, ok := currencyByID[strings.ToUpper(code)]
+	if !ok {
+		return Currency{}, ErrUnsupportedCurrency
+	}
+	return cur, nil
+}
+
+// Base 基准币种
+func Base() Currency {
+	return baseCurrency
+}
+
+// Currencies 全部已配置币种
+func Currencies() []Currency {
+	return currencies
+}
+
+// ValidateAmount 校验金额为正数且不超过币种精度
+func (c Currency) ValidateAmount(amount decimal.Decimal) error {
+	if !amount.IsPositive() || !amount.Equal(amount.Truncate(c.Precision)) {
+		return ErrInvalidAmount
+	}
+	return nil
+}
+
+// Payout 计算赔付：倍数先截断到两位小数，乘积向下取整到币种最小单位
+func (c Currency) Payout(amount, multiplier decimal.Decimal) decimal.Decimal {
+	return amount.Mul(TruncMultiplier(multiplier)).RoundFloor(c.Precision)
+}
+
+// ToBase 折算为基准币种金额，向下取整到基准币种最小单位
+func (c Currency) ToBase(amount decimal.Decimal) decimal.Decimal {
+	return amount.Mul(c.Rate).RoundFloor(baseCurrency.Precision)
+}
+
+// Format 按币种精度格式化金额
+func (c Currency) Format(amount decimal.Decimal) string {
+	return amount.StringFixed(c.Precision)
+}
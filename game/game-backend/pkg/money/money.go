@@ -0,0 +1,33 @@
+package money
+
+import (
+	"errors"
+
+	"github.com/shopspring/decimal"
+)
+
+// MultiplierScale 倍数保留的小数位数
+const MultiplierScale = 2
+
+var (
+	// ErrInvalidAmount 金额不合法
+	ErrInvalidAmount = errors.New("金额必须为正数且不超过币种精度")
+	// ErrInvalidMultiplier 倍数不合法
+	ErrInvalidMultiplier = errors.New("倍数最多两位小数")
+)
+
+// Zero 零金额
+var Zero = decimal.Zero
+
+// TruncMultiplier 倍数截断到两位小数（不四舍五入）
+func TruncMultiplier(m decimal.Decimal) decimal.Decimal {
+	return m.Truncate(MultiplierScale)
+}
+
+// ValidateMultiplier 校验倍数不超过两位小数
+func ValidateMultiplier(m decimal.Decimal) error {
+	if m.IsNegative() || !m.Equal(TruncMultiplier(m)) {
+		return ErrInvalidMultiplier
+	}
+	return nil
+}
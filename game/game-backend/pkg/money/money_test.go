@@ -0,0 +1,60 @@
+package money
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestTruncMultiplier(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"1", "1"},
+		{"1.5", "1.5"},
+		{"2.349", "2.34"},
+		{"2.999999", "2.99"},
+		{"10.005", "10"},
+		{"0.019", "0.01"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got := TruncMultiplier(decimal.RequireFromString(tt.in))
+			if !got.Equal(decimal.RequireFromString(tt.want)) {
+				t.Fatalf("TruncMultiplier(%s) = %s, want %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCurrencyPayout(t *testing.T) {
+	cny := Currency{Code: "CNY", Precision: 2}
+	btc := Currency{Code: "BTC", Precision: 8}
+	jpy := Currency{Code: "JPY", Precision: 0}
+
+	tests := []struct {
+		name       string
+		cur        Currency
+		amount     string
+		multiplier string
+		want       string
+	}{
+		{"整数倍", cny, "10", "2", "20"},
+		{"倍数截断到两位小数", cny, "10", "1.999", "19.9"},
+		{"赔付向下取整到分", cny, "0.33", "1.5", "0.49"},
+		{"高精度币种", btc, "0.00012345", "2.37", "0.00029257"},
+		{"无小数币种", jpy, "7", "1.99", "13"},
+		{"1倍退回本金", cny, "12.34", "1.00999", "12.34"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.cur.Payout(decimal.RequireFromString(tt.amount), decimal.RequireFromString(tt.multiplier))
+			if !got.Equal(decimal.RequireFromString(tt.want)) {
+				t.Fatalf("Payout(%s, %s) = %s, want %s", tt.amount, tt.multiplier, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+-- 测试数据（需在执行数据库迁移后导入）
+USE crash_game;
+
+-- 插入测试用户
+INSERT IGNORE INTO users (username, password, email, status) VALUES
+('testuser1', '$2a$10$92IXUNpkjO0rOQ5byMi.Ye4oKoEa3Ro9llC/.og/at2.uheWG/igi', 'test1@example.com', 1),
+('testuser2', '$2a$10$92IXUNpkjO0rOQ5byMi.Ye4oKoEa3Ro9llC/.og/at2.uheWG/igi', 'test2@example.com', 1);
+
+-- 插入测试钱包
+INSERT IGNORE INTO wallets (user_id, currency, balance) VALUES
+(1, 'CNY', 1000.00),
+(1, 'USD', 100.00),
+(2, 'CNY', 1000.00);
+
+INSERT INTO wallet_transactions (wallet_id, user_id, currency, type, amount, balance_after, ref_type, ref_id)
+SELECT w.id, w.user_id, w.currency, 'seed', w.balance, w.balance, 'user', w.user_id
+FROM wallets w
+WHERE w.user_id IN (1, 2)
+  AND NOT EXISTS (SELECT 1 FROM wallet_transactions t WHERE t.wallet_id = w.id);
+
+-- 插入测试用户统计
+INSERT IGNORE INTO user_stats (user_id, currency, total_bets, total_winnings, biggest_multiplier, games_played) VALUES
+(1, 'CNY', 0, 0.00, 0.00, 0),
+(2, 'CNY', 0, 0.00, 0.00, 0);
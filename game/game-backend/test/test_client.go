@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -16,6 +17,7 @@ import (
 // 测试客户端
 type TestClient struct {
 	ws       *websocket.Conn
+	wsMutex  sync.Mutex // 消息接收协程回应时间同步与命令行发送并发写入
 	token    string
 	userID   uint
 	username string
@@ -29,19 +31,34 @@ type LoginResponse struct {
 		Token    string `json:"token"`
 		UserID   uint   `json:"user_id"`
 		Username string `json:"username"`
-		Balance  float64 `json:"balance"`
+		Wallets  []struct {
+			Currency string `json:"currency"`
+			Balance  string `json:"balance"`
+		} `json:"wallets"`
 	} `json:"data"`
 }
 
 // WebSocket消息
 type WSMessage struct {
 	Type      string      `json:"type"`
+	Slot      *int        `json:"slot,omitempty"`
 	Data      interface{} `json:"data,omitempty"`
 	Token     string      `json:"token,omitempty"`
 	Version   string      `json:"version,omitempty"`
+	Currency  string      `json:"currency,omitempty"`
 	Amount    float64     `json:"amount,omitempty"`
 	AutoCashout float64   `json:"auto_cashout,omitempty"`
 	BetID     string      `json:"bet_id,omitempty"`
+	Fraction  float64     `json:"fraction,omitempty"`
+	BaseAmount float64    `json:"base_amount,omitempty"`
+	Rounds    int         `json:"rounds,omitempty"`
+	OnWin     string      `json:"on_win,omitempty"`
+	OnLoss    string      `json:"on_loss,omitempty"`
+	ID        int64       `json:"id,omitempty"`
+	ServerTime int64      `json:"server_time,omitempty"`
+	ClientTime int64      `json:"client_time,omitempty"`
+	Room      string      `json:"room,omitempty"`
+	Content   string      `json:"content,omitempty"`
 }
 
 func main() {
@@ -94,8 +111,10 @@ func (c *TestClient) login() error {
 	c.userID = loginResp.Data.UserID
 	c.username = loginResp.Data.Username
 	
-	fmt.Printf("登录成功: %s (ID: %d, 余额: %.2f)\n", 
-		c.username, c.userID, loginResp.Data.Balance)
+	fmt.Printf("登录成功: %s (ID: %d)\n", c.username, c.userID)
+	for _, w := range loginResp.Data.Wallets {
+		fmt.Printf("  余额: %s %s\n", w.Balance, w.Currency)
+	}
 	
 	return nil
 }
@@ -118,7 +137,7 @@ func (c *TestClient) connectWebSocket() error {
 		Version: "1.0",
 	}
 	
-	if err := c.ws.WriteJSON(handshake); err != nil {
+	if err := c.writeJSON(handshake); err != nil {
 		return err
 	}
 	
@@ -126,6 +145,13 @@ func (c *TestClient) connectWebSocket() error {
 	return nil
 }
 
+// 发送WebSocket消息
+func (c *TestClient) writeJSON(msg interface{}) error {
+	c.wsMutex.Lock()
+	defer c.wsMutex.Unlock()
+	return c.ws.WriteJSON(msg)
+}
+
 // 处理WebSocket消息
 func (c *TestClient) handleMessages() {
 	for {
@@ -144,16 +170,17 @@ func (c *TestClient) handleMessages() {
 		case "handshake_response":
 			status, _ := msg["status"].(string)
 			if status == "success" {
-				fmt.Println("✅ 握手成功")
+				seq, _ := msg["seq"].(float64)
+				fmt.Printf("✅ 握手成功: 会话=%v, 序号=%d, 已恢复=%v\n", msg["session_id"], int64(seq), msg["resumed"])
 			} else {
 				fmt.Printf("❌ 握手失败: %v\n", msg["message"])
 			}
 			
 		case "game_status_update":
-			multiplier, _ := msg["current_multiplier"].(float64)
+			multiplier, _ := msg["current_multiplier"].(string)
 			players, _ := msg["players_count"].(float64)
 			nextRound, _ := msg["next_round_in"].(float64)
-			fmt.Printf("🎮 游戏状态: 倍数=%.2f, 玩家=%d, 下轮=%ds\n", 
+			fmt.Printf("🎮 游戏状态: 倍数=%s, 玩家=%d, 下轮=%ds\n", 
 				multiplier, int(players), int(nextRound))
 			
 		case "game_start":
@@ -162,25 +189,108 @@ func (c *TestClient) handleMessages() {
 			fmt.Printf("🚀 游戏开始: 轮次=%s, 玩家=%d\n", roundID, int(players))
 			
 		case "game_end":
-			multiplier, _ := msg["final_multiplier"].(float64)
+			multiplier, _ := msg["final_multiplier"].(string)
 			winners, _ := msg["winners_count"].(float64)
-			fmt.Printf("🏁 游戏结束: 最终倍数=%.2f, 获胜者=%d\n", 
+			fmt.Printf("🏁 游戏结束: 最终倍数=%s, 获胜者=%d\n", 
 				multiplier, int(winners))
 			
 		case "player_bet":
 			betID, _ := msg["bet_id"].(string)
-			amount, _ := msg["amount"].(float64)
-			fmt.Printf("💰 下注成功: ID=%s, 金额=%.2f\n", betID, amount)
+			slot, _ := msg["slot"].(float64)
+			amount, _ := msg["amount"].(string)
+			fmt.Printf("💰 下注成功: ID=%s, 下注位=%d, 金额=%s\n", betID, int(slot), amount)
+			
+		case "round_snapshot":
+			roundID, _ := msg["round_id"].(string)
+			bets, _ := msg["bets"].([]interface{})
+			seq, _ := msg["seq"].(float64)
+			fmt.Printf("📋 本局下注列表: 轮次=%s, 下注=%d, 序号=%d\n", roundID, len(bets), int64(seq))
+			
+		case "player_bet_cancel":
+			betID, _ := msg["bet_id"].(string)
+			amount, _ := msg["amount"].(string)
+			fmt.Printf("↩️ 下注已撤销: ID=%s, 退回=%s\n", betID, amount)
 			
 		case "player_cashout":
 			betID, _ := msg["bet_id"].(string)
-			multiplier, _ := msg["multiplier"].(float64)
-			payout, _ := msg["payout"].(float64)
-			fmt.Printf("💸 止盈成功: ID=%s, 倍数=%.2f, 赔付=%.2f\n", 
-				betID, multiplier, payout)
+			slot, _ := msg["slot"].(float64)
+			multiplier, _ := msg["multiplier"].(string)
+			payout, _ := msg["payout"].(string)
+			remaining, _ := msg["remaining"].(string)
+			fmt.Printf("💸 止盈成功: ID=%s, 下注位=%d, 倍数=%s, 赔付=%s, 剩余本金=%s\n", 
+				betID, int(slot), multiplier, payout, remaining)
+			
+		case "auto_bet_status":
+			status, _ := msg["status"].(string)
+			played, _ := msg["rounds_played"].(float64)
+			rounds, _ := msg["rounds"].(float64)
+			amount, _ := msg["current_amount"].(string)
+			profit, _ := msg["profit"].(string)
+			reason, _ := msg["stop_reason"].(string)
+			fmt.Printf("🤖 自动下注: 状态=%s, 轮数=%d/%d, 下局金额=%s, 盈亏=%s %s\n",
+				status, int(played), int(rounds), amount, profit, reason)
+			
+		case "game_tick":
+			// 关键帧之间的倍数滴答，每100ms一条，不打印
+			
+		case "time_sync_ping":
+			// 回应服务端的时间同步ping，服务端据此测量RTT
+			id, _ := msg["id"].(float64)
+			serverTime, _ := msg["server_time"].(float64)
+			c.writeJSON(WSMessage{
+				Type:       "time_sync_pong",
+				ID:         int64(id),
+				ServerTime: int64(serverTime),
+				ClientTime: time.Now().UnixMilli(),
+			})
+			
+		case "online_users":
+			online, _ := msg["online_count"].(float64)
+			players, _ := msg["players_count"].(float64)
+			spectators, _ := msg["spectators_count"].(float64)
+			users, _ := msg["users"].([]interface{})
+			fmt.Printf("👥 在线=%d, 下注玩家=%d, 观战=%d\n", int(online), int(players), int(spectators))
+			for _, u := range users {
+				if user, ok := u.(map[string]interface{}); ok {
+					username, _ := user["username"].(string)
+					playing, _ := user["playing"].(bool)
+					if playing {
+						username += " 🎲"
+					}
+					fmt.Printf("   %s\n", username)
+				}
+			}
+			
+		case "chat_history":
+			room, _ := msg["room"].(string)
+			messages, _ := msg["messages"].([]interface{})
+			fmt.Printf("💬 已加入聊天室 %s，最近%d条消息:\n", room, len(messages))
+			for _, m := range messages {
+				if chat, ok := m.(map[string]interface{}); ok {
+					printChat(chat)
+				}
+			}
+			
+		case "chat_message":
+			printChat(msg)
+			
+		case "chat_leave":
+			room, _ := msg["room"].(string)
+			fmt.Printf("💬 已被移出聊天室 %s\n", room)
 			
 		case "leaderboard_update":
-			fmt.Println("📊 排行榜已更新")
+			period, _ := msg["period"].(string)
+			metric, _ := msg["metric"].(string)
+			entries, _ := msg["entries"].([]interface{})
+			fmt.Printf("📊 排行榜已更新: %s/%s\n", period, metric)
+			for _, e := range entries {
+				if entry, ok := e.(map[string]interface{}); ok {
+					rank, _ := entry["rank"].(float64)
+					username, _ := entry["username"].(string)
+					score, _ := entry["score"].(string)
+					fmt.Printf("   %d. %s %s\n", int(rank), username, score)
+				}
+			}
 			
 		case "system_notification":
 			notifType, _ := msg["type"].(string)
@@ -199,8 +309,15 @@ func (c *TestClient) startCLI() {
 	
 	fmt.Println("\n🎮 Crash游戏测试客户端")
 	fmt.Println("命令:")
-	fmt.Println("  bet <金额> [自动止盈倍数] - 下注")
-	fmt.Println("  cashout <下注ID> - 止盈")
+	fmt.Println("  bet <金额> [自动止盈倍数] [币种] [下注位] - 下注")
+	fmt.Println("  cancel <下注ID> - 本局开始前撤销下注")
+	fmt.Println("  cashout <下注ID> [比例] - 止盈，比例小于1时部分止盈")
+	fmt.Println("  autobet <金额> <自动止盈倍数> <轮数> [币种] - 开启自动下注")
+	fmt.Println("  autostop - 停止自动下注")
+	fmt.Println("  join <聊天室> - 加入聊天室")
+	fmt.Println("  leave <聊天室> - 离开聊天室")
+	fmt.Println("  say <聊天室> <内容> - 发送聊天消息")
+	fmt.Println("  online - 在线用户列表")
 	fmt.Println("  status - 获取游戏状态")
 	fmt.Println("  quit - 退出")
 	fmt.Println()
@@ -224,8 +341,34 @@ func (c *TestClient) startCLI() {
 		switch parts[0] {
 		case "bet":
 			c.handleBet(parts)
+		case "cancel":
+			if len(parts) < 2 {
+				fmt.Println("❌ 用法: cancel <下注ID>")
+				continue
+			}
+			c.send(WSMessage{Type: "player_bet_cancel", BetID: parts[1]}, "↩️ 撤销下注请求已发送")
 		case "cashout":
 			c.handleCashout(parts)
+		case "autobet":
+			c.handleAutoBet(parts)
+		case "autostop":
+			c.send(WSMessage{Type: "auto_bet_stop"}, "🤖 停止自动下注请求已发送")
+		case "join", "leave":
+			if len(parts) < 2 {
+				fmt.Printf("❌ 用法: %s <聊天室>\n", parts[0])
+				continue
+			}
+			c.send(WSMessage{Type: "chat_" + parts[0], Room: parts[1]}, "💬 请求已发送")
+		case "say":
+			if len(parts) < 3 {
+				fmt.Println("❌ 用法: say <聊天室> <内容>")
+				continue
+			}
+			if err := c.writeJSON(WSMessage{Type: "chat_message", Room: parts[1], Content: strings.Join(parts[2:], " ")}); err != nil {
+				fmt.Printf("❌ 发送聊天消息失败: %v\n", err)
+			}
+		case "online":
+			c.send(WSMessage{Type: "online_users"}, "👥 在线用户请求已发送")
 		case "status":
 			c.handleStatus()
 		case "quit", "exit":
@@ -240,7 +383,7 @@ func (c *TestClient) startCLI() {
 // 处理下注命令
 func (c *TestClient) handleBet(parts []string) {
 	if len(parts) < 2 {
-		fmt.Println("❌ 用法: bet <金额> [自动止盈倍数]")
+		fmt.Println("❌ 用法: bet <金额> [自动止盈倍数] [币种] [下注位]")
 		return
 	}
 	
@@ -258,18 +401,35 @@ func (c *TestClient) handleBet(parts []string) {
 		}
 	}
 	
+	var currency string
+	if len(parts) > 3 {
+		currency = strings.ToUpper(parts[3])
+	}
+	
+	var slot *int
+	if len(parts) > 4 {
+		var n int
+		if _, err := fmt.Sscanf(parts[4], "%d", &n); err != nil {
+			fmt.Println("❌ 下注位格式错误")
+			return
+		}
+		slot = &n
+	}
+	
 	betMsg := WSMessage{
 		Type:        "player_bet",
+		Slot:        slot,
+		Currency:    currency,
 		Amount:      amount,
 		AutoCashout: autoCashout,
 	}
 	
-	if err := c.ws.WriteJSON(betMsg); err != nil {
+	if err := c.writeJSON(betMsg); err != nil {
 		fmt.Printf("❌ 发送下注消息失败: %v\n", err)
 		return
 	}
 	
-	fmt.Printf("💰 下注请求已发送: 金额=%.2f", amount)
+	fmt.Printf("💰 下注请求已发送: 金额=%v %s", amount, currency)
 	if autoCashout > 0 {
 		fmt.Printf(", 自动止盈=%.2f", autoCashout)
 	}
@@ -279,18 +439,27 @@ func (c *TestClient) handleBet(parts []string) {
 // 处理止盈命令
 func (c *TestClient) handleCashout(parts []string) {
 	if len(parts) < 2 {
-		fmt.Println("❌ 用法: cashout <下注ID>")
+		fmt.Println("❌ 用法: cashout <下注ID> [比例]")
 		return
 	}
 	
 	betID := parts[1]
 	
+	var fraction float64
+	if len(parts) > 2 {
+		if _, err := fmt.Sscanf(parts[2], "%f", &fraction); err != nil {
+			fmt.Println("❌ 止盈比例格式错误")
+			return
+		}
+	}
+	
 	cashoutMsg := WSMessage{
-		Type:  "player_cashout",
-		BetID: betID,
+		Type:     "player_cashout",
+		BetID:    betID,
+		Fraction: fraction,
 	}
 	
-	if err := c.ws.WriteJSON(cashoutMsg); err != nil {
+	if err := c.writeJSON(cashoutMsg); err != nil {
 		fmt.Printf("❌ 发送止盈消息失败: %v\n", err)
 		return
 	}
@@ -298,6 +467,46 @@ func (c *TestClient) handleCashout(parts []string) {
 	fmt.Printf("💸 止盈请求已发送: ID=%s\n", betID)
 }
 
+// 处理自动下注命令，输赢后均恢复为基础金额
+func (c *TestClient) handleAutoBet(parts []string) {
+	if len(parts) < 4 {
+		fmt.Println("❌ 用法: autobet <金额> <自动止盈倍数> <轮数> [币种]")
+		return
+	}
+	
+	msg := WSMessage{
+		Type:   "auto_bet_start",
+		OnWin:  "reset",
+		OnLoss: "reset",
+	}
+	if _, err := fmt.Sscanf(parts[1], "%f", &msg.BaseAmount); err != nil {
+		fmt.Println("❌ 金额格式错误")
+		return
+	}
+	if _, err := fmt.Sscanf(parts[2], "%f", &msg.AutoCashout); err != nil {
+		fmt.Println("❌ 自动止盈倍数格式错误")
+		return
+	}
+	if _, err := fmt.Sscanf(parts[3], "%d", &msg.Rounds); err != nil {
+		fmt.Println("❌ 轮数格式错误")
+		return
+	}
+	if len(parts) > 4 {
+		msg.Currency = strings.ToUpper(parts[4])
+	}
+	
+	c.send(msg, "🤖 开启自动下注请求已发送")
+}
+
+// 发送消息并打印提示
+func (c *TestClient) send(msg WSMessage, hint string) {
+	if err := c.writeJSON(msg); err != nil {
+		fmt.Printf("❌ 发送消息失败: %v\n", err)
+		return
+	}
+	fmt.Println(hint)
+}
+
 // 处理状态命令
 func (c *TestClient) handleStatus() {
 	resp, err := http.Get("http://localhost:8080/api/v1/game/status")
@@ -317,17 +526,25 @@ func (c *TestClient) handleStatus() {
 		data, _ := statusResp["data"].(map[string]interface{})
 		gameID, _ := data["game_id"].(string)
 		status, _ := data["status"].(float64)
-		multiplier, _ := data["current_multiplier"].(float64)
+		multiplier, _ := data["current_multiplier"].(string)
 		players, _ := data["players_count"].(float64)
 		nextRound, _ := data["next_round_in"].(float64)
 		
 		fmt.Printf("🎮 游戏状态:\n")
 		fmt.Printf("  游戏ID: %s\n", gameID)
 		fmt.Printf("  状态: %d (0:等待 1:进行中 2:已结束)\n", int(status))
-		fmt.Printf("  当前倍数: %.2f\n", multiplier)
+		fmt.Printf("  当前倍数: %s\n", multiplier)
 		fmt.Printf("  玩家数量: %d\n", int(players))
 		fmt.Printf("  下轮开始: %ds\n", int(nextRound))
 	} else {
 		fmt.Printf("❌ 获取状态失败: %v\n", statusResp["message"])
 	}
 }
+
+// 打印聊天消息
+func printChat(msg map[string]interface{}) {
+	room, _ := msg["room"].(string)
+	username, _ := msg["username"].(string)
+	content, _ := msg["content"].(string)
+	fmt.Printf("💬 [%s] %s: %s\n", room, username, content)
+}